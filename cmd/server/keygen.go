@@ -0,0 +1,71 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/config"
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/jwt"
+)
+
+// runKeygen implements the keygen subcommand, which writes signing keys that
+// the server loads with --signing-keys or MOCK_SIGNING_KEYS
+func runKeygen(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("keygen", flag.ContinueOnError)
+	algs := flags.String("alg", "RS256,PS256,ES256,EdDSA", "Comma-separated signing algorithms to generate a key for")
+	format := flags.String("format", "jwks", "Output format: jwks, or pem for a single key")
+	out := flags.String("out", "", "File to write the keys to (default: standard output)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var keySet jwt.JSONWebKeySet
+	for _, alg := range config.SplitList(*algs) {
+		key, err := jwt.GenerateSigningKey(alg)
+		if err != nil {
+			return err
+		}
+		keySet.Keys = append(keySet.Keys, key)
+	}
+	if len(keySet.Keys) == 0 {
+		return errors.New("no algorithms given")
+	}
+
+	var output []byte
+	switch *format {
+	case "jwks":
+		encoded, err := json.MarshalIndent(keySet, "", "  ")
+		if err != nil {
+			return err
+		}
+		output = append(encoded, '\n')
+	case "pem":
+		// PEM carries no algorithm, so RSA keys load as RS256
+		if len(keySet.Keys) != 1 {
+			return errors.New("pem output holds a single key, pass one -alg")
+		}
+		privateKey, err := keySet.Keys[0].PrivateKey()
+		if err != nil {
+			return err
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+		if err != nil {
+			return err
+		}
+		output = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+
+	if *out == "" {
+		_, err := stdout.Write(output)
+		return err
+	}
+	return os.WriteFile(*out, output, 0o600)
+}
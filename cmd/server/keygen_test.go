@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/jwt"
+)
+
+func TestRunKeygen(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr bool
+	}{
+		{name: "default JWKS", args: nil},
+		{name: "single PEM key", args: []string{"-alg", "ES256", "-format", "pem"}},
+		{name: "PEM with several keys", args: []string{"-format", "pem"}, wantErr: true},
+		{name: "unknown algorithm", args: []string{"-alg", "HS256"}, wantErr: true},
+		{name: "unknown format", args: []string{"-format", "der"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			err := runKeygen(tt.args, &stdout)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("runKeygen() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			// The server loads what keygen writes
+			path := filepath.Join(dir, "keys")
+			if err := os.WriteFile(path, stdout.Bytes(), 0o600); err != nil {
+				t.Fatalf("Failed to write key file: %v", err)
+			}
+			if _, err := jwt.LoadKeys(path); err != nil {
+				t.Errorf("Failed to load generated keys: %v", err)
+			}
+		})
+	}
+
+	// -out writes the file instead of standard output
+	out := filepath.Join(dir, "out.json")
+	var stdout bytes.Buffer
+	if err := runKeygen([]string{"-alg", "EdDSA", "-out", out}, &stdout); err != nil {
+		t.Fatalf("runKeygen() error = %v", err)
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("Expected nothing on standard output, got %q", stdout.String())
+	}
+	if info, err := os.Stat(out); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("Expected a key file readable by its owner only, got %v, %v", info, err)
+	}
+}
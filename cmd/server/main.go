@@ -5,22 +5,36 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"strconv"
 	"time"
 
 	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/config"
 	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/handlers"
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/jwt"
 	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/models"
 	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/store"
 	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/version"
 )
 
 func main() {
+	// Subcommands come before the server flags
+	if len(os.Args) > 1 && os.Args[1] == "keygen" {
+		if err := runKeygen(os.Args[2:], os.Stdout); err != nil {
+			log.Fatalf("keygen: %v", err)
+		}
+		return
+	}
+
 	// Define command-line flags
 	var port int
 	var host string
+	var configFile string
+	var signingKeys string
 	flag.IntVar(&port, "port", 0, "Port to run the server on (default: uses MOCK_OAUTH_PORT env var or 8080)")
 	flag.StringVar(&host, "host", "", "Host for public URLs (default: http://localhost:[port])")
+	flag.StringVar(&configFile, "config", "", "JSON file with users, clients, scopes and claim rules to seed (default: uses MOCK_CONFIG_FILE env var)")
+	flag.StringVar(&signingKeys, "signing-keys", "", "Comma-separated PEM, JWK or JWKS files with persistent signing keys (default: uses MOCK_SIGNING_KEYS env var, or ephemeral keys)")
 	flag.Parse()
 
 	// Log version info on startup
@@ -61,25 +75,104 @@ func main() {
 
 	// Initialize in-memory store with configuration
 	memoryStore := store.NewMemoryStore()
+	if cfg.SigningAlg != "" && !jwt.IsSigningAlgorithm(cfg.SigningAlg) {
+		log.Fatalf("Unsupported signing algorithm %q, expected one of %v", cfg.SigningAlg, jwt.SigningAlgorithms())
+	}
+	memoryStore.StoreSettings(cfg.Settings())
+
+	// Seed the user and client registries from the configuration file, if one was given
+	if configFile == "" {
+		configFile = config.ConfigFilePath()
+	}
+	if configFile != "" {
+		fileConfig, err := config.LoadFile(configFile)
+		if err != nil {
+			log.Fatalf("Failed to load config file: %v", err)
+		}
+		for _, user := range fileConfig.Users {
+			memoryStore.StoreUser(user)
+		}
+		for _, client := range fileConfig.Clients {
+			memoryStore.StoreClient(client)
+		}
+		for _, scope := range fileConfig.Scopes {
+			memoryStore.StoreScope(scope)
+		}
+		for _, rule := range fileConfig.Rules {
+			memoryStore.StoreClaimRule(rule)
+		}
+		if fileConfig.Claims != nil {
+			memoryStore.StoreCustomClaims(fileConfig.Claims)
+		}
+		log.Printf("Loaded %d users, %d clients, %d scopes and %d claim rules from %s", len(fileConfig.Users), len(fileConfig.Clients), len(fileConfig.Scopes), len(fileConfig.Rules), configFile)
+	}
 
 	// Set up default user using configuration
 	defaultUser := models.NewDefaultUser()
 
+	// Load persistent signing keys, if any. Otherwise keys are generated on first use.
+	keyFiles := cfg.SigningKeyFiles
+	if signingKeys != "" {
+		keyFiles = config.SplitList(signingKeys)
+	}
+	if len(keyFiles) > 0 {
+		keys, err := jwt.LoadKeys(keyFiles...)
+		if err != nil {
+			log.Fatalf("Failed to load signing keys: %v", err)
+		}
+		for _, key := range keys {
+			log.Printf("Signing key %s (%s, active: %v)", key.Kid, key.Alg, key.Active)
+		}
+	}
+
+	// Rotate the signing key on a timer, if configured
+	gracePeriod := time.Duration(cfg.KeyGracePeriod) * time.Second
+	if cfg.KeyRotationInterval > 0 {
+		interval := time.Duration(cfg.KeyRotationInterval) * time.Second
+		stopRotation := jwt.StartKeyRotation(interval, gracePeriod)
+		defer stopRotation()
+		log.Printf("Rotating signing keys every %s with a grace period of %s", interval, gracePeriod)
+	}
+
 	// Create a new ServeMux
 	mux := http.NewServeMux()
 
 	// Set up routes
 	mux.Handle("/authorize", &handlers.AuthorizeHandler{Store: memoryStore})
 	mux.Handle("/token", handlers.NewTokenHandlerWithIssuer(memoryStore, baseURL))
-	mux.Handle("/userinfo", &handlers.UserInfoHandler{Store: memoryStore})
+	mux.Handle("/userinfo", &handlers.UserInfoHandler{Store: memoryStore, IssuerURL: baseURL})
+	mux.Handle("/groups", handlers.NewGroupsHandler(memoryStore))
 	mux.Handle("/config", handlers.NewConfigHandler(memoryStore, defaultUser))
 	mux.Handle("/version", handlers.NewVersionHandler())
+	mux.Handle("/device/code", handlers.NewDeviceAuthorizationHandler(memoryStore, baseURL))
+	mux.Handle("/device", handlers.NewDeviceVerificationHandler(memoryStore))
+	mux.Handle("/introspect", handlers.NewIntrospectionHandler(memoryStore, baseURL))
+	mux.Handle("/revoke", handlers.NewRevocationHandler(memoryStore))
+	usersHandler := handlers.NewUsersHandler(memoryStore)
+	mux.Handle("/admin/users", usersHandler)
+	mux.Handle("/admin/users/", usersHandler)
+	mux.Handle("/admin/consents", handlers.NewConsentsHandler(memoryStore))
+	clientsHandler := handlers.NewClientsHandler(memoryStore)
+	mux.Handle("/admin/clients", clientsHandler)
+	mux.Handle("/admin/clients/", clientsHandler)
+	scopesHandler := handlers.NewScopesHandler(memoryStore)
+	mux.Handle("/admin/scopes", scopesHandler)
+	mux.Handle("/admin/scopes/", scopesHandler)
+	rulesHandler := handlers.NewRulesHandler(memoryStore)
+	mux.Handle("/admin/rules", rulesHandler)
+	mux.Handle("/admin/rules/", rulesHandler)
+	registrationHandler := handlers.NewRegistrationHandler(memoryStore, baseURL)
+	mux.Handle("/register", registrationHandler)
+	mux.Handle("/register/", registrationHandler)
+	keysHandler := handlers.NewKeysHandler(gracePeriod)
+	mux.Handle("/admin/keys", keysHandler)
+	mux.Handle("/admin/keys/", keysHandler)
 
 	// Add OpenID Connect Discovery endpoint
-	mux.Handle("/.well-known/openid-configuration", handlers.NewOpenIDConfigHandler(baseURL))
+	mux.Handle("/.well-known/openid-configuration", handlers.NewOpenIDConfigHandler(memoryStore, baseURL))
 
 	// Add JWKS endpoint
-	mux.Handle("/jwks", handlers.NewJWKSHandler())
+	mux.Handle("/jwks", handlers.NewJWKSHandlerWithMaxAge(time.Duration(cfg.JWKSMaxAge)*time.Second))
 
 	// Start the server with the custom ServeMux
 	startServer(serverPort, mux)
@@ -3,7 +3,10 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 	"sync"
+
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/types"
 )
 
 // ServerConfig holds configuration parameters for the OAuth2 server
@@ -13,7 +16,26 @@ type ServerConfig struct {
 	MockUserName    string
 	MockTokenExpiry int
 	IssuerURL       string
-	mu              sync.RWMutex
+
+	RefreshTokenRotation       bool
+	RefreshTokenReuseDetection bool
+	RequirePKCE                bool
+	RequireRegisteredClients   bool
+	SigningAlg                 string
+	PairwiseSalt               string
+	GroupsOverageThreshold     int
+
+	// KeyRotationInterval rotates the signing key every so many seconds. Zero disables the timer.
+	KeyRotationInterval int
+	// KeyGracePeriod is how many seconds a rotated-out key stays published in the JWKS
+	KeyGracePeriod int
+	// JWKSMaxAge is how many seconds clients may cache the JWKS
+	JWKSMaxAge int
+	// SigningKeyFiles are PEM, JWK or JWKS files with persistent signing keys.
+	// Empty generates ephemeral keys at startup.
+	SigningKeyFiles []string
+
+	mu sync.RWMutex
 }
 
 var defaultConfig = ServerConfig{
@@ -22,6 +44,8 @@ var defaultConfig = ServerConfig{
 	MockUserName:    "Test User",
 	MockTokenExpiry: 3600,
 	IssuerURL:       "", // Will be auto-generated if not specified
+	KeyGracePeriod:  3600,
+	JWKSMaxAge:      300,
 }
 
 // LoadConfig loads server configuration from environment variables or returns defaults
@@ -53,9 +77,80 @@ func LoadConfig() *ServerConfig {
 		config.IssuerURL = issuerURL
 	}
 
+	if rotation, exists := os.LookupEnv("MOCK_REFRESH_TOKEN_ROTATION"); exists {
+		if parsed, err := strconv.ParseBool(rotation); err == nil {
+			config.RefreshTokenRotation = parsed
+		}
+	}
+
+	if reuseDetection, exists := os.LookupEnv("MOCK_REFRESH_TOKEN_REUSE_DETECTION"); exists {
+		if parsed, err := strconv.ParseBool(reuseDetection); err == nil {
+			config.RefreshTokenReuseDetection = parsed
+		}
+	}
+
+	if requirePKCE, exists := os.LookupEnv("MOCK_REQUIRE_PKCE"); exists {
+		if parsed, err := strconv.ParseBool(requirePKCE); err == nil {
+			config.RequirePKCE = parsed
+		}
+	}
+
+	if requireRegistered, exists := os.LookupEnv("MOCK_REQUIRE_REGISTERED_CLIENTS"); exists {
+		if parsed, err := strconv.ParseBool(requireRegistered); err == nil {
+			config.RequireRegisteredClients = parsed
+		}
+	}
+
+	if signingAlg, exists := os.LookupEnv("MOCK_SIGNING_ALG"); exists {
+		config.SigningAlg = signingAlg
+	}
+
+	if salt, exists := os.LookupEnv("MOCK_PAIRWISE_SALT"); exists {
+		config.PairwiseSalt = salt
+	}
+
+	if threshold, exists := os.LookupEnv("MOCK_GROUPS_OVERAGE_THRESHOLD"); exists {
+		if parsed, err := strconv.Atoi(threshold); err == nil {
+			config.GroupsOverageThreshold = parsed
+		}
+	}
+
+	if interval, exists := os.LookupEnv("MOCK_KEY_ROTATION_INTERVAL"); exists {
+		if parsed, err := strconv.Atoi(interval); err == nil {
+			config.KeyRotationInterval = parsed
+		}
+	}
+
+	if gracePeriod, exists := os.LookupEnv("MOCK_KEY_GRACE_PERIOD"); exists {
+		if parsed, err := strconv.Atoi(gracePeriod); err == nil {
+			config.KeyGracePeriod = parsed
+		}
+	}
+
+	if maxAge, exists := os.LookupEnv("MOCK_JWKS_MAX_AGE"); exists {
+		if parsed, err := strconv.Atoi(maxAge); err == nil {
+			config.JWKSMaxAge = parsed
+		}
+	}
+
+	if keyFiles, exists := os.LookupEnv("MOCK_SIGNING_KEYS"); exists {
+		config.SigningKeyFiles = SplitList(keyFiles)
+	}
+
 	return config
 }
 
+// SplitList splits a comma-separated list, dropping empty entries
+func SplitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 // UpdateConfig updates the server configuration with values from the provided map
 func (c *ServerConfig) UpdateConfig(newConfig map[string]interface{}) {
 	c.mu.Lock()
@@ -89,5 +184,34 @@ func (c *ServerConfig) GetConfig() ServerConfig {
 		MockUserName:    c.MockUserName,
 		MockTokenExpiry: c.MockTokenExpiry,
 		IssuerURL:       c.IssuerURL,
+
+		RefreshTokenRotation:       c.RefreshTokenRotation,
+		RefreshTokenReuseDetection: c.RefreshTokenReuseDetection,
+		RequirePKCE:                c.RequirePKCE,
+		RequireRegisteredClients:   c.RequireRegisteredClients,
+		SigningAlg:                 c.SigningAlg,
+		PairwiseSalt:               c.PairwiseSalt,
+		GroupsOverageThreshold:     c.GroupsOverageThreshold,
+
+		KeyRotationInterval: c.KeyRotationInterval,
+		KeyGracePeriod:      c.KeyGracePeriod,
+		JWKSMaxAge:          c.JWKSMaxAge,
+		SigningKeyFiles:     c.SigningKeyFiles,
+	}
+}
+
+// Settings returns the runtime behaviour settings used to seed the store
+func (c *ServerConfig) Settings() types.Settings {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return types.Settings{
+		RefreshTokenRotation:       c.RefreshTokenRotation,
+		RefreshTokenReuseDetection: c.RefreshTokenReuseDetection,
+		RequirePKCE:                c.RequirePKCE,
+		RequireRegisteredClients:   c.RequireRegisteredClients,
+		SigningAlg:                 c.SigningAlg,
+		PairwiseSalt:               c.PairwiseSalt,
+		GroupsOverageThreshold:     c.GroupsOverageThreshold,
 	}
 }
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/models"
+)
+
+// FileConfig holds the users, clients, custom scopes, claim rules and global
+// custom claims loaded from a JSON configuration file
+type FileConfig struct {
+	Users   []*models.UserInfo   `json:"users"`
+	Clients []*models.Client     `json:"clients"`
+	Scopes  []*models.Scope      `json:"scopes"`
+	Rules   []*models.ClaimRule  `json:"rules"`
+	Claims  *models.CustomClaims `json:"claims"`
+}
+
+// ConfigFilePath returns the configuration file named by MOCK_CONFIG_FILE, if any
+func ConfigFilePath() string {
+	return os.Getenv("MOCK_CONFIG_FILE")
+}
+
+// LoadFile reads and validates a JSON configuration file
+func LoadFile(path string) (*FileConfig, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- the path is supplied by the operator
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+
+	var fileConfig FileConfig
+	if err := json.Unmarshal(data, &fileConfig); err != nil {
+		return nil, fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+
+	for i, user := range fileConfig.Users {
+		if user == nil || user.Sub == "" {
+			return nil, fmt.Errorf("config file %s: user %d is missing a sub", path, i)
+		}
+		if user.ID == "" {
+			user.ID = user.Sub
+		}
+		if err := user.CustomClaims.Validate(); err != nil {
+			return nil, fmt.Errorf("config file %s: user %d: custom_claims: %w", path, i, err)
+		}
+		if err := user.ValidateMemberships(); err != nil {
+			return nil, fmt.Errorf("config file %s: user %d: %w", path, i, err)
+		}
+	}
+
+	for i, client := range fileConfig.Clients {
+		if client == nil {
+			return nil, fmt.Errorf("config file %s: client %d is empty", path, i)
+		}
+		if err := client.Validate(); err != nil {
+			return nil, fmt.Errorf("config file %s: client %d: %w", path, i, err)
+		}
+	}
+
+	for i, scope := range fileConfig.Scopes {
+		if scope == nil {
+			return nil, fmt.Errorf("config file %s: scope %d is empty", path, i)
+		}
+		if err := scope.Validate(); err != nil {
+			return nil, fmt.Errorf("config file %s: scope %d: %w", path, i, err)
+		}
+	}
+
+	for i, rule := range fileConfig.Rules {
+		if rule == nil {
+			return nil, fmt.Errorf("config file %s: rule %d is empty", path, i)
+		}
+		if err := rule.Validate(); err != nil {
+			return nil, fmt.Errorf("config file %s: rule %d (%s): %w", path, i, rule.Name, err)
+		}
+	}
+
+	if err := fileConfig.Claims.Validate(); err != nil {
+		return nil, fmt.Errorf("config file %s: claims: %w", path, err)
+	}
+
+	return &fileConfig, nil
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadFile(t *testing.T) {
+	// Cases are named after the config section they cover, so that
+	// go test -run TestLoadFile/clients runs a single section
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+		check   func(t *testing.T, fileConfig *FileConfig)
+	}{
+		{
+			name:    "users/ID defaults to sub",
+			content: `{"users":[{"sub":"alice","email":"alice@example.com"}]}`,
+			check: func(t *testing.T, fileConfig *FileConfig) {
+				if len(fileConfig.Users) != 1 || fileConfig.Users[0].Sub != "alice" || fileConfig.Users[0].ID != "alice" {
+					t.Errorf("expected user alice with ID defaulted to sub, got %+v", fileConfig.Users)
+				}
+			},
+		},
+		{name: "users/missing sub", content: `{"users":[{"email":"nosub@example.com"}]}`, wantErr: true},
+		{name: "users/empty group", content: `{"users":[{"sub":"alice","groups":[""]}]}`, wantErr: true},
+		{
+			name:    "clients/registered client",
+			content: `{"clients":[{"client_id":"web-app","client_secret":"secret","redirect_uris":["https://app.example.com/callback"]}]}`,
+			check: func(t *testing.T, fileConfig *FileConfig) {
+				if len(fileConfig.Clients) != 1 || fileConfig.Clients[0].ClientID != "web-app" {
+					t.Errorf("expected client web-app, got %+v", fileConfig.Clients)
+				}
+			},
+		},
+		{name: "clients/confidential client without secret", content: `{"clients":[{"client_id":"web-app","client_type":"confidential"}]}`, wantErr: true},
+		{name: "clients/authorization code client without redirect URIs", content: `{"clients":[{"client_id":"web-app","client_secret":"secret"}]}`, wantErr: true},
+		{
+			name:    "scopes/custom scope",
+			content: `{"scopes":[{"name":"groups","claims":["groups"]}]}`,
+			check: func(t *testing.T, fileConfig *FileConfig) {
+				if len(fileConfig.Scopes) != 1 || fileConfig.Scopes[0].Name != "groups" {
+					t.Errorf("expected scope groups, got %+v", fileConfig.Scopes)
+				}
+			},
+		},
+		{name: "scopes/standard scope redefined", content: `{"scopes":[{"name":"profile","claims":["groups"]}]}`, wantErr: true},
+		{
+			name:    "claims/global and user custom claims",
+			content: `{"claims":{"access_token":{"tenant_id":"t1"}},"users":[{"sub":"alice","custom_claims":{"id_token":{"roles":["admin"]}}}]}`,
+			check: func(t *testing.T, fileConfig *FileConfig) {
+				if fileConfig.Claims == nil || fileConfig.Claims.AccessToken["tenant_id"] != "t1" || fileConfig.Users[0].CustomClaims == nil {
+					t.Errorf("expected global and user custom claims, got %+v and %+v", fileConfig.Claims, fileConfig.Users[0])
+				}
+			},
+		},
+		{name: "claims/reserved global claim", content: `{"claims":{"id_token":{"iss":"https://evil.example.com"}}}`, wantErr: true},
+		{name: "claims/reserved user claim", content: `{"users":[{"sub":"alice","custom_claims":{"access_token":{"sub":"bob"}}}]}`, wantErr: true},
+		{
+			name:    "rules/claim rule",
+			content: `{"rules":[{"name":"roles","claim":"roles","expression":"'admins' in user.groups ? ['admin'] : []"}]}`,
+			check: func(t *testing.T, fileConfig *FileConfig) {
+				if len(fileConfig.Rules) != 1 || fileConfig.Rules[0].Name != "roles" {
+					t.Errorf("expected rule roles, got %+v", fileConfig.Rules)
+				}
+			},
+		},
+		{name: "rules/expression does not compile", content: `{"rules":[{"name":"roles","claim":"roles","expression":"user.groups.exists(g,"}]}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.json")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			fileConfig, err := LoadFile(path)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			tt.check(t, fileConfig)
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		if _, err := LoadFile(filepath.Join(t.TempDir(), "absent.json")); err == nil {
+			t.Errorf("expected error for missing file")
+		}
+	})
+}
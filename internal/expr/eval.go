@@ -0,0 +1,516 @@
+package expr
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Eval evaluates the expression with the given variable values. Selecting a
+// missing field of a map, or any field of null, yields null. Operators and
+// functions applied to values of the wrong type return an error.
+func (p *Program) Eval(variables map[string]interface{}) (interface{}, error) {
+	// Macros bind their variables in a copy, leaving the caller's map intact
+	vars := make(map[string]interface{}, len(variables))
+	for name, value := range variables {
+		vars[name] = value
+	}
+	return p.root.eval(vars)
+}
+
+// node is an expression tree node
+type node interface {
+	eval(vars map[string]interface{}) (interface{}, error)
+}
+
+type literalNode struct{ value interface{} }
+
+func (n *literalNode) eval(map[string]interface{}) (interface{}, error) {
+	return n.value, nil
+}
+
+type identNode struct{ name string }
+
+func (n *identNode) eval(vars map[string]interface{}) (interface{}, error) {
+	return vars[n.name], nil
+}
+
+type selectNode struct {
+	operand node
+	field   string
+}
+
+func (n *selectNode) eval(vars map[string]interface{}) (interface{}, error) {
+	operand, err := n.operand.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	switch operand := operand.(type) {
+	case nil:
+		return nil, nil
+	case map[string]interface{}:
+		return operand[n.field], nil
+	default:
+		return nil, fmt.Errorf("cannot select field %q of a %s", n.field, typeName(operand))
+	}
+}
+
+// hasNode tests whether a map has a field, as in has(user.groups)
+type hasNode struct{ field *selectNode }
+
+func (n *hasNode) eval(vars map[string]interface{}) (interface{}, error) {
+	operand, err := n.field.operand.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := operand.(map[string]interface{})
+	if !ok {
+		return false, nil
+	}
+	_, exists := m[n.field.field]
+	return exists, nil
+}
+
+type indexNode struct{ operand, index node }
+
+func (n *indexNode) eval(vars map[string]interface{}) (interface{}, error) {
+	operand, err := n.operand.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	index, err := n.index.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	switch operand := operand.(type) {
+	case nil:
+		return nil, nil
+	case map[string]interface{}:
+		key, ok := index.(string)
+		if !ok {
+			return nil, fmt.Errorf("cannot index a map with a %s", typeName(index))
+		}
+		return operand[key], nil
+	case []interface{}:
+		i, ok := index.(float64)
+		if !ok || i != math.Trunc(i) {
+			return nil, fmt.Errorf("cannot index a list with %v", index)
+		}
+		if i < 0 || int(i) >= len(operand) {
+			return nil, fmt.Errorf("index %v out of range for a list of %d", i, len(operand))
+		}
+		return operand[int(i)], nil
+	default:
+		return nil, fmt.Errorf("cannot index a %s", typeName(operand))
+	}
+}
+
+type listNode struct{ elements []node }
+
+func (n *listNode) eval(vars map[string]interface{}) (interface{}, error) {
+	list := make([]interface{}, 0, len(n.elements))
+	for _, element := range n.elements {
+		value, err := element.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, value)
+	}
+	return list, nil
+}
+
+type mapNode struct{ keys, values []node }
+
+func (n *mapNode) eval(vars map[string]interface{}) (interface{}, error) {
+	m := make(map[string]interface{}, len(n.keys))
+	for i, keyNode := range n.keys {
+		key, err := keyNode.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		name, ok := key.(string)
+		if !ok {
+			return nil, fmt.Errorf("map keys must be strings, got a %s", typeName(key))
+		}
+		if m[name], err = n.values[i].eval(vars); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+type unaryNode struct {
+	operator string
+	operand  node
+}
+
+func (n *unaryNode) eval(vars map[string]interface{}) (interface{}, error) {
+	operand, err := n.operand.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	switch operand := operand.(type) {
+	case bool:
+		if n.operator == "!" {
+			return !operand, nil
+		}
+	case float64:
+		if n.operator == "-" {
+			return -operand, nil
+		}
+	}
+	return nil, fmt.Errorf("cannot apply %s to a %s", n.operator, typeName(operand))
+}
+
+type binaryNode struct {
+	operator    string
+	left, right node
+}
+
+func (n *binaryNode) eval(vars map[string]interface{}) (interface{}, error) {
+	left, err := n.left.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	// The logical operators only evaluate their right operand when needed
+	if n.operator == "&&" || n.operator == "||" {
+		l, ok := left.(bool)
+		if !ok {
+			return nil, fmt.Errorf("cannot apply %s to a %s", n.operator, typeName(left))
+		}
+		if l == (n.operator == "||") {
+			return l, nil
+		}
+		right, err := n.right.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		r, ok := right.(bool)
+		if !ok {
+			return nil, fmt.Errorf("cannot apply %s to a %s", n.operator, typeName(right))
+		}
+		return r, nil
+	}
+
+	right, err := n.right.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.operator {
+	case "==":
+		return reflect.DeepEqual(left, right), nil
+	case "!=":
+		return !reflect.DeepEqual(left, right), nil
+	case "in":
+		return contains(right, left)
+	case "+":
+		switch l := left.(type) {
+		case string:
+			if r, ok := right.(string); ok {
+				return l + r, nil
+			}
+		case []interface{}:
+			if r, ok := right.([]interface{}); ok {
+				return append(append(make([]interface{}, 0, len(l)+len(r)), l...), r...), nil
+			}
+		}
+	case "<", "<=", ">", ">=":
+		switch l := left.(type) {
+		case string:
+			if r, ok := right.(string); ok {
+				return compare(n.operator, strings.Compare(l, r)), nil
+			}
+		case float64:
+			if r, ok := right.(float64); ok {
+				switch {
+				case l < r:
+					return compare(n.operator, -1), nil
+				case l > r:
+					return compare(n.operator, 1), nil
+				}
+				return compare(n.operator, 0), nil
+			}
+		}
+	}
+
+	l, lok := left.(float64)
+	r, rok := right.(float64)
+	if lok && rok {
+		switch n.operator {
+		case "+":
+			return l + r, nil
+		case "-":
+			return l - r, nil
+		case "*":
+			return l * r, nil
+		case "/", "%":
+			if r == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+			if n.operator == "%" {
+				return math.Mod(l, r), nil
+			}
+			return l / r, nil
+		}
+	}
+	return nil, fmt.Errorf("cannot apply %s to a %s and a %s", n.operator, typeName(left), typeName(right))
+}
+
+// compare reports whether a comparison with the given result satisfies the operator
+func compare(operator string, result int) bool {
+	switch operator {
+	case "<":
+		return result < 0
+	case "<=":
+		return result <= 0
+	case ">":
+		return result > 0
+	default:
+		return result >= 0
+	}
+}
+
+// contains implements the in operator: membership of a list or a map's keys.
+// Nothing is in null.
+func contains(container, value interface{}) (bool, error) {
+	switch container := container.(type) {
+	case nil:
+		return false, nil
+	case []interface{}:
+		for _, element := range container {
+			if reflect.DeepEqual(element, value) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case map[string]interface{}:
+		key, ok := value.(string)
+		if !ok {
+			return false, nil
+		}
+		_, exists := container[key]
+		return exists, nil
+	default:
+		return false, fmt.Errorf("cannot apply in to a %s", typeName(container))
+	}
+}
+
+type conditionalNode struct{ condition, then, otherwise node }
+
+func (n *conditionalNode) eval(vars map[string]interface{}) (interface{}, error) {
+	condition, err := n.condition.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	c, ok := condition.(bool)
+	if !ok {
+		return nil, fmt.Errorf("condition must be a bool, got a %s", typeName(condition))
+	}
+	if c {
+		return n.then.eval(vars)
+	}
+	return n.otherwise.eval(vars)
+}
+
+// callNode calls a global function (target is nil) or a method
+type callNode struct {
+	function string
+	target   node
+	args     []node
+	pattern  *regexp.Regexp // compiled literal pattern of matches()
+}
+
+func (n *callNode) eval(vars map[string]interface{}) (interface{}, error) {
+	var receiver interface{}
+	if n.target != nil {
+		var err error
+		if receiver, err = n.target.eval(vars); err != nil {
+			return nil, err
+		}
+	}
+	args := make([]interface{}, len(n.args))
+	for i, arg := range n.args {
+		var err error
+		if args[i], err = arg.eval(vars); err != nil {
+			return nil, err
+		}
+	}
+
+	switch n.function {
+	case "size":
+		if n.target == nil {
+			receiver = args[0]
+		}
+		switch receiver := receiver.(type) {
+		case string:
+			return float64(utf8.RuneCountInString(receiver)), nil
+		case []interface{}:
+			return float64(len(receiver)), nil
+		case map[string]interface{}:
+			return float64(len(receiver)), nil
+		}
+		return nil, fmt.Errorf("size() of a %s", typeName(receiver))
+	case "string":
+		switch arg := args[0].(type) {
+		case string:
+			return arg, nil
+		case float64:
+			return strconv.FormatFloat(arg, 'f', -1, 64), nil
+		case bool:
+			return strconv.FormatBool(arg), nil
+		}
+		return nil, fmt.Errorf("string() of a %s", typeName(args[0]))
+	case "join":
+		list, ok := receiver.([]interface{})
+		separator, sepOK := args[0].(string)
+		if !ok || !sepOK {
+			return nil, fmt.Errorf("join() needs a list of strings and a string separator")
+		}
+		parts := make([]string, len(list))
+		for i, element := range list {
+			if parts[i], ok = element.(string); !ok {
+				return nil, fmt.Errorf("join() of a list containing a %s", typeName(element))
+			}
+		}
+		return strings.Join(parts, separator), nil
+	}
+
+	s, ok := receiver.(string)
+	if !ok {
+		return nil, fmt.Errorf("%s() on a %s", n.function, typeName(receiver))
+	}
+	switch n.function {
+	case "lowerAscii":
+		return strings.ToLower(s), nil
+	case "upperAscii":
+		return strings.ToUpper(s), nil
+	}
+
+	arg, ok := args[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("%s() needs a string argument, got a %s", n.function, typeName(args[0]))
+	}
+	switch n.function {
+	case "startsWith":
+		return strings.HasPrefix(s, arg), nil
+	case "endsWith":
+		return strings.HasSuffix(s, arg), nil
+	case "contains":
+		return strings.Contains(s, arg), nil
+	case "split":
+		parts := strings.Split(s, arg)
+		list := make([]interface{}, len(parts))
+		for i, part := range parts {
+			list[i] = part
+		}
+		return list, nil
+	case "matches":
+		re := n.pattern
+		if re == nil {
+			var err error
+			if re, err = regexp.Compile(arg); err != nil {
+				return nil, fmt.Errorf("invalid pattern: %w", err)
+			}
+		}
+		return re.MatchString(s), nil
+	}
+	return nil, fmt.Errorf("unknown function %q", n.function)
+}
+
+// macroNode evaluates a body for each element of a list, or each key of a
+// map, with the element bound to a variable. Null is an empty list.
+type macroNode struct {
+	macro    string
+	target   node
+	variable string
+	body     node
+}
+
+func (n *macroNode) eval(vars map[string]interface{}) (interface{}, error) {
+	target, err := n.target.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	var elements []interface{}
+	switch target := target.(type) {
+	case nil:
+	case []interface{}:
+		elements = target
+	case map[string]interface{}:
+		for key := range target {
+			elements = append(elements, key)
+		}
+		sort.Slice(elements, func(i, j int) bool { return elements[i].(string) < elements[j].(string) })
+	default:
+		return nil, fmt.Errorf("%s() on a %s", n.macro, typeName(target))
+	}
+
+	previous, bound := vars[n.variable]
+	defer func() {
+		if bound {
+			vars[n.variable] = previous
+		} else {
+			delete(vars, n.variable)
+		}
+	}()
+
+	results := make([]interface{}, 0, len(elements))
+	for _, element := range elements {
+		vars[n.variable] = element
+		value, err := n.body.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		if n.macro == "map" {
+			results = append(results, value)
+			continue
+		}
+		matched, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%s() needs a bool condition, got a %s", n.macro, typeName(value))
+		}
+		switch {
+		case n.macro == "exists" && matched:
+			return true, nil
+		case n.macro == "all" && !matched:
+			return false, nil
+		case n.macro == "filter" && matched:
+			results = append(results, element)
+		}
+	}
+
+	switch n.macro {
+	case "exists":
+		return false, nil
+	case "all":
+		return true, nil
+	}
+	return results, nil
+}
+
+// typeName names the type of a value in error messages
+func typeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "list"
+	case map[string]interface{}:
+		return "map"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
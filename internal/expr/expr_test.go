@@ -0,0 +1,146 @@
+package expr
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	variables := map[string]interface{}{
+		"user": map[string]interface{}{
+			"email":          "alice@corp.example.com",
+			"email_verified": true,
+			"groups":         []interface{}{"admins", "app-billing", "app-reports"},
+			"address":        map[string]interface{}{"country": "NZ"},
+		},
+		"scopes":  []interface{}{"openid", "email"},
+		"request": map[string]interface{}{"grant_type": "authorization_code"},
+	}
+
+	testCases := []struct {
+		source string
+		want   interface{}
+	}{
+		{`"admins" in user.groups ? ["admin"] : ["reader"]`, []interface{}{"admin"}},
+		{`user.email.endsWith("@corp.example.com") && user.email_verified`, true},
+		{`user.email.split("@")[1]`, "corp.example.com"},
+		{`user.groups.filter(g, g.startsWith("app-")).map(g, g.upperAscii())`, []interface{}{"APP-BILLING", "APP-REPORTS"}},
+		{`user.groups.exists(g, g.matches("^app-"))`, true},
+		{`user.groups.all(g, size(g) > 5)`, true},
+		{`user.missing.field`, nil},
+		{`has(user.groups) && !has(user.hd)`, true},
+		{`user.address["country"] == 'NZ'`, true},
+		{`"email" in scopes && !("profile" in scopes)`, true},
+		{`"country" in user.address`, true},
+		{`"x" in user.missing`, false},
+		{`size(user.groups) * 2 + 1`, 7.0},
+		{`-(10 % 4) / 2`, -1.0},
+		{`"group count: " + string(size(user.groups))`, "group count: 3"},
+		{`user.groups.join(",")`, "admins,app-billing,app-reports"},
+		{`{"tier": request.grant_type == "client_credentials" ? "machine" : "human", "ids": [1, 2.5e0]}`, map[string]interface{}{"tier": "human", "ids": []interface{}{1.0, 2.5}}},
+		{`user.missing.exists(g, g == "x")`, false},
+		{`user.groups + ["extra"] == ["admins", "app-billing", "app-reports", "extra"]`, true},
+		{`"a" < "b" && 2 >= 2 && null == user.hd`, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.source, func(t *testing.T) {
+			program, err := Compile(tc.source, "user", "client", "scopes", "request")
+			if err != nil {
+				t.Fatalf("Compile() error: %v", err)
+			}
+			got, err := program.Eval(variables)
+			if err != nil {
+				t.Fatalf("Eval() error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Eval() = %#v; want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, source := range []string{
+		``,
+		`user.`,
+		`"unterminated`,
+		`user.email ==`,
+		`account.email`,
+		`user.email.reverse()`,
+		`size(user.groups, 2)`,
+		`user.email.matches("[")`,
+		`has(user)`,
+		`user.groups.exists(1, true)`,
+		`g == "admins" || user.groups.exists(g, g == "admins")`,
+		`user.email # comment`,
+		`(user.email`,
+		`true ? 1`,
+	} {
+		if _, err := Compile(source, "user"); err == nil {
+			t.Errorf("Compile(%q) succeeded; want an error", source)
+		}
+	}
+}
+
+func TestCompileNestingDepth(t *testing.T) {
+	nested := func(prefix, operand, suffix string, levels int) string {
+		return strings.Repeat(prefix, levels) + operand + strings.Repeat(suffix, levels)
+	}
+
+	for _, source := range []string{
+		nested("(", "1", ")", maxDepth-1),
+		nested("!", "true", "", maxDepth-1),
+		nested("", "1", " + 1", maxDepth-1),
+	} {
+		if _, err := Compile(source, "user"); err != nil {
+			t.Errorf("Compile() of %d bytes error: %v", len(source), err)
+		}
+	}
+
+	// Rule bodies can be up to 1MB, so the parser must not recurse as deep as
+	// the input is long
+	for _, source := range []string{
+		nested("(", "1", ")", 1<<19),
+		nested("[", "1", "]", 10000),
+		nested("{1: ", "1", "}", 10000),
+		nested("!", "true", "", 10000),
+		nested("-", "1", "", maxDepth+1),
+		nested("", "1", " + 1", 10000),
+		nested("", "user", ".a", 10000),
+		nested("", "user", "[0]", 10000),
+		nested("true ? 1 : ", "2", "", 10000),
+		nested("size(", "user", ")", 10000),
+		nested("user.groups.exists(g, ", "true", ")", 10000),
+	} {
+		_, err := Compile(source, "user")
+		if err == nil || !strings.Contains(err.Error(), "nested more than") {
+			t.Errorf("Compile() of %d bytes error = %v; want a nesting error", len(source), err)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	variables := map[string]interface{}{
+		"user": map[string]interface{}{"email": "alice@example.com", "groups": []interface{}{"admins"}},
+	}
+	for _, source := range []string{
+		`user.hd.endsWith("example.com")`,
+		`user.email && true`,
+		`user.groups[1]`,
+		`user.email.size > 1`,
+		`1 / 0`,
+		`user.email ? 1 : 2`,
+		`user.groups.filter(g, g)`,
+		`"a" + 1`,
+	} {
+		program, err := Compile(source, "user")
+		if err != nil {
+			t.Fatalf("Compile(%q) error: %v", source, err)
+		}
+		if _, err := program.Eval(variables); err == nil {
+			t.Errorf("Eval(%q) succeeded; want an error", source)
+		}
+	}
+}
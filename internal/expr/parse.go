@@ -0,0 +1,560 @@
+// Package expr implements the expression language of claim rules. It is the
+// server's own small language: its syntax and functions are modelled on CEL,
+// but it is not CEL and has no static types, protobuf values or CEL's
+// semantics for errors and overflow. Expressions are compiled once, which
+// checks their syntax, nesting depth, variables and functions, and are then
+// evaluated against JSON values: nil, bool, float64, string, []interface{}
+// and map[string]interface{}.
+package expr
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// functions maps the global functions to their number of arguments
+var functions = map[string]int{"size": 1, "string": 1}
+
+// methods maps the receiver-style functions to their number of arguments
+var methods = map[string]int{
+	"startsWith": 1, "endsWith": 1, "contains": 1, "matches": 1,
+	"lowerAscii": 0, "upperAscii": 0, "size": 0, "split": 1, "join": 1,
+}
+
+// macros are the receiver-style functions that bind a variable to each
+// element of a list, such as groups.exists(g, g == "admins")
+var macros = map[string]bool{"exists": true, "all": true, "filter": true, "map": true}
+
+// maxDepth limits how deeply expressions nest, counting brackets, unary
+// operators, chained binary operators and field selections, so that neither
+// the parser nor the evaluator recurses without bound on large rule bodies
+const maxDepth = 100
+
+// Program is a compiled expression
+type Program struct {
+	source string
+	root   node
+}
+
+// Compile parses an expression that may refer to the given variables. It
+// reports syntax errors, undeclared variables, unknown functions, wrong
+// argument counts, invalid regular expression literals and expressions nested
+// deeper than maxDepth.
+func Compile(source string, variables ...string) (*Program, error) {
+	tokens, err := lex(source)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 1 {
+		return nil, fmt.Errorf("empty expression")
+	}
+
+	p := &parser{tokens: tokens, variables: make(map[string]int)}
+	for _, variable := range variables {
+		p.variables[variable]++
+	}
+	root, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, p.unexpected(t)
+	}
+	return &Program{source: source, root: root}, nil
+}
+
+// String returns the source of the expression
+func (p *Program) String() string {
+	return p.source
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenNumber
+	tokenString
+	tokenPunct
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value interface{} // value of number and string literals
+	pos   int
+}
+
+// twoCharPuncts are the operators lexed before single-character punctuation
+var twoCharPuncts = []string{"==", "!=", "<=", ">=", "&&", "||"}
+
+// lex splits an expression into tokens, ending with a tokenEOF
+func lex(source string) ([]token, error) {
+	var tokens []token
+	for pos := 0; pos < len(source); {
+		r, width := utf8.DecodeRuneInString(source[pos:])
+		switch {
+		case unicode.IsSpace(r):
+			pos += width
+		case r == '_' || unicode.IsLetter(r):
+			start := pos
+			for pos < len(source) {
+				r, width := utf8.DecodeRuneInString(source[pos:])
+				if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+					break
+				}
+				pos += width
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: source[start:pos], pos: start})
+		case r >= '0' && r <= '9':
+			start := pos
+			for pos < len(source) && (isDigit(source[pos]) || source[pos] == '.') {
+				pos++
+			}
+			if pos < len(source) && (source[pos] == 'e' || source[pos] == 'E') {
+				pos++
+				if pos < len(source) && (source[pos] == '+' || source[pos] == '-') {
+					pos++
+				}
+				for pos < len(source) && isDigit(source[pos]) {
+					pos++
+				}
+			}
+			value, err := strconv.ParseFloat(source[start:pos], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q at position %d", source[start:pos], start)
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: source[start:pos], value: value, pos: start})
+		case r == '"' || r == '\'':
+			value, end, err := lexString(source, pos)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokenString, text: source[pos:end], value: value, pos: pos})
+			pos = end
+		default:
+			text := ""
+			for _, punct := range twoCharPuncts {
+				if strings.HasPrefix(source[pos:], punct) {
+					text = punct
+					break
+				}
+			}
+			if text == "" && strings.ContainsRune("()[]{}.,:?!-+*/%<>", r) {
+				text = string(r)
+			}
+			if text == "" {
+				return nil, fmt.Errorf("unexpected character %q at position %d", r, pos)
+			}
+			tokens = append(tokens, token{kind: tokenPunct, text: text, pos: pos})
+			pos += len(text)
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(source)}), nil
+}
+
+// lexString reads the quoted string literal starting at start and returns its
+// value and the position after the closing quote
+func lexString(source string, start int) (string, int, error) {
+	quote := source[start]
+	var value strings.Builder
+	for pos := start + 1; pos < len(source); pos++ {
+		c := source[pos]
+		switch {
+		case c == quote:
+			return value.String(), pos + 1, nil
+		case c == '\\' && pos+1 < len(source):
+			pos++
+			switch source[pos] {
+			case 'n':
+				value.WriteByte('\n')
+			case 't':
+				value.WriteByte('\t')
+			case 'r':
+				value.WriteByte('\r')
+			case '\\', '"', '\'':
+				value.WriteByte(source[pos])
+			default:
+				return "", 0, fmt.Errorf("invalid escape \\%c at position %d", source[pos], pos-1)
+			}
+		default:
+			value.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string at position %d", start)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// parser is a recursive descent parser following CEL's operator precedence
+type parser struct {
+	tokens    []token
+	pos       int
+	variables map[string]int // declared and macro-bound variables in scope
+	depth     int            // nesting depth of the node being parsed
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it is one of the operators
+func (p *parser) accept(operators ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != tokenPunct && !(t.kind == tokenIdent && t.text == "in") {
+		return "", false
+	}
+	for _, operator := range operators {
+		if t.text == operator {
+			p.pos++
+			return operator, true
+		}
+	}
+	return "", false
+}
+
+func (p *parser) expect(punct string) error {
+	if _, ok := p.accept(punct); !ok {
+		return fmt.Errorf("expected %q at position %d", punct, p.peek().pos)
+	}
+	return nil
+}
+
+// nest enters one more level of nesting. Callers restore p.depth when they
+// return.
+func (p *parser) nest() error {
+	p.depth++
+	if p.depth > maxDepth {
+		return fmt.Errorf("expression is nested more than %d levels deep at position %d", maxDepth, p.peek().pos)
+	}
+	return nil
+}
+
+func (p *parser) unexpected(t token) error {
+	if t.kind == tokenEOF {
+		return fmt.Errorf("unexpected end of expression")
+	}
+	return fmt.Errorf("unexpected %q at position %d", t.text, t.pos)
+}
+
+// parseExpr parses a conditional expression: or ? or : expr
+func (p *parser) parseExpr() (node, error) {
+	defer func(depth int) { p.depth = depth }(p.depth)
+	if err := p.nest(); err != nil {
+		return nil, err
+	}
+	condition, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := p.accept("?"); !ok {
+		return condition, nil
+	}
+	then, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	otherwise, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	return &conditionalNode{condition: condition, then: then, otherwise: otherwise}, nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	return p.parseBinary(p.parseAnd, "||")
+}
+
+func (p *parser) parseAnd() (node, error) {
+	return p.parseBinary(p.parseRelation, "&&")
+}
+
+func (p *parser) parseRelation() (node, error) {
+	return p.parseBinary(p.parseAddition, "==", "!=", "<", "<=", ">", ">=", "in")
+}
+
+func (p *parser) parseAddition() (node, error) {
+	return p.parseBinary(p.parseMultiplication, "+", "-")
+}
+
+func (p *parser) parseMultiplication() (node, error) {
+	return p.parseBinary(p.parseUnary, "*", "/", "%")
+}
+
+// parseBinary parses left-associative binary operators of one precedence level
+func (p *parser) parseBinary(operand func() (node, error), operators ...string) (node, error) {
+	defer func(depth int) { p.depth = depth }(p.depth)
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		operator, ok := p.accept(operators...)
+		if !ok {
+			return left, nil
+		}
+		if err := p.nest(); err != nil {
+			return nil, err
+		}
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{operator: operator, left: left, right: right}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if operator, ok := p.accept("!", "-"); ok {
+		defer func(depth int) { p.depth = depth }(p.depth)
+		if err := p.nest(); err != nil {
+			return nil, err
+		}
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{operator: operator, operand: operand}, nil
+	}
+	return p.parseMember()
+}
+
+// parseMember parses field selections, indexes and method calls
+func (p *parser) parseMember() (node, error) {
+	defer func(depth int) { p.depth = depth }(p.depth)
+	operand, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if p.peek().text == "." || p.peek().text == "[" {
+			if err := p.nest(); err != nil {
+				return nil, err
+			}
+		}
+		if _, ok := p.accept("."); ok {
+			name := p.next()
+			if name.kind != tokenIdent {
+				return nil, p.unexpected(name)
+			}
+			if _, ok := p.accept("("); !ok {
+				operand = &selectNode{operand: operand, field: name.text}
+				continue
+			}
+			if operand, err = p.parseMethod(operand, name); err != nil {
+				return nil, err
+			}
+		} else if _, ok := p.accept("["); ok {
+			index, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			operand = &indexNode{operand: operand, index: index}
+		} else {
+			return operand, nil
+		}
+	}
+}
+
+// parseMethod parses the arguments of a method or macro call on target. The
+// opening parenthesis has been consumed.
+func (p *parser) parseMethod(target node, name token) (node, error) {
+	if macros[name.text] {
+		variable := p.next()
+		if variable.kind != tokenIdent {
+			return nil, fmt.Errorf("%s() needs a variable name at position %d", name.text, variable.pos)
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+		p.variables[variable.text]++
+		body, err := p.parseExpr()
+		p.variables[variable.text]--
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return &macroNode{macro: name.text, target: target, variable: variable.text, body: body}, nil
+	}
+
+	arity, exists := methods[name.text]
+	if !exists {
+		return nil, fmt.Errorf("unknown function %q at position %d", name.text, name.pos)
+	}
+	args, err := p.parseArgs()
+	if err != nil {
+		return nil, err
+	}
+	if len(args) != arity {
+		return nil, fmt.Errorf("%s() takes %d arguments, got %d at position %d", name.text, arity, len(args), name.pos)
+	}
+
+	// Literal patterns are compiled once, so that invalid ones fail to compile
+	call := &callNode{function: name.text, target: target, args: args}
+	if name.text != "matches" {
+		return call, nil
+	}
+	if pattern, ok := args[0].(*literalNode); ok {
+		source, isString := pattern.value.(string)
+		if !isString {
+			return nil, fmt.Errorf("matches() needs a string pattern at position %d", name.pos)
+		}
+		re, err := regexp.Compile(source)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern at position %d: %w", name.pos, err)
+		}
+		call.pattern = re
+	}
+	return call, nil
+}
+
+// parseArgs parses a comma-separated argument list up to the closing parenthesis
+func (p *parser) parseArgs() ([]node, error) {
+	var args []node
+	if _, ok := p.accept(")"); ok {
+		return args, nil
+	}
+	for {
+		arg, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if _, ok := p.accept(","); !ok {
+			return args, p.expect(")")
+		}
+	}
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenNumber, tokenString:
+		return &literalNode{value: t.value}, nil
+	case tokenIdent:
+		switch t.text {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		case "null":
+			return &literalNode{value: nil}, nil
+		}
+		if _, ok := p.accept("("); ok {
+			return p.parseFunction(t)
+		}
+		if p.variables[t.text] == 0 {
+			return nil, fmt.Errorf("undeclared variable %q at position %d", t.text, t.pos)
+		}
+		return &identNode{name: t.text}, nil
+	case tokenPunct:
+		switch t.text {
+		case "(":
+			inner, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			return inner, p.expect(")")
+		case "[":
+			return p.parseList()
+		case "{":
+			return p.parseMap()
+		}
+	}
+	return nil, p.unexpected(t)
+}
+
+// parseFunction parses a global function call. The opening parenthesis has
+// been consumed.
+func (p *parser) parseFunction(name token) (node, error) {
+	if name.text == "has" {
+		arg, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		field, ok := arg.(*selectNode)
+		if !ok {
+			return nil, fmt.Errorf("has() needs a field selection such as user.groups at position %d", name.pos)
+		}
+		return &hasNode{field: field}, p.expect(")")
+	}
+
+	arity, exists := functions[name.text]
+	if !exists {
+		return nil, fmt.Errorf("unknown function %q at position %d", name.text, name.pos)
+	}
+	args, err := p.parseArgs()
+	if err != nil {
+		return nil, err
+	}
+	if len(args) != arity {
+		return nil, fmt.Errorf("%s() takes %d arguments, got %d at position %d", name.text, arity, len(args), name.pos)
+	}
+	return &callNode{function: name.text, args: args}, nil
+}
+
+// parseList parses a list literal. The opening bracket has been consumed.
+func (p *parser) parseList() (node, error) {
+	list := &listNode{}
+	if _, ok := p.accept("]"); ok {
+		return list, nil
+	}
+	for {
+		element, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		list.elements = append(list.elements, element)
+		if _, ok := p.accept(","); !ok {
+			return list, p.expect("]")
+		}
+	}
+}
+
+// parseMap parses a map literal. The opening brace has been consumed.
+func (p *parser) parseMap() (node, error) {
+	m := &mapNode{}
+	if _, ok := p.accept("}"); ok {
+		return m, nil
+	}
+	for {
+		key, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		value, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		m.keys = append(m.keys, key)
+		m.values = append(m.values, value)
+		if _, ok := p.accept(","); !ok {
+			return m, p.expect("}")
+		}
+	}
+}
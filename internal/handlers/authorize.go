@@ -18,17 +18,39 @@ type AuthorizeHandler struct {
 }
 
 func (h *AuthorizeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	clientID := r.URL.Query().Get("client_id")
-	redirectURI := r.URL.Query().Get("redirect_uri")
-	scope := r.URL.Query().Get("scope")
-	responseType := r.URL.Query().Get("response_type")
-	state := r.URL.Query().Get("state")
+	// Authorization requests may be sent as a form, and the interactive login
+	// page posts the original parameters back as form fields
+	if r.Method == http.MethodPost {
+		r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // limit request body to 1MB
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	clientID := r.FormValue("client_id")
+	redirectURI := r.FormValue("redirect_uri")
+	scope := r.FormValue("scope")
+	responseType := r.FormValue("response_type")
+	state := r.FormValue("state")
 
 	if clientID == "" || redirectURI == "" || scope == "" || responseType != "code" {
 		http.Error(w, "Invalid request parameters", http.StatusBadRequest)
 		return
 	}
 
+	// An unknown client or unregistered redirect URI is reported to the user
+	// instead of redirecting (RFC 6749 section 4.1.2.1)
+	client, registered := h.Store.GetClient(clientID)
+	if !registered && h.Store.GetSettings().RequireRegisteredClients {
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client", "The OAuth client was not found")
+		return
+	}
+	if registered && !client.AllowsRedirectURI(redirectURI) {
+		writeOAuthError(w, http.StatusBadRequest, "redirect_uri_mismatch", "The redirect URI is not registered for this client")
+		return
+	}
+
 	// Check if there's an error scenario configured for the authorize endpoint.
 	// If an error scenario is configured and enabled for this endpoint, we return
 	// an OAuth2 error response by redirecting to the redirect_uri with error parameters
@@ -39,25 +61,96 @@ func (h *AuthorizeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	//   - error_description: Human-readable error description (optional)
 	//   - state: The state parameter from the original request (if provided)
 	if errorScenario, exists := h.Store.GetErrorScenario("authorize"); exists {
-		// Redirect to the provided redirect URI with error parameters
-		redirectURL, err := url.Parse(redirectURI)
-		if err != nil {
-			http.Error(w, "Invalid redirect URI", http.StatusBadRequest)
+		log.Printf("Returning error redirect for authorize endpoint: error=%s, description=%s", errorScenario.ErrorCode, errorScenario.Description)
+		redirectWithError(w, r, redirectURI, state, errorScenario.ErrorCode, errorScenario.Description)
+		return
+	}
+
+	// Validate the PKCE parameters (RFC 7636 section 4.3). Plain is the default method.
+	codeChallenge := r.FormValue("code_challenge")
+	codeChallengeMethod := r.FormValue("code_challenge_method")
+	if codeChallenge != "" {
+		if codeChallengeMethod == "" {
+			codeChallengeMethod = pkceMethodPlain
+		}
+		if codeChallengeMethod != pkceMethodS256 && codeChallengeMethod != pkceMethodPlain {
+			redirectWithError(w, r, redirectURI, state, "invalid_request", "Unsupported code_challenge_method")
 			return
 		}
+	} else if codeChallengeMethod != "" {
+		redirectWithError(w, r, redirectURI, state, "invalid_request", "code_challenge_method requires code_challenge")
+		return
+	} else if registered && client.IsPublic() && h.Store.GetSettings().RequirePKCE {
+		// RFC 7636 section 4.4.1. Unregistered clients are only known to be
+		// public at /token, where they send no client_secret.
+		redirectWithError(w, r, redirectURI, state, "invalid_request", "PKCE is required for public clients")
+		return
+	}
+
+	// The claims parameter requests individual claims (OIDC Core section 5.5)
+	claimsRequest, err := models.ParseClaimsRequest(r.FormValue("claims"))
+	if err != nil {
+		redirectWithError(w, r, redirectURI, state, "invalid_request", "Invalid claims parameter")
+		return
+	}
+
+	if registered && !client.AllowsGrantType("authorization_code") {
+		redirectWithError(w, r, redirectURI, state, "unauthorized_client", "The client is not allowed to use the authorization code grant")
+		return
+	}
+	if registered && !client.AllowsScope(scope) {
+		redirectWithError(w, r, redirectURI, state, "invalid_scope", "Requested scope is not allowed for this client")
+		return
+	}
 
-		query := redirectURL.Query()
-		query.Set("error", errorScenario.ErrorCode)
-		if errorScenario.Description != "" {
-			query.Set("error_description", errorScenario.Description)
+	// Bind the code to the registered user named by login_hint (sub or email).
+	// Without a match the identity is derived from the client ID as before.
+	var userID string
+	if user, exists := h.Store.FindUser(r.FormValue("login_hint")); exists {
+		userID = user.Sub
+	}
+
+	// In interactive mode the tester picks the user on the login page first,
+	// then optionally grants a subset of the scopes on the consent screen
+	consentSubmitted := r.Method == http.MethodPost && r.FormValue("step") == consentStep
+	switch {
+	case r.Method == http.MethodPost && r.FormValue("action") == loginActionDeny:
+		redirectWithError(w, r, redirectURI, state, "access_denied", "The user denied the request")
+		return
+	case consentSubmitted:
+		// The user was chosen before the consent screen was shown. An empty
+		// user_id keeps the identity derived from the client ID.
+		userID = r.FormValue("user_id")
+		if userID != "" {
+			user, exists := h.Store.GetUser(userID)
+			if !exists {
+				http.Error(w, "Unknown user", http.StatusBadRequest)
+				return
+			}
+			userID = user.Sub
 		}
-		if state != "" {
-			query.Set("state", state)
+	case loginSubmitted(r):
+		var ok bool
+		if userID, ok = loginUser(h.Store, r); !ok {
+			http.Error(w, "Select a registered user or enter an email address", http.StatusBadRequest)
+			return
 		}
-		redirectURL.RawQuery = query.Encode()
+	case interactiveLogin(r):
+		renderLoginPage(w, r, h.Store, userID)
+		return
+	}
 
-		log.Printf("Returning error redirect for authorize endpoint: error=%s, description=%s", errorScenario.ErrorCode, errorScenario.Description)
-		http.Redirect(w, r, redirectURL.String(), http.StatusFound)
+	// Pre-set consent decisions take precedence over the consent screen
+	if consentSubmitted {
+		scope = (&models.Consent{Scopes: r.Form["granted_scope"]}).Grant(scope)
+	} else if consent, exists := h.Store.GetConsent(clientID, userID); exists {
+		scope = consent.Grant(scope)
+	} else if interactiveConsent(r) {
+		renderConsentPage(w, r, userID)
+		return
+	}
+	if scope == "" {
+		redirectWithError(w, r, redirectURI, state, "access_denied", "The user granted none of the requested scopes")
 		return
 	}
 
@@ -71,6 +164,13 @@ func (h *AuthorizeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		RedirectURI: redirectURI,
 		Scope:       scope,
 		Expiration:  expiration,
+		UserID:      userID,
+
+		CodeChallenge:       codeChallenge,
+		CodeChallengeMethod: codeChallengeMethod,
+		Nonce:               r.FormValue("nonce"),
+		AuthTime:            time.Now(),
+		Claims:              claimsRequest,
 	})
 
 	// Redirect to the provided redirect URI with the authorization code
@@ -93,3 +193,25 @@ func (h *AuthorizeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	http.Redirect(w, r, redirectURL.String(), http.StatusFound)
 }
+
+// redirectWithError sends the user agent back to the client with an OAuth2 error
+// response as described in RFC 6749 section 4.1.2.1
+func redirectWithError(w http.ResponseWriter, r *http.Request, redirectURI, state, errorCode, description string) {
+	redirectURL, err := url.Parse(redirectURI)
+	if err != nil {
+		http.Error(w, "Invalid redirect URI", http.StatusBadRequest)
+		return
+	}
+
+	query := redirectURL.Query()
+	query.Set("error", errorCode)
+	if description != "" {
+		query.Set("error_description", description)
+	}
+	if state != "" {
+		query.Set("state", state)
+	}
+	redirectURL.RawQuery = query.Encode()
+
+	http.Redirect(w, r, redirectURL.String(), http.StatusFound)
+}
@@ -1,11 +1,14 @@
 package handlers
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"testing"
 
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/jwt"
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/models"
 	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/store"
 	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/types"
 )
@@ -408,3 +411,208 @@ func TestAuthorizeHandler_ErrorWithoutDescription(t *testing.T) {
 		t.Errorf("expected no error_description, got %q", errorDesc)
 	}
 }
+
+func TestAuthorizeHandler_PKCE(t *testing.T) {
+	baseParams := func() url.Values {
+		return url.Values{
+			"client_id":     {"test-client"},
+			"redirect_uri":  {"http://localhost/callback"},
+			"scope":         {"openid"},
+			"response_type": {"code"},
+			"state":         {"test-state"},
+		}
+	}
+
+	t.Run("Challenge is stored with the code", func(t *testing.T) {
+		memoryStore := store.NewMemoryStore()
+		handler := &AuthorizeHandler{Store: memoryStore}
+
+		params := baseParams()
+		params.Set("code_challenge", "test-challenge")
+		params.Set("code_challenge_method", "S256")
+		req := httptest.NewRequest(http.MethodGet, "/authorize?"+params.Encode(), nil)
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+
+		redirectURL, err := url.Parse(resp.Header().Get("Location"))
+		if err != nil {
+			t.Fatalf("Failed to parse redirect URL: %v", err)
+		}
+		authRequest, exists := memoryStore.GetAuthCode(redirectURL.Query().Get("code"))
+		if !exists {
+			t.Fatal("expected authorization code to be stored")
+		}
+		if authRequest.CodeChallenge != "test-challenge" || authRequest.CodeChallengeMethod != "S256" {
+			t.Errorf("expected PKCE challenge to be stored, got %+v", authRequest)
+		}
+	})
+
+	t.Run("Method defaults to plain", func(t *testing.T) {
+		memoryStore := store.NewMemoryStore()
+		handler := &AuthorizeHandler{Store: memoryStore}
+
+		params := baseParams()
+		params.Set("code_challenge", "test-challenge")
+		req := httptest.NewRequest(http.MethodGet, "/authorize?"+params.Encode(), nil)
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+
+		redirectURL, _ := url.Parse(resp.Header().Get("Location"))
+		authRequest, exists := memoryStore.GetAuthCode(redirectURL.Query().Get("code"))
+		if !exists || authRequest.CodeChallengeMethod != "plain" {
+			t.Errorf("expected plain challenge method, got %+v", authRequest)
+		}
+	})
+
+	t.Run("Unsupported method is rejected", func(t *testing.T) {
+		handler := &AuthorizeHandler{Store: store.NewMemoryStore()}
+
+		params := baseParams()
+		params.Set("code_challenge", "test-challenge")
+		params.Set("code_challenge_method", "S512")
+		req := httptest.NewRequest(http.MethodGet, "/authorize?"+params.Encode(), nil)
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+
+		redirectURL, _ := url.Parse(resp.Header().Get("Location"))
+		if redirectURL.Query().Get("error") != "invalid_request" {
+			t.Errorf("expected invalid_request error, got %q", redirectURL.Query().Get("error"))
+		}
+		if redirectURL.Query().Get("state") != "test-state" {
+			t.Errorf("expected state to be preserved")
+		}
+	})
+
+	t.Run("Required for public clients", func(t *testing.T) {
+		memoryStore := store.NewMemoryStore()
+		memoryStore.StoreSettings(types.Settings{RequirePKCE: true})
+		memoryStore.StoreClient(&models.Client{ClientID: "native-app", ClientType: models.ClientTypePublic, RedirectURIs: []string{"http://localhost/callback"}})
+		memoryStore.StoreClient(&models.Client{ClientID: "web-app", ClientSecret: "secret", RedirectURIs: []string{"http://localhost/callback"}})
+		handler := &AuthorizeHandler{Store: memoryStore}
+
+		tests := []struct {
+			name          string
+			clientID      string
+			codeChallenge string
+			expectedError string
+		}{
+			{"Public client without challenge", "native-app", "", "invalid_request"},
+			{"Public client with challenge", "native-app", "test-challenge", ""},
+			{"Confidential client without challenge", "web-app", "", ""},
+			{"Unregistered client without challenge", "test-client", "", ""},
+		}
+
+		for _, tt := range tests {
+			params := baseParams()
+			params.Set("client_id", tt.clientID)
+			if tt.codeChallenge != "" {
+				params.Set("code_challenge", tt.codeChallenge)
+			}
+			req := httptest.NewRequest(http.MethodGet, "/authorize?"+params.Encode(), nil)
+			resp := httptest.NewRecorder()
+			handler.ServeHTTP(resp, req)
+
+			redirectURL, _ := url.Parse(resp.Header().Get("Location"))
+			if errorCode := redirectURL.Query().Get("error"); errorCode != tt.expectedError {
+				t.Errorf("%s: expected error %q, got %q", tt.name, tt.expectedError, errorCode)
+			}
+			if tt.expectedError == "" && redirectURL.Query().Get("code") == "" {
+				t.Errorf("%s: expected an authorization code", tt.name)
+			}
+		}
+	})
+}
+
+func TestAuthorizeHandler_ClaimsParameter(t *testing.T) {
+	if err := jwt.InitKeys(); err != nil {
+		t.Fatalf("Failed to initialize keys: %v", err)
+	}
+
+	memoryStore := store.NewMemoryStore()
+	memoryStore.StoreUser(&models.UserInfo{
+		Sub:         "alice",
+		ID:          "alice",
+		Name:        "Alice",
+		Email:       "alice@example.com",
+		PhoneNumber: "+15551234567",
+		Locale:      "en",
+	})
+	handler := &AuthorizeHandler{Store: memoryStore}
+
+	authorize := func(claims string) *httptest.ResponseRecorder {
+		params := url.Values{
+			"client_id":     {"test-client"},
+			"redirect_uri":  {"http://example.com/callback"},
+			"scope":         {"openid"},
+			"response_type": {"code"},
+			"state":         {"test-state"},
+			"login_hint":    {"alice"},
+			"claims":        {claims},
+		}
+		req := httptest.NewRequest(http.MethodGet, "/authorize?"+params.Encode(), nil)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for _, claims := range []string{`not json`, `{"id_token":{"email":"yes"}}`} {
+		rr := authorize(claims)
+		location, _ := url.Parse(rr.Header().Get("Location"))
+		if rr.Code != http.StatusFound || location.Query().Get("error") != "invalid_request" {
+			t.Errorf("Expected an invalid_request redirect for %s, got %d %s", claims, rr.Code, location)
+		}
+	}
+
+	authRequest := redirectedCode(t, memoryStore, authorize(`{
+		"id_token": {"email": {"essential": true}, "acr": {"values": ["urn:mace:incommon:iap:silver"]}},
+		"userinfo": {"phone_number": null, "locale": {"value": "fr"}}
+	}`))
+	if authRequest.Claims == nil || authRequest.Claims.IDToken["email"] == nil || !authRequest.Claims.IDToken["email"].Essential {
+		t.Fatalf("Expected the claims request on the auth code, got %+v", authRequest.Claims)
+	}
+
+	memoryStore.StoreAuthCode("claims-code", authRequest)
+	rr := postTokenRequest(NewTokenHandler(memoryStore), url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {"claims-code"},
+		"client_id":    {"test-client"},
+		"redirect_uri": {"http://example.com/callback"},
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("code exchange failed: status %d, body %s", rr.Code, rr.Body.String())
+	}
+	var response models.TokenResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+
+	// The ID token carries the claims requested for it, not those for userinfo
+	idClaims, err := jwt.VerifyToken(response.IDToken)
+	if err != nil {
+		t.Fatalf("Failed to verify ID token: %v", err)
+	}
+	if idClaims["email"] != "alice@example.com" || idClaims["acr"] != "urn:mace:incommon:iap:silver" {
+		t.Errorf("Expected the requested email and acr in the ID token, got %v", idClaims)
+	}
+	if _, ok := idClaims["phone_number"]; ok {
+		t.Errorf("Expected no phone_number in the ID token, got %v", idClaims)
+	}
+
+	// Userinfo releases phone_number, but not locale, whose value does not match
+	req := httptest.NewRequest(http.MethodGet, "/userinfo", nil)
+	req.Header.Set("Authorization", "Bearer "+response.AccessToken)
+	rr = httptest.NewRecorder()
+	(&UserInfoHandler{Store: memoryStore}).ServeHTTP(rr, req)
+	var userInfo map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &userInfo); err != nil {
+		t.Fatalf("Failed to unmarshal userinfo: %v", err)
+	}
+	if userInfo["phone_number"] != "+15551234567" {
+		t.Errorf("Expected the requested phone_number in userinfo, got %v", userInfo)
+	}
+	for _, claim := range []string{"locale", "email"} {
+		if _, ok := userInfo[claim]; ok {
+			t.Errorf("Expected no %s in userinfo, got %v", claim, userInfo)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/jwt"
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/models"
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/store"
+)
+
+// clientCredentials extracts the client ID and secret from HTTP Basic
+// authentication or, failing that, from the request body
+func clientCredentials(r *http.Request) (string, string) {
+	if clientID, clientSecret, ok := r.BasicAuth(); ok {
+		// RFC 6749 section 2.3.1 requires both values to be form-urlencoded
+		// before they are placed in the header
+		if decoded, err := url.QueryUnescape(clientID); err == nil {
+			clientID = decoded
+		}
+		if decoded, err := url.QueryUnescape(clientSecret); err == nil {
+			clientSecret = decoded
+		}
+		return clientID, clientSecret
+	}
+	return r.FormValue("client_id"), r.FormValue("client_secret")
+}
+
+// authenticateClient verifies the credentials of a confidential client. Registered
+// clients must present one of their registered secrets or a client assertion,
+// and public clients are rejected. In open registration mode unregistered clients
+// are accepted with any non-empty secret. The registered client, if any, is returned.
+func authenticateClient(s store.Store, r *http.Request) (string, *models.Client, bool) {
+	clientID := requestClientID(r)
+	if clientID == "" {
+		return clientID, nil, false
+	}
+	client, ok := verifyRequestClient(s, r, clientID, true)
+	return clientID, client, ok
+}
+
+// identifyClient resolves the client making a request at an endpoint that
+// public clients may also use. Public clients identify themselves by client_id
+// alone, while registered confidential clients must authenticate.
+func identifyClient(s store.Store, r *http.Request) (string, *models.Client, bool) {
+	clientID := requestClientID(r)
+	if clientID == "" {
+		return clientID, nil, false
+	}
+	client, ok := verifyRequestClient(s, r, clientID, false)
+	return clientID, client, ok
+}
+
+// requestClientID returns the client ID a request names, from HTTP Basic
+// authentication, the client_id parameter or the issuer of a client assertion
+func requestClientID(r *http.Request) string {
+	clientID, _ := clientCredentials(r)
+	if clientID == "" && hasClientAssertion(r) {
+		clientID = jwt.UnverifiedAssertionIssuer(r.FormValue("client_assertion"))
+	}
+	return clientID
+}
+
+// verifyRequestClient authenticates the request as the given client, with a
+// client assertion if the request carries one and with a secret otherwise
+func verifyRequestClient(s store.Store, r *http.Request, clientID string, requireSecret bool) (*models.Client, bool) {
+	if hasClientAssertion(r) {
+		return verifyClientAssertion(s, r, clientID)
+	}
+
+	_, clientSecret := clientCredentials(r)
+	client, ok := verifyClient(s, clientID, clientSecret, requireSecret)
+	if ok && client != nil && !client.IsPublic() && !client.AllowsAuthMethod(secretAuthMethod(r)) {
+		return client, false
+	}
+	return client, ok
+}
+
+// verifyClient checks a client's credentials against the client registry.
+// requireSecret demands a confidential client that authenticates with a secret.
+func verifyClient(s store.Store, clientID, clientSecret string, requireSecret bool) (*models.Client, bool) {
+	client, registered := s.GetClient(clientID)
+	if !registered {
+		if s.GetSettings().RequireRegisteredClients {
+			return nil, false
+		}
+		return nil, !requireSecret || clientSecret != ""
+	}
+	if client.IsPublic() {
+		return client, !requireSecret
+	}
+	return client, client.HasSecret(clientSecret)
+}
+
+// secretAuthMethod names the way a request presents its client secret
+func secretAuthMethod(r *http.Request) string {
+	if _, _, ok := r.BasicAuth(); ok {
+		return "client_secret_basic"
+	}
+	return "client_secret_post"
+}
+
+// hasClientAssertion reports whether a request authenticates with a JWT
+// client assertion (RFC 7523 section 2.2)
+func hasClientAssertion(r *http.Request) bool {
+	return r.FormValue("client_assertion_type") != "" || r.FormValue("client_assertion") != ""
+}
+
+// verifyClientAssertion authenticates a registered confidential client by its
+// private_key_jwt or client_secret_jwt assertion. Each assertion is accepted once.
+func verifyClientAssertion(s store.Store, r *http.Request, clientID string) (*models.Client, bool) {
+	if r.FormValue("client_assertion_type") != jwt.ClientAssertionType {
+		return nil, false
+	}
+	// A client must not use more than one authentication method (RFC 6749 section 2.3)
+	if _, _, ok := r.BasicAuth(); ok || r.FormValue("client_secret") != "" {
+		return nil, false
+	}
+
+	client, registered := s.GetClient(clientID)
+	if !registered || client.IsPublic() {
+		return nil, false
+	}
+
+	keySet, err := clientKeySet(client)
+	if err != nil {
+		log.Printf("No usable keys for client %s: %v", sanitizeLog(clientID), err) // #nosec G706 -- sanitizeLog strips CR/LF to prevent log injection
+		return client, false
+	}
+
+	assertion, err := jwt.VerifyClientAssertion(r.FormValue("client_assertion"), jwt.ClientAssertionOptions{
+		ClientID:  clientID,
+		Audiences: assertionAudiences(r),
+		Keys:      keySet,
+		Secrets:   client.Secrets(),
+	})
+	if err != nil {
+		log.Printf("Rejected client assertion for client %s: %v", sanitizeLog(clientID), err) // #nosec G706 -- sanitizeLog strips CR/LF to prevent log injection
+		return client, false
+	}
+	if !client.AllowsAuthMethod(assertion.Method) {
+		return client, false
+	}
+	if !s.UseClientAssertionID(clientID, assertion.ID, assertion.ExpiresAt) {
+		log.Printf("Rejected replayed client assertion for client %s", sanitizeLog(clientID)) // #nosec G706 -- sanitizeLog strips CR/LF to prevent log injection
+		return client, false
+	}
+	return client, true
+}
+
+// jwksClient fetches client key sets from their jwks_uri
+var jwksClient = &http.Client{Timeout: 5 * time.Second}
+
+// clientKeySet returns the keys that verify a client's private_key_jwt
+// assertions, fetched from its jwks_uri when no jwks is registered
+func clientKeySet(client *models.Client) (*jwt.JSONWebKeySet, error) {
+	data := []byte(client.JWKS)
+	if len(data) == 0 && client.JWKSURI != "" {
+		resp, err := jwksClient.Get(client.JWKSURI) // #nosec G107 -- jwks_uri is registered client metadata
+		if err != nil {
+			return nil, fmt.Errorf("fetching jwks_uri: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("fetching jwks_uri: status %d", resp.StatusCode)
+		}
+		if data, err = io.ReadAll(io.LimitReader(resp.Body, 1<<20)); err != nil {
+			return nil, fmt.Errorf("reading jwks_uri: %w", err)
+		}
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	var keySet jwt.JSONWebKeySet
+	if err := json.Unmarshal(data, &keySet); err != nil {
+		return nil, fmt.Errorf("parsing JWK Set: %w", err)
+	}
+	return &keySet, nil
+}
+
+// assertionAudiences lists the aud values a client assertion may carry: the
+// issuer, the token endpoint or the endpoint receiving the assertion, all as
+// addressed by the request
+func assertionAudiences(r *http.Request) []string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	base := scheme + "://" + r.Host
+	return []string{base, base + "/", base + "/token", base + r.URL.Path}
+}
+
+// isPublicClient reports whether a client does not authenticate with a secret.
+// Unregistered clients count as public when they send no client_secret.
+func isPublicClient(client *models.Client, clientSecret string) bool {
+	if client != nil {
+		return client.IsPublic()
+	}
+	return clientSecret == ""
+}
+
+// allowsGrantType rejects a grant type that a registered client may not use
+// (RFC 6749 section 5.2)
+func allowsGrantType(w http.ResponseWriter, client *models.Client, grantType string) bool {
+	if client != nil && !client.AllowsGrantType(grantType) {
+		writeOAuthError(w, http.StatusBadRequest, "unauthorized_client", "The client is not allowed to use this grant type")
+		return false
+	}
+	return true
+}
+
+// writeInvalidClient rejects a request whose client authentication failed
+// (RFC 6749 section 5.2)
+func writeInvalidClient(w http.ResponseWriter, r *http.Request, description string) {
+	if r.Header.Get("Authorization") != "" {
+		w.Header().Set("WWW-Authenticate", `Basic realm="mock-oauth2-server"`)
+	}
+	writeOAuthError(w, http.StatusUnauthorized, "invalid_client", description)
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/jwt"
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/models"
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/store"
+	jwtlib "github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func TestTokenHandler_ClientAssertion(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwk, err := jwt.NewJSONWebKey(&privateKey.PublicKey, "service-key", "RS256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks, _ := json.Marshal(jwt.JSONWebKeySet{Keys: []jwt.JSONWebKey{jwk}})
+
+	memoryStore := store.NewMemoryStore()
+	memoryStore.StoreClient(&models.Client{ClientID: "key-service", JWKS: jwks, TokenEndpointAuthMethod: jwt.AuthMethodPrivateKeyJWT, ClientSecret: "unused-secret"})
+	memoryStore.StoreClient(&models.Client{ClientID: "secret-service", ClientSecret: "service-secret"})
+	tokenHandler := NewTokenHandler(memoryStore)
+	introspectionHandler := NewIntrospectionHandler(memoryStore, "http://localhost:8080")
+
+	claims := func(clientID, audience string) jwtlib.MapClaims {
+		return jwtlib.MapClaims{
+			"iss": clientID,
+			"sub": clientID,
+			"aud": audience,
+			"exp": time.Now().Add(time.Minute).Unix(),
+			"jti": uuid.New().String(),
+		}
+	}
+	signRS256 := func(claims jwtlib.MapClaims) string {
+		token := jwtlib.NewWithClaims(jwtlib.SigningMethodRS256, claims)
+		token.Header["kid"] = "service-key"
+		signed, err := token.SignedString(privateKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	signHS256 := func(claims jwtlib.MapClaims, secret string) string {
+		signed, err := jwtlib.NewWithClaims(jwtlib.SigningMethodHS256, claims).SignedString([]byte(secret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	assertionForm := func(assertion string) url.Values {
+		return url.Values{
+			"grant_type":            {"client_credentials"},
+			"client_assertion_type": {jwt.ClientAssertionType},
+			"client_assertion":      {assertion},
+		}
+	}
+
+	replayed := signRS256(claims("key-service", "http://example.com/token"))
+	withClientID := assertionForm(signRS256(claims("key-service", "http://example.com/token")))
+	withClientID.Set("client_id", "key-service")
+	wrongClientID := assertionForm(signRS256(claims("key-service", "http://example.com/token")))
+	wrongClientID.Set("client_id", "secret-service")
+	wrongType := assertionForm(signRS256(claims("key-service", "http://example.com/token")))
+	wrongType.Set("client_assertion_type", "urn:example:other")
+
+	tests := []struct {
+		name           string
+		form           url.Values
+		expectedStatus int
+		expectedSub    string
+	}{
+		{"private_key_jwt", assertionForm(replayed), http.StatusOK, "key-service"},
+		{"Replayed assertion", assertionForm(replayed), http.StatusUnauthorized, ""},
+		{"private_key_jwt with client_id", withClientID, http.StatusOK, "key-service"},
+		{"client_id differs from the assertion", wrongClientID, http.StatusUnauthorized, ""},
+		{"Issuer as audience", assertionForm(signRS256(claims("key-service", "http://example.com"))), http.StatusOK, "key-service"},
+		{"Wrong audience", assertionForm(signRS256(claims("key-service", "http://other.example.com/token"))), http.StatusUnauthorized, ""},
+		{"Wrong assertion type", wrongType, http.StatusUnauthorized, ""},
+		{"client_secret_jwt", assertionForm(signHS256(claims("secret-service", "http://example.com/token"), "service-secret")), http.StatusOK, "secret-service"},
+		{"client_secret_jwt with wrong secret", assertionForm(signHS256(claims("secret-service", "http://example.com/token"), "wrong")), http.StatusUnauthorized, ""},
+		{"Method not registered for the client", assertionForm(signHS256(claims("key-service", "http://example.com/token"), "unused-secret")), http.StatusUnauthorized, ""},
+		{"Secret for a private_key_jwt client", url.Values{"grant_type": {"client_credentials"}, "client_id": {"key-service"}, "client_secret": {"unused-secret"}}, http.StatusUnauthorized, ""},
+		{"Unregistered client", assertionForm(signHS256(claims("other-service", "http://example.com/token"), "any")), http.StatusUnauthorized, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := postTokenRequest(tokenHandler, tt.form)
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if tt.expectedStatus != http.StatusOK {
+				if errorCode := decodeOAuthError(t, rr); errorCode != "invalid_client" {
+					t.Errorf("expected error invalid_client, got %q", errorCode)
+				}
+				return
+			}
+
+			var response models.TokenResponse
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("Error decoding response: %v", err)
+			}
+			tokenClaims, err := jwt.VerifyToken(response.AccessToken)
+			if err != nil {
+				t.Fatalf("Error verifying access token: %v", err)
+			}
+			if tokenClaims["sub"] != tt.expectedSub {
+				t.Errorf("expected sub %s, got %v", tt.expectedSub, tokenClaims["sub"])
+			}
+		})
+	}
+
+	t.Run("Introspection", func(t *testing.T) {
+		form := url.Values{
+			"token":                 {"unknown-token"},
+			"client_assertion_type": {jwt.ClientAssertionType},
+			"client_assertion":      {signRS256(claims("key-service", "http://example.com/introspect"))},
+		}
+		req := httptest.NewRequest(http.MethodPost, "/introspect", strings.NewReader(form.Encode()))
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		introspectionHandler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Errorf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+	})
+}
+
+func TestTokenHandler_ClientAssertionWithJWKSURI(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwk, err := jwt.NewJSONWebKey(&privateKey.PublicKey, "", "RS256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwksServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, jwt.JSONWebKeySet{Keys: []jwt.JSONWebKey{jwk}})
+	}))
+	defer jwksServer.Close()
+
+	memoryStore := store.NewMemoryStore()
+	memoryStore.StoreClient(&models.Client{ClientID: "uri-service", JWKSURI: jwksServer.URL, TokenEndpointAuthMethod: jwt.AuthMethodPrivateKeyJWT})
+	handler := NewTokenHandler(memoryStore)
+
+	assertion, err := jwtlib.NewWithClaims(jwtlib.SigningMethodRS256, jwtlib.MapClaims{
+		"iss": "uri-service",
+		"sub": "uri-service",
+		"aud": "http://example.com/token",
+		"exp": time.Now().Add(time.Minute).Unix(),
+		"jti": uuid.New().String(),
+	}).SignedString(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := postTokenRequest(handler, url.Values{
+		"grant_type":            {"client_credentials"},
+		"client_assertion_type": {jwt.ClientAssertionType},
+		"client_assertion":      {assertion},
+	})
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+}
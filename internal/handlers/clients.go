@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/models"
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/store"
+)
+
+// clientsPath is the admin API collection path for the client registry
+const clientsPath = "/admin/clients"
+
+// ClientsHandler manages the client registry through the admin API.
+//
+//	GET    /admin/clients       lists registered clients
+//	POST   /admin/clients       creates or replaces a client (client_id is required)
+//	GET    /admin/clients/{id}  returns a single client
+//	DELETE /admin/clients/{id}  removes a client
+type ClientsHandler struct {
+	store store.Store
+}
+
+// NewClientsHandler creates a new ClientsHandler
+func NewClientsHandler(store store.Store) *ClientsHandler {
+	return &ClientsHandler{store: store}
+}
+
+// ServeHTTP dispatches admin client requests by method and path
+func (h *ClientsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	clientID := strings.Trim(strings.TrimPrefix(r.URL.Path, clientsPath), "/")
+
+	switch {
+	case clientID == "" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, h.store.ListClients())
+	case clientID == "" && r.Method == http.MethodPost:
+		h.storeClient(w, r)
+	case clientID != "" && r.Method == http.MethodGet:
+		client, exists := h.store.GetClient(clientID)
+		if !exists {
+			http.Error(w, "Client not found", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, client)
+	case clientID != "" && r.Method == http.MethodDelete:
+		if !h.store.RemoveClient(clientID) {
+			http.Error(w, "Client not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Removed client %s", sanitizeLog(clientID)) // #nosec G706 -- sanitizeLog strips CR/LF to prevent log injection
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// storeClient decodes a client from the request body and registers it
+func (h *ClientsHandler) storeClient(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20)) // limit request body to 1MB
+	if err != nil {
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	var client models.Client
+	if err := json.Unmarshal(body, &client); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := client.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.store.StoreClient(&client)
+	log.Printf("Registered client %s", sanitizeLog(client.ClientID)) // #nosec G706 -- sanitizeLog strips CR/LF to prevent log injection
+
+	writeJSON(w, http.StatusCreated, &client)
+}
@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/models"
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/store"
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/types"
+)
+
+func TestClientsHandler(t *testing.T) {
+	memoryStore := store.NewMemoryStore()
+	handler := NewClientsHandler(memoryStore)
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for _, body := range []string{
+		`{"client_secret":"secret"}`,
+		`{"client_id":"bad-type","client_type":"trusted"}`,
+		`{"client_id":"no-secret","client_type":"confidential"}`,
+		`{"client_id":"no-redirect","client_secret":"secret"}`,
+		`not json`,
+	} {
+		if rr := serve(http.MethodPost, "/admin/clients", body); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d for %s, got %d", http.StatusBadRequest, body, rr.Code)
+		}
+	}
+
+	rr := serve(http.MethodPost, "/admin/clients", `{"client_id":"web-app","client_secret":"secret","redirect_uris":["https://app.example.com/callback"]}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	serve(http.MethodPost, "/admin/clients", `{"client_id":"native-app","client_type":"public","redirect_uris":["http://127.0.0.1/callback"]}`)
+
+	rr = serve(http.MethodGet, "/admin/clients", "")
+	var clients []models.Client
+	if err := json.NewDecoder(rr.Body).Decode(&clients); err != nil {
+		t.Fatalf("Error decoding clients: %v", err)
+	}
+	if len(clients) != 2 || clients[0].ClientID != "native-app" || clients[1].ClientID != "web-app" {
+		t.Fatalf("expected clients native-app and web-app, got %+v", clients)
+	}
+
+	rr = serve(http.MethodGet, "/admin/clients/web-app", "")
+	var webApp models.Client
+	if err := json.NewDecoder(rr.Body).Decode(&webApp); err != nil {
+		t.Fatalf("Error decoding client: %v", err)
+	}
+	if len(webApp.RedirectURIs) != 1 || webApp.RedirectURIs[0] != "https://app.example.com/callback" {
+		t.Errorf("unexpected client %+v", webApp)
+	}
+
+	if rr := serve(http.MethodDelete, "/admin/clients/web-app", ""); rr.Code != http.StatusNoContent {
+		t.Errorf("expected status %d, got %d", http.StatusNoContent, rr.Code)
+	}
+	if rr := serve(http.MethodGet, "/admin/clients/web-app", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("expected status %d after delete, got %d", http.StatusNotFound, rr.Code)
+	}
+	if rr := serve(http.MethodDelete, "/admin/clients/web-app", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("expected status %d for unknown client, got %d", http.StatusNotFound, rr.Code)
+	}
+	if rr := serve(http.MethodPut, "/admin/clients", ""); rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status %d, got %d", http.StatusMethodNotAllowed, rr.Code)
+	}
+}
+
+func TestAuthorizeHandler_ClientRegistry(t *testing.T) {
+	memoryStore := store.NewMemoryStore()
+	memoryStore.StoreClient(&models.Client{
+		ClientID:      "web-app",
+		ClientSecret:  "secret",
+		RedirectURIs:  []string{"https://app.example.com/callback", "http://127.0.0.1/callback"},
+		AllowedScopes: []string{"openid", "email"},
+	})
+	memoryStore.StoreClient(&models.Client{
+		ClientID:     "service",
+		ClientSecret: "secret",
+		RedirectURIs: []string{"https://app.example.com/callback"},
+		GrantTypes:   []string{"client_credentials"},
+	})
+	handler := &AuthorizeHandler{Store: memoryStore}
+
+	tests := []struct {
+		name             string
+		clientID         string
+		redirectURI      string
+		scope            string
+		strict           bool
+		expectedStatus   int
+		expectedError    string
+		expectedRedirect bool
+	}{
+		{"Registered redirect URI", "web-app", "https://app.example.com/callback", "openid", false, http.StatusFound, "", true},
+		{"Loopback redirect URI on any port", "web-app", "http://127.0.0.1:49152/callback", "openid", false, http.StatusFound, "", true},
+		{"Unregistered redirect URI", "web-app", "https://evil.example.com/callback", "openid", false, http.StatusBadRequest, "redirect_uri_mismatch", false},
+		{"Scope outside the allowed scopes", "web-app", "https://app.example.com/callback", "openid admin", false, http.StatusFound, "invalid_scope", true},
+		{"Grant type not allowed", "service", "https://app.example.com/callback", "openid", false, http.StatusFound, "unauthorized_client", true},
+		{"Unknown client in open mode", "other-app", "https://other.example.com/callback", "openid", false, http.StatusFound, "", true},
+		{"Unknown client in strict mode", "other-app", "https://other.example.com/callback", "openid", true, http.StatusUnauthorized, "invalid_client", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memoryStore.StoreSettings(types.Settings{RequireRegisteredClients: tt.strict})
+			params := url.Values{
+				"client_id":     {tt.clientID},
+				"redirect_uri":  {tt.redirectURI},
+				"scope":         {tt.scope},
+				"response_type": {"code"},
+				"state":         {"test-state"},
+			}
+			req := httptest.NewRequest(http.MethodGet, "/authorize?"+params.Encode(), nil)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if !tt.expectedRedirect {
+				if errorCode := decodeOAuthError(t, rr); errorCode != tt.expectedError {
+					t.Errorf("expected error %q, got %q", tt.expectedError, errorCode)
+				}
+				return
+			}
+
+			location, err := url.Parse(rr.Header().Get("Location"))
+			if err != nil {
+				t.Fatalf("Error parsing redirect: %v", err)
+			}
+			if location.Query().Get("error") != tt.expectedError {
+				t.Errorf("expected error %q in redirect, got %s", tt.expectedError, location)
+			}
+		})
+	}
+}
+
+func TestTokenHandler_ClientRegistry(t *testing.T) {
+	memoryStore := store.NewMemoryStore()
+	memoryStore.StoreClient(&models.Client{
+		ClientID:      "web-app",
+		ClientSecret:  "new-secret",
+		ClientSecrets: []string{"old-secret"},
+		RedirectURIs:  []string{"http://example.com/callback"},
+		GrantTypes:    []string{"authorization_code"},
+	})
+	memoryStore.StoreClient(&models.Client{ClientID: "native-app", ClientType: models.ClientTypePublic})
+	handler := NewTokenHandler(memoryStore)
+
+	tests := []struct {
+		name           string
+		clientID       string
+		secret         string
+		strict         bool
+		expectedStatus int
+		expectedError  string
+	}{
+		{"Primary secret", "web-app", "new-secret", false, http.StatusOK, ""},
+		{"Rotated secret", "web-app", "old-secret", false, http.StatusOK, ""},
+		{"Wrong secret", "web-app", "wrong", false, http.StatusUnauthorized, "invalid_client"},
+		{"Missing secret", "web-app", "", false, http.StatusUnauthorized, "invalid_client"},
+		{"Public client without secret", "native-app", "", false, http.StatusOK, ""},
+		{"Unknown client in open mode", "other-app", "", false, http.StatusOK, ""},
+		{"Unknown client in strict mode", "other-app", "", true, http.StatusUnauthorized, "invalid_client"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memoryStore.StoreSettings(types.Settings{RequireRegisteredClients: tt.strict})
+			memoryStore.StoreAuthCode("registry-code", &models.AuthRequest{
+				ClientID:    tt.clientID,
+				RedirectURI: "http://example.com/callback",
+				Scope:       "openid",
+			})
+
+			form := url.Values{
+				"grant_type":   {"authorization_code"},
+				"code":         {"registry-code"},
+				"client_id":    {tt.clientID},
+				"redirect_uri": {"http://example.com/callback"},
+			}
+			if tt.secret != "" {
+				form.Set("client_secret", tt.secret)
+			}
+			rr := postTokenRequest(handler, form)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if tt.expectedError != "" {
+				if errorCode := decodeOAuthError(t, rr); errorCode != tt.expectedError {
+					t.Errorf("expected error %q, got %q", tt.expectedError, errorCode)
+				}
+			}
+		})
+	}
+
+	t.Run("Grant type not allowed", func(t *testing.T) {
+		memoryStore.StoreSettings(types.Settings{})
+		rr := postTokenRequest(handler, url.Values{
+			"grant_type":    {"client_credentials"},
+			"client_id":     {"web-app"},
+			"client_secret": {"new-secret"},
+		})
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusBadRequest, rr.Code, rr.Body.String())
+		}
+		if errorCode := decodeOAuthError(t, rr); errorCode != "unauthorized_client" {
+			t.Errorf("expected error unauthorized_client, got %q", errorCode)
+		}
+	})
+}
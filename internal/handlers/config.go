@@ -6,6 +6,7 @@ import (
 	"log"
 	"net/http"
 
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/jwt"
 	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/models"
 	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/store"
 	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/types"
@@ -30,6 +31,33 @@ type ConfigRequest struct {
 	UserInfo      map[string]interface{} `json:"user_info,omitempty"`
 	Tokens        map[string]interface{} `json:"tokens,omitempty"`
 	ErrorScenario *ErrorScenario         `json:"error_scenario,omitempty"`
+	Settings      *SettingsRequest       `json:"settings,omitempty"`
+	Clients       []*models.Client       `json:"clients,omitempty"`
+	Device        *DeviceDecision        `json:"device_authorization,omitempty"`
+	// Claims replaces the global custom claims added to every token
+	Claims *models.CustomClaims `json:"claims,omitempty"`
+}
+
+// DeviceDecision approves or denies a pending device authorization without
+// going through the verification page
+type DeviceDecision struct {
+	UserCode string `json:"user_code"`
+	Action   string `json:"action"`            // "approve" or "deny"
+	UserID   string `json:"user_id,omitempty"` // Registered user to sign the device in as
+}
+
+// SettingsRequest toggles server behaviour at runtime.
+// Fields left out of the JSON keep their current value.
+type SettingsRequest struct {
+	RefreshTokenRotation       *bool `json:"refresh_token_rotation,omitempty"`
+	RefreshTokenReuseDetection *bool `json:"refresh_token_reuse_detection,omitempty"`
+	RequirePKCE                *bool `json:"require_pkce,omitempty"`
+	RequireRegisteredClients   *bool `json:"require_registered_clients,omitempty"`
+	// SigningAlg is the default token signing algorithm, one of RS256, PS256, ES256, EdDSA or HS256
+	SigningAlg *string `json:"signing_alg,omitempty"`
+	// GroupsOverageThreshold is the most groups a token carries before the
+	// groups overage pointer replaces them. Zero disables the overage.
+	GroupsOverageThreshold *int `json:"groups_overage_threshold,omitempty"`
 }
 
 // ErrorScenario defines an error condition to simulate
@@ -109,6 +137,53 @@ func (h *ConfigHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			config.ErrorScenario.Enabled)
 	}
 
+	// Update server settings if provided
+	if config.Settings != nil {
+		if alg := config.Settings.SigningAlg; alg != nil && *alg != "" && !jwt.IsSigningAlgorithm(*alg) {
+			http.Error(w, "Invalid settings: unsupported signing_alg "+*alg, http.StatusBadRequest)
+			return
+		}
+		if threshold := config.Settings.GroupsOverageThreshold; threshold != nil && *threshold < 0 {
+			http.Error(w, "Invalid settings: groups_overage_threshold cannot be negative", http.StatusBadRequest)
+			return
+		}
+		h.storeSettings(*config.Settings)
+	}
+
+	// Register clients if provided
+	for _, client := range config.Clients {
+		if client == nil {
+			continue
+		}
+		if err := client.Validate(); err != nil {
+			http.Error(w, "Invalid client: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		h.store.StoreClient(client)
+		log.Printf("Registered client: %s", sanitizeLog(client.ClientID)) // #nosec G706 -- sanitizeLog strips CR/LF to prevent log injection
+	}
+
+	// Replace the global custom claims if provided
+	if config.Claims != nil {
+		if err := config.Claims.Validate(); err != nil {
+			http.Error(w, "Invalid claims: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		h.store.StoreCustomClaims(config.Claims)
+	}
+
+	// Decide a pending device authorization if requested
+	if config.Device != nil {
+		if config.Device.Action != "approve" && config.Device.Action != "deny" {
+			http.Error(w, "Invalid device_authorization action: must be approve or deny", http.StatusBadRequest)
+			return
+		}
+		if !decideDeviceAuthorization(h.store, config.Device.UserCode, config.Device.UserID, config.Device.Action == "approve") {
+			http.Error(w, "Unknown or expired user_code, or unknown user_id", http.StatusNotFound)
+			return
+		}
+	}
+
 	// Return success response
 	response := ConfigResponse{
 		Status:  "success",
@@ -167,6 +242,32 @@ func (h *ConfigHandler) storeErrorScenario(scenario ErrorScenario) {
 	h.store.StoreErrorScenario(storeScenario)
 }
 
+// storeSettings applies the provided settings on top of the current ones
+func (h *ConfigHandler) storeSettings(update SettingsRequest) {
+	settings := h.store.GetSettings()
+	if update.RefreshTokenRotation != nil {
+		settings.RefreshTokenRotation = *update.RefreshTokenRotation
+	}
+	if update.RefreshTokenReuseDetection != nil {
+		settings.RefreshTokenReuseDetection = *update.RefreshTokenReuseDetection
+	}
+	if update.RequirePKCE != nil {
+		settings.RequirePKCE = *update.RequirePKCE
+	}
+	if update.RequireRegisteredClients != nil {
+		settings.RequireRegisteredClients = *update.RequireRegisteredClients
+	}
+	if update.SigningAlg != nil {
+		settings.SigningAlg = *update.SigningAlg
+	}
+	if update.GroupsOverageThreshold != nil {
+		settings.GroupsOverageThreshold = *update.GroupsOverageThreshold
+	}
+
+	log.Printf("Storing settings: %+v", settings)
+	h.store.StoreSettings(settings)
+}
+
 // determineStatusCode returns an appropriate HTTP status code for the OAuth error
 func determineStatusCode(errorCode string) int {
 	switch errorCode {
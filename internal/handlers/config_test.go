@@ -9,11 +9,14 @@ import (
 	"testing"
 
 	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/models"
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/store"
 	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/types"
 )
 
-// Mock store implementation for testing
+// Mock store implementation for testing. Methods the config handler does not
+// exercise fall through to the embedded MemoryStore.
 type mockStore struct {
+	*store.MemoryStore
 	authCodes     map[string]*models.AuthRequest
 	tokens        map[string]string
 	tokenConfig   map[string]interface{}
@@ -22,6 +25,7 @@ type mockStore struct {
 
 func newMockStore() *mockStore {
 	return &mockStore{
+		MemoryStore: store.NewMemoryStore(),
 		authCodes:   make(map[string]*models.AuthRequest),
 		tokens:      make(map[string]string),
 		tokenConfig: make(map[string]interface{}),
@@ -559,3 +563,80 @@ func TestConfigHandler_ErrorScenarioExplicitlyDisabled(t *testing.T) {
 		t.Errorf("error scenario should not be returned when disabled, but got: %+v", scenario)
 	}
 }
+
+func TestConfigHandler_SigningAlgSetting(t *testing.T) {
+	mockStore := newMockStore()
+	handler := NewConfigHandler(mockStore, models.NewDefaultUser())
+
+	tests := []struct {
+		body       string
+		wantStatus int
+		wantAlg    string
+	}{
+		{body: `{"settings": {"signing_alg": "EdDSA"}}`, wantStatus: http.StatusOK, wantAlg: "EdDSA"},
+		{body: `{"settings": {"signing_alg": "none"}}`, wantStatus: http.StatusBadRequest, wantAlg: "EdDSA"},
+		{body: `{"settings": {"require_pkce": true}}`, wantStatus: http.StatusOK, wantAlg: "EdDSA"},
+		{body: `{"settings": {"signing_alg": ""}}`, wantStatus: http.StatusOK, wantAlg: ""},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("POST", "/config", bytes.NewBufferString(tt.body)))
+
+		if rr.Code != tt.wantStatus {
+			t.Errorf("%s: got status %d, want %d", tt.body, rr.Code, tt.wantStatus)
+		}
+		if alg := mockStore.GetSettings().SigningAlg; alg != tt.wantAlg {
+			t.Errorf("%s: got signing_alg %q, want %q", tt.body, alg, tt.wantAlg)
+		}
+	}
+}
+
+func TestConfigHandler_GroupsOverageThresholdSetting(t *testing.T) {
+	mockStore := newMockStore()
+	handler := NewConfigHandler(mockStore, models.NewDefaultUser())
+
+	tests := []struct {
+		body          string
+		wantStatus    int
+		wantThreshold int
+	}{
+		{body: `{"settings": {"groups_overage_threshold": 200}}`, wantStatus: http.StatusOK, wantThreshold: 200},
+		{body: `{"settings": {"groups_overage_threshold": -1}}`, wantStatus: http.StatusBadRequest, wantThreshold: 200},
+		{body: `{"settings": {"require_pkce": true}}`, wantStatus: http.StatusOK, wantThreshold: 200},
+		{body: `{"settings": {"groups_overage_threshold": 0}}`, wantStatus: http.StatusOK, wantThreshold: 0},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("POST", "/config", bytes.NewBufferString(tt.body)))
+
+		if rr.Code != tt.wantStatus {
+			t.Errorf("%s: got status %d, want %d", tt.body, rr.Code, tt.wantStatus)
+		}
+		if threshold := mockStore.GetSettings().GroupsOverageThreshold; threshold != tt.wantThreshold {
+			t.Errorf("%s: got groups_overage_threshold %d, want %d", tt.body, threshold, tt.wantThreshold)
+		}
+	}
+}
+
+func TestConfigHandler_CustomClaims(t *testing.T) {
+	mockStore := newMockStore()
+	handler := NewConfigHandler(mockStore, models.NewDefaultUser())
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST", "/config", bytes.NewBufferString(`{"claims": {"access_token": {"tenant_id": "t1"}}}`)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", rr.Code, rr.Body.String())
+	}
+	if claims := mockStore.GetCustomClaims(); claims == nil || claims.AccessToken["tenant_id"] != "t1" {
+		t.Errorf("expected global custom claims, got %+v", claims)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST", "/config", bytes.NewBufferString(`{"claims": {"access_token": {"exp": 0}}}`)))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("got status %d for a reserved claim, want %d", rr.Code, http.StatusBadRequest)
+	}
+	if claims := mockStore.GetCustomClaims(); claims.AccessToken["tenant_id"] != "t1" {
+		t.Errorf("expected rejected claims to leave the global claims unchanged, got %+v", claims)
+	}
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"encoding/json"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/models"
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/store"
+)
+
+const (
+	// consentModeParam is the /authorize query parameter that selects the consent mode
+	consentModeParam = "mock_consent"
+	// consentModeCookie selects the consent mode when the query parameter is absent
+	consentModeCookie = "mock_consent"
+	// consentModeInteractive renders the consent screen instead of granting every scope
+	consentModeInteractive = "interactive"
+	// consentStep marks a form submitted from the consent screen
+	consentStep = "consent"
+)
+
+// consentPage is the data rendered into the consent template
+type consentPage struct {
+	ClientID string
+	UserID   string
+	Scopes   []string
+	Params   []authorizeParam
+}
+
+var consentTemplate = template.Must(template.New("consent").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Mock OAuth2 Server - Consent</title></head>
+<body>
+<h1>Grant access</h1>
+<p>Client <strong>{{.ClientID}}</strong> would like to:</p>
+<form method="POST" action="/authorize">{{range .Params}}
+  <input type="hidden" name="{{.Name}}" value="{{.Value}}">{{end}}
+  <input type="hidden" name="step" value="consent">
+  <input type="hidden" name="user_id" value="{{.UserID}}">
+  {{range .Scopes}}<label><input type="checkbox" name="granted_scope" value="{{.}}" checked> {{.}}</label><br>
+  {{end}}<button type="submit" name="action" value="approve">Allow</button>
+  <button type="submit" name="action" value="deny">Deny</button>
+</form>
+</body>
+</html>
+`))
+
+// interactiveConsent reports whether the request asks for the consent screen
+// rather than granting every requested scope
+func interactiveConsent(r *http.Request) bool {
+	return requestMode(r, consentModeParam, consentModeCookie) == consentModeInteractive
+}
+
+// renderConsentPage lists the requested scopes with checkboxes so the tester
+// can grant a subset of them
+func renderConsentPage(w http.ResponseWriter, r *http.Request, userID string) {
+	page := consentPage{
+		ClientID: r.FormValue("client_id"),
+		UserID:   userID,
+		Scopes:   strings.Fields(r.FormValue("scope")),
+		Params:   authorizeParams(r.Form),
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := consentTemplate.Execute(w, page); err != nil {
+		log.Printf("Error rendering consent page: %v", err)
+	}
+}
+
+// consentsPath is the admin API collection path for consent decisions
+const consentsPath = "/admin/consents"
+
+// ConsentsHandler pre-sets consent decisions through the admin API so that
+// automated tests get partial grants without the consent screen.
+//
+//	GET    /admin/consents                              lists consent decisions
+//	POST   /admin/consents                              creates or replaces a decision
+//	DELETE /admin/consents?client_id=...&user_id=...    removes a decision
+type ConsentsHandler struct {
+	store store.Store
+}
+
+// NewConsentsHandler creates a new ConsentsHandler
+func NewConsentsHandler(store store.Store) *ConsentsHandler {
+	return &ConsentsHandler{store: store}
+}
+
+// ServeHTTP dispatches admin consent requests by method
+func (h *ConsentsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, h.store.ListConsents())
+	case http.MethodPost:
+		h.storeConsent(w, r)
+	case http.MethodDelete:
+		clientID := r.URL.Query().Get("client_id")
+		if !h.store.RemoveConsent(clientID, r.URL.Query().Get("user_id")) {
+			http.Error(w, "Consent not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Removed consent for client %s", sanitizeLog(clientID)) // #nosec G706 -- sanitizeLog strips CR/LF to prevent log injection
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// storeConsent decodes a consent decision from the request body and saves it
+func (h *ConsentsHandler) storeConsent(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20)) // limit request body to 1MB
+	if err != nil {
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	var consent models.Consent
+	if err := json.Unmarshal(body, &consent); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if consent.ClientID == "" {
+		http.Error(w, "Missing client_id", http.StatusBadRequest)
+		return
+	}
+
+	h.store.StoreConsent(&consent)
+	log.Printf("Stored consent for client %s and user %s: %s", sanitizeLog(consent.ClientID), sanitizeLog(consent.UserID), sanitizeLog(strings.Join(consent.Scopes, " "))) // #nosec G706 -- sanitizeLog strips CR/LF to prevent log injection
+
+	writeJSON(w, http.StatusCreated, &consent)
+}
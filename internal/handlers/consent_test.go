@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/jwt"
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/models"
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/store"
+)
+
+func TestAuthorizeHandler_ConsentScreen(t *testing.T) {
+	memoryStore := store.NewMemoryStore()
+	memoryStore.StoreUser(&models.UserInfo{Sub: "alice", Email: "alice@example.com"})
+	handler := &AuthorizeHandler{Store: memoryStore}
+
+	t.Run("Rendered after login", func(t *testing.T) {
+		rr := postLoginForm(handler, url.Values{"action": {"approve"}, "user_id": {"alice"}, "mock_consent": {"interactive"}})
+		body := rr.Body.String()
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected consent page, got status %d: %s", rr.Code, body)
+		}
+		for _, expected := range []string{
+			`name="granted_scope" value="openid" checked`,
+			`name="granted_scope" value="email" checked`,
+			`name="user_id" value="alice"`,
+			`name="step" value="consent"`,
+		} {
+			if !strings.Contains(body, expected) {
+				t.Errorf("expected consent page to contain %s: %s", expected, body)
+			}
+		}
+	})
+
+	t.Run("Rendered in auto login mode by cookie", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/authorize?"+loginTestParams().Encode(), nil)
+		req.AddCookie(&http.Cookie{Name: "mock_consent", Value: "interactive"})
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "granted_scope") {
+			t.Errorf("expected consent page, got status %d", rr.Code)
+		}
+	})
+
+	t.Run("Partial grant", func(t *testing.T) {
+		rr := postLoginForm(handler, url.Values{"action": {"approve"}, "step": {"consent"}, "user_id": {"alice"}, "granted_scope": {"openid"}})
+		authRequest := redirectedCode(t, memoryStore, rr)
+		if authRequest.Scope != "openid" || authRequest.UserID != "alice" {
+			t.Errorf("expected code for alice with scope openid, got %+v", authRequest)
+		}
+	})
+
+	t.Run("Unknown user", func(t *testing.T) {
+		rr := postLoginForm(handler, url.Values{"action": {"approve"}, "step": {"consent"}, "user_id": {"mallory"}, "granted_scope": {"openid"}})
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("Nothing granted", func(t *testing.T) {
+		rr := postLoginForm(handler, url.Values{"action": {"approve"}, "step": {"consent"}})
+		location, _ := url.Parse(rr.Header().Get("Location"))
+		if location.Query().Get("error") != "access_denied" {
+			t.Errorf("expected access_denied redirect, got %s", location)
+		}
+	})
+
+	t.Run("Deny", func(t *testing.T) {
+		rr := postLoginForm(handler, url.Values{"action": {"deny"}, "step": {"consent"}, "granted_scope": {"openid"}})
+		location, _ := url.Parse(rr.Header().Get("Location"))
+		if location.Query().Get("error") != "access_denied" {
+			t.Errorf("expected access_denied redirect, got %s", location)
+		}
+	})
+}
+
+func TestConsentsHandler_PresetConsent(t *testing.T) {
+	if err := jwt.InitKeys(); err != nil {
+		t.Fatalf("Failed to initialize keys: %v", err)
+	}
+
+	memoryStore := store.NewMemoryStore()
+	consentsHandler := NewConsentsHandler(memoryStore)
+	authorizeHandler := &AuthorizeHandler{Store: memoryStore}
+	tokenHandler := NewTokenHandler(memoryStore)
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/consents", strings.NewReader(`{"client_id":"test-client","scopes":["openid","profile"]}`))
+	rr := httptest.NewRecorder()
+	consentsHandler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+
+	// The pre-set decision applies even when the consent screen is requested
+	query := loginTestParams()
+	query.Set("scope", "openid email profile")
+	query.Set("mock_consent", "interactive")
+	rr = httptest.NewRecorder()
+	authorizeHandler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/authorize?"+query.Encode(), nil))
+	authRequest := redirectedCode(t, memoryStore, rr)
+	if authRequest.Scope != "openid profile" {
+		t.Fatalf("expected granted scope %q, got %q", "openid profile", authRequest.Scope)
+	}
+
+	location, _ := url.Parse(rr.Header().Get("Location"))
+	rr = postTokenRequest(tokenHandler, url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {location.Query().Get("code")},
+		"client_id":    {"test-client"},
+		"redirect_uri": {"http://localhost/callback"},
+	})
+	var tokens models.TokenResponse
+	if err := json.NewDecoder(rr.Body).Decode(&tokens); err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+	if tokens.Scope != "openid profile" {
+		t.Errorf("expected token response scope %q, got %q", "openid profile", tokens.Scope)
+	}
+	claims, err := jwt.VerifyToken(tokens.AccessToken)
+	if err != nil {
+		t.Fatalf("Error verifying access token: %v", err)
+	}
+	if scopes, _ := claims["scope"].([]interface{}); len(scopes) != 2 || scopes[0] != "openid" || scopes[1] != "profile" {
+		t.Errorf("expected access token scope claim [openid profile], got %v", claims["scope"])
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/admin/consents?client_id=test-client", nil)
+	rr = httptest.NewRecorder()
+	consentsHandler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("expected status %d, got %d", http.StatusNoContent, rr.Code)
+	}
+	if _, exists := memoryStore.GetConsent("test-client", ""); exists {
+		t.Errorf("expected consent to be removed")
+	}
+}
@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"html/template"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/models"
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/store"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// deviceCodeLifetime is how long a device code can be polled before it expires
+	deviceCodeLifetime = 10 * time.Minute
+	// devicePollInterval is the minimum polling interval handed to clients
+	devicePollInterval = 5 * time.Second
+	// userCodeAlphabet avoids vowels and look-alike characters (RFC 8628 section 6.1)
+	userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+)
+
+// DeviceAuthorizationResponse represents an RFC 8628 section 3.2 response
+type DeviceAuthorizationResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// DeviceAuthorizationHandler handles RFC 8628 device authorization requests
+type DeviceAuthorizationHandler struct {
+	store     store.Store
+	issuerURL string
+}
+
+// NewDeviceAuthorizationHandler creates a new DeviceAuthorizationHandler
+func NewDeviceAuthorizationHandler(store store.Store, issuerURL string) *DeviceAuthorizationHandler {
+	return &DeviceAuthorizationHandler{
+		store:     store,
+		issuerURL: strings.TrimSuffix(issuerURL, "/"),
+	}
+}
+
+// ServeHTTP issues a device code and user code
+func (h *DeviceAuthorizationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // limit request body to 1MB
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	clientID, _ := clientCredentials(r)
+	scope := r.FormValue("scope")
+	if clientID == "" {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Missing client_id parameter")
+		return
+	}
+
+	_, client, ok := identifyClient(h.store, r)
+	if !ok {
+		writeInvalidClient(w, r, "Client authentication failed")
+		return
+	}
+	if !allowsGrantType(w, client, deviceCodeGrantType) {
+		return
+	}
+	if client != nil && !client.AllowsScope(scope) {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_scope", "Requested scope is not allowed for this client")
+		return
+	}
+
+	userCode, err := generateUserCode()
+	if err != nil {
+		log.Printf("Error generating user code: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	authorization := &models.DeviceAuthorization{
+		DeviceCode: uuid.New().String(),
+		UserCode:   userCode,
+		ClientID:   clientID,
+		Scope:      scope,
+		Status:     models.DeviceStatusPending,
+		ExpiresAt:  time.Now().Add(deviceCodeLifetime),
+		Interval:   devicePollInterval,
+	}
+	h.store.StoreDeviceAuthorization(authorization)
+
+	verificationURI := h.issuerURL + "/device"
+	response := DeviceAuthorizationResponse{
+		DeviceCode:              authorization.DeviceCode,
+		UserCode:                userCode,
+		VerificationURI:         verificationURI,
+		VerificationURIComplete: verificationURI + "?user_code=" + url.QueryEscape(userCode),
+		ExpiresIn:               int(deviceCodeLifetime.Seconds()),
+		Interval:                int(devicePollInterval.Seconds()),
+	}
+
+	log.Printf("Issued device code for client %s with user code %s", sanitizeLog(clientID), userCode) // #nosec G706 -- sanitizeLog strips CR/LF to prevent log injection
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding device authorization response: %v", err)
+	}
+}
+
+// DeviceVerificationHandler serves the verification page where a tester
+// approves or denies a device by entering its user code
+type DeviceVerificationHandler struct {
+	store store.Store
+}
+
+// NewDeviceVerificationHandler creates a new DeviceVerificationHandler
+func NewDeviceVerificationHandler(store store.Store) *DeviceVerificationHandler {
+	return &DeviceVerificationHandler{store: store}
+}
+
+// deviceVerificationPage is the data rendered into the verification template
+type deviceVerificationPage struct {
+	UserCode string
+	ClientID string
+	Scope    string
+	Message  string
+	Users    []*models.UserInfo
+}
+
+var deviceVerificationTemplate = template.Must(template.New("device").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Mock OAuth2 Server - Device Login</title></head>
+<body>
+<h1>Connect a device</h1>
+{{if .Message}}<p>{{.Message}}</p>{{end}}
+{{if .ClientID}}<p>Client <strong>{{.ClientID}}</strong> is requesting: {{.Scope}}</p>{{end}}
+<form method="POST" action="/device">
+  <label for="user_code">Code</label>
+  <input id="user_code" name="user_code" value="{{.UserCode}}" autocomplete="off">
+  {{if .Users}}<label for="user_id">Sign in as</label>
+  <select id="user_id" name="user_id">
+    {{range .Users}}<option value="{{.Sub}}">{{.Name}} ({{.Email}})</option>{{end}}
+  </select>{{end}}
+  <button type="submit" name="action" value="approve">Approve</button>
+  <button type="submit" name="action" value="deny">Deny</button>
+</form>
+</body>
+</html>
+`))
+
+// ServeHTTP renders the verification form on GET and records the decision on POST
+func (h *DeviceVerificationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	page := deviceVerificationPage{Users: h.store.ListUsers()}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	switch r.Method {
+	case http.MethodGet:
+		page.UserCode = normalizeUserCode(r.URL.Query().Get("user_code"))
+		if authorization, exists := h.store.GetDeviceAuthorizationByUserCode(page.UserCode); exists {
+			page.ClientID = authorization.ClientID
+			page.Scope = authorization.Scope
+		}
+	case http.MethodPost:
+		r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // limit request body to 1MB
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		approve := r.FormValue("action") == "approve"
+		if decideDeviceAuthorization(h.store, r.FormValue("user_code"), r.FormValue("user_id"), approve) {
+			page.Message = "Device denied. You can close this window."
+			if approve {
+				page.Message = "Device approved. You can return to your device."
+			}
+		} else {
+			w.WriteHeader(http.StatusBadRequest)
+			page.Message = "Unknown or expired code."
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := deviceVerificationTemplate.Execute(w, page); err != nil {
+		log.Printf("Error rendering device verification page: %v", err)
+	}
+}
+
+// decideDeviceAuthorization approves or denies the pending device authorization
+// for a user code, binding approved devices to the given registered user. It
+// returns false if no pending, unexpired authorization exists or the user is unknown.
+func decideDeviceAuthorization(s store.Store, userCode, userID string, approve bool) bool {
+	authorization, exists := s.GetDeviceAuthorizationByUserCode(normalizeUserCode(userCode))
+	if !exists || authorization.Expired() {
+		return false
+	}
+	if userID != "" {
+		if _, exists := s.GetUser(userID); !exists {
+			return false
+		}
+	}
+
+	decided := false
+	s.UpdateDeviceAuthorization(authorization.DeviceCode, func(a *models.DeviceAuthorization) {
+		if a.Status != models.DeviceStatusPending {
+			return
+		}
+		a.Status = models.DeviceStatusDenied
+		if approve {
+			a.Status = models.DeviceStatusApproved
+			a.UserID = userID
+			a.AuthTime = time.Now()
+		}
+		decided = true
+	})
+
+	if decided {
+		log.Printf("Device authorization for user code %s decided: approved=%t", authorization.UserCode, approve)
+	}
+	return decided
+}
+
+// generateUserCode creates a random user code in the form XXXX-XXXX
+func generateUserCode() (string, error) {
+	alphabetSize := big.NewInt(int64(len(userCodeAlphabet)))
+	code := make([]byte, 0, 9)
+	for i := 0; i < 8; i++ {
+		if i == 4 {
+			code = append(code, '-')
+		}
+		n, err := rand.Int(rand.Reader, alphabetSize)
+		if err != nil {
+			return "", err
+		}
+		code = append(code, userCodeAlphabet[n.Int64()])
+	}
+	return string(code), nil
+}
+
+// normalizeUserCode makes user code entry case-insensitive and tolerant of
+// missing or extra separators
+func normalizeUserCode(userCode string) string {
+	code := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(userCode))
+	if len(code) != 8 {
+		return code
+	}
+	return code[:4] + "-" + code[4:]
+}
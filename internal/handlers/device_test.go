@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/models"
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/store"
+)
+
+// requestDeviceCode starts a device authorization for test-client
+func requestDeviceCode(t *testing.T, memoryStore *store.MemoryStore) DeviceAuthorizationResponse {
+	t.Helper()
+
+	handler := NewDeviceAuthorizationHandler(memoryStore, "http://localhost:8080/")
+	form := url.Values{"client_id": {"test-client"}, "scope": {"openid email"}}
+	req := httptest.NewRequest(http.MethodPost, "/device/code", strings.NewReader(form.Encode()))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var response DeviceAuthorizationResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+	return response
+}
+
+// pollDeviceToken polls the token endpoint after clearing the poll interval
+func pollDeviceToken(memoryStore *store.MemoryStore, handler http.Handler, deviceCode string) *httptest.ResponseRecorder {
+	memoryStore.UpdateDeviceAuthorization(deviceCode, func(a *models.DeviceAuthorization) {
+		a.LastPolledAt = time.Time{}
+	})
+	return postTokenRequest(handler, url.Values{
+		"grant_type":  {deviceCodeGrantType},
+		"device_code": {deviceCode},
+		"client_id":   {"test-client"},
+	})
+}
+
+func TestDeviceAuthorizationHandler(t *testing.T) {
+	memoryStore := store.NewMemoryStore()
+	response := requestDeviceCode(t, memoryStore)
+
+	if response.DeviceCode == "" || len(response.UserCode) != 9 {
+		t.Errorf("expected device code and XXXX-XXXX user code, got %+v", response)
+	}
+	if response.VerificationURI != "http://localhost:8080/device" {
+		t.Errorf("unexpected verification_uri %q", response.VerificationURI)
+	}
+	if response.VerificationURIComplete != "http://localhost:8080/device?user_code="+response.UserCode {
+		t.Errorf("unexpected verification_uri_complete %q", response.VerificationURIComplete)
+	}
+	if response.ExpiresIn != 600 || response.Interval != 5 {
+		t.Errorf("unexpected expires_in/interval: %d/%d", response.ExpiresIn, response.Interval)
+	}
+}
+
+func TestDeviceFlow_Approve(t *testing.T) {
+	memoryStore := store.NewMemoryStore()
+	tokenHandler := NewTokenHandler(memoryStore)
+	response := requestDeviceCode(t, memoryStore)
+
+	rr := pollDeviceToken(memoryStore, tokenHandler, response.DeviceCode)
+	if errorCode := decodeOAuthError(t, rr); errorCode != "authorization_pending" {
+		t.Errorf("expected authorization_pending, got %q", errorCode)
+	}
+
+	// Polling again without waiting for the interval
+	rr = postTokenRequest(tokenHandler, url.Values{
+		"grant_type":  {deviceCodeGrantType},
+		"device_code": {response.DeviceCode},
+		"client_id":   {"test-client"},
+	})
+	if errorCode := decodeOAuthError(t, rr); errorCode != "slow_down" {
+		t.Errorf("expected slow_down, got %q", errorCode)
+	}
+
+	// Approve through the verification page, typing the code in lower case
+	verificationHandler := NewDeviceVerificationHandler(memoryStore)
+	form := url.Values{"user_code": {strings.ToLower(response.UserCode)}, "action": {"approve"}}
+	req := httptest.NewRequest(http.MethodPost, "/device", strings.NewReader(form.Encode()))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	page := httptest.NewRecorder()
+	verificationHandler.ServeHTTP(page, req)
+	if page.Code != http.StatusOK || !strings.Contains(page.Body.String(), "approved") {
+		t.Fatalf("expected approval page, got %d: %s", page.Code, page.Body.String())
+	}
+
+	rr = pollDeviceToken(memoryStore, tokenHandler, response.DeviceCode)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var tokens models.TokenResponse
+	if err := json.NewDecoder(rr.Body).Decode(&tokens); err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+	if tokens.AccessToken == "" || tokens.RefreshToken == "" || tokens.Scope != "openid email" {
+		t.Errorf("unexpected token response %+v", tokens)
+	}
+
+	// The device code can only be redeemed once
+	rr = pollDeviceToken(memoryStore, tokenHandler, response.DeviceCode)
+	if errorCode := decodeOAuthError(t, rr); errorCode != "invalid_grant" {
+		t.Errorf("expected invalid_grant on second redemption, got %q", errorCode)
+	}
+}
+
+func TestDeviceFlow_DenyViaConfig(t *testing.T) {
+	memoryStore := store.NewMemoryStore()
+	tokenHandler := NewTokenHandler(memoryStore)
+	configHandler := NewConfigHandler(memoryStore, models.NewDefaultUser())
+	response := requestDeviceCode(t, memoryStore)
+
+	body, _ := json.Marshal(ConfigRequest{Device: &DeviceDecision{UserCode: response.UserCode, Action: "deny"}})
+	rr := httptest.NewRecorder()
+	configHandler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/config", bytes.NewBuffer(body)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	rr = pollDeviceToken(memoryStore, tokenHandler, response.DeviceCode)
+	if errorCode := decodeOAuthError(t, rr); errorCode != "access_denied" {
+		t.Errorf("expected access_denied, got %q", errorCode)
+	}
+
+	// A decided authorization cannot be decided again
+	body, _ = json.Marshal(ConfigRequest{Device: &DeviceDecision{UserCode: response.UserCode, Action: "approve"}})
+	rr = httptest.NewRecorder()
+	configHandler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/config", bytes.NewBuffer(body)))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestDeviceFlow_Expired(t *testing.T) {
+	memoryStore := store.NewMemoryStore()
+	tokenHandler := NewTokenHandler(memoryStore)
+	response := requestDeviceCode(t, memoryStore)
+
+	memoryStore.UpdateDeviceAuthorization(response.DeviceCode, func(a *models.DeviceAuthorization) {
+		a.ExpiresAt = time.Now().Add(-time.Second)
+	})
+
+	rr := pollDeviceToken(memoryStore, tokenHandler, response.DeviceCode)
+	if errorCode := decodeOAuthError(t, rr); errorCode != "expired_token" {
+		t.Errorf("expected expired_token, got %q", errorCode)
+	}
+	if decideDeviceAuthorization(memoryStore, response.UserCode, "", true) {
+		t.Error("expected an expired device authorization not to be approvable")
+	}
+}
+
+func TestNormalizeUserCode(t *testing.T) {
+	testCases := map[string]string{
+		"BCDF-GHJK": "BCDF-GHJK",
+		"bcdfghjk":  "BCDF-GHJK",
+		"bcdf ghjk": "BCDF-GHJK",
+		"short":     "SHORT",
+	}
+
+	for input, expected := range testCases {
+		if result := normalizeUserCode(input); result != expected {
+			t.Errorf("normalizeUserCode(%q) = %q; want %q", input, result, expected)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/jwt"
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/models"
+)
+
+// encryptForClient wraps a response in a JWE for the client's registered
+// encryption key. An empty enc uses the OIDC default, A128CBC-HS256.
+func encryptForClient(client *models.Client, payload []byte, alg, enc, contentType string) (string, error) {
+	if enc == "" {
+		enc = jwt.DefaultContentEncryption
+	}
+
+	keySet, err := clientKeySet(client)
+	if err != nil {
+		return "", err
+	}
+	if keySet == nil {
+		return "", errors.New("client has no keys")
+	}
+	key, ok := keySet.EncryptionKey(alg)
+	if !ok {
+		return "", fmt.Errorf("client has no key for %s", alg)
+	}
+
+	return jwt.Encrypt(payload, key, alg, enc, contentType)
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/jwt"
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/models"
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/store"
+)
+
+func TestTokenHandler_EncryptedIDToken(t *testing.T) {
+	if err := jwt.InitKeys(); err != nil {
+		t.Fatalf("Failed to initialize keys: %v", err)
+	}
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	signingKey, _ := jwt.NewJSONWebKey(&rsaKey.PublicKey, "client-sig", "RS256")
+	encryptionKey, _ := jwt.NewJSONWebKey(&rsaKey.PublicKey, "client-enc", "")
+	encryptionKey.Use = "enc"
+	keySet, _ := json.Marshal(jwt.JSONWebKeySet{Keys: []jwt.JSONWebKey{signingKey, encryptionKey}})
+
+	mockStore := store.NewMemoryStore()
+	mockStore.StoreClient(&models.Client{
+		ClientID:                    "rp",
+		ClientSecret:                "rp-secret",
+		JWKS:                        keySet,
+		IDTokenEncryptedResponseAlg: jwt.KeyAlgRSAOAEP256,
+		IDTokenEncryptedResponseEnc: jwt.EncA256GCM,
+	})
+	mockStore.StoreAuthCode("rp-code", &models.AuthRequest{
+		ClientID:    "rp",
+		RedirectURI: "http://example.com/callback",
+		Scope:       "openid",
+		Nonce:       "n-0S6_WzA2Mj",
+	})
+	handler := NewTokenHandler(mockStore)
+
+	rr := postTokenRequest(handler, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {"rp-code"},
+		"client_id":     {"rp"},
+		"client_secret": {"rp-secret"},
+		"redirect_uri":  {"http://example.com/callback"},
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("code exchange failed: status %d, body %s", rr.Code, rr.Body.String())
+	}
+	var response models.TokenResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+
+	// The ID token is a JWE around the signed ID token
+	if _, err := jwt.VerifyToken(response.IDToken); err == nil {
+		t.Fatalf("Expected the ID token to be encrypted")
+	}
+	nested, err := jwt.Decrypt(response.IDToken, rsaKey)
+	if err != nil {
+		t.Fatalf("Failed to decrypt ID token: %v", err)
+	}
+	claims, err := jwt.VerifyToken(string(nested))
+	if err != nil {
+		t.Fatalf("Failed to verify nested ID token: %v", err)
+	}
+	if claims["aud"] != "rp" || claims["nonce"] != "n-0S6_WzA2Mj" {
+		t.Errorf("Unexpected ID token claims %v", claims)
+	}
+
+	// The access token stays a plain signed JWT
+	if _, err := jwt.VerifyToken(response.AccessToken); err != nil {
+		t.Errorf("Expected a signed access token: %v", err)
+	}
+}
+
+func TestUserInfoHandler_Encrypted(t *testing.T) {
+	if err := jwt.InitKeys(); err != nil {
+		t.Fatalf("Failed to initialize keys: %v", err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate EC key: %v", err)
+	}
+	encryptionKey, _ := jwt.NewJSONWebKey(&ecKey.PublicKey, "client-enc", "")
+	encryptionKey.Use = "enc"
+	keySet, _ := json.Marshal(jwt.JSONWebKeySet{Keys: []jwt.JSONWebKey{encryptionKey}})
+
+	mockStore := store.NewMemoryStore()
+	mockStore.StoreClient(&models.Client{
+		ClientID:                     "rp",
+		ClientSecret:                 "rp-secret",
+		JWKS:                         keySet,
+		UserinfoEncryptedResponseAlg: jwt.KeyAlgECDHES,
+	})
+	mockStore.StoreClient(&models.Client{
+		ClientID:                     "rp-without-ec-key",
+		ClientSecret:                 "rp-secret",
+		JWKS:                         []byte(`{"keys":[{"kty":"RSA","use":"sig","n":"AQAB","e":"AQAB"}]}`),
+		UserinfoEncryptedResponseAlg: jwt.KeyAlgECDHES,
+	})
+	mockStore.StoreClient(&models.Client{
+		ClientID:                     "signing-rp",
+		JWKS:                         keySet,
+		UserinfoSignedResponseAlg:    "RS256",
+		UserinfoEncryptedResponseAlg: jwt.KeyAlgECDHES,
+		UserinfoEncryptedResponseEnc: jwt.EncA256GCM,
+	})
+	mockStore.StoreToken("rp-token", "rp")
+	mockStore.StoreToken("signing-rp-token", "signing-rp")
+	mockStore.StoreToken("misconfigured-token", "rp-without-ec-key")
+	handler := &UserInfoHandler{Store: mockStore}
+
+	req := httptest.NewRequest(http.MethodGet, "/userinfo", nil)
+	req.Header.Set("Authorization", "Bearer rp-token")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	if contentType := rr.Header().Get("Content-Type"); contentType != "application/jwt" {
+		t.Errorf("Expected Content-Type application/jwt, got %s", contentType)
+	}
+	plaintext, err := jwt.Decrypt(rr.Body.String(), ecKey)
+	if err != nil {
+		t.Fatalf("Failed to decrypt userinfo: %v", err)
+	}
+	var claims map[string]interface{}
+	if err := json.Unmarshal(plaintext, &claims); err != nil {
+		t.Fatalf("Expected JSON claims inside the JWE: %v", err)
+	}
+	if claims["sub"] != "rp" {
+		t.Errorf("Expected sub rp, got %v", claims["sub"])
+	}
+
+	// A client that also signs receives a signed JWT nested in the JWE
+	req = httptest.NewRequest(http.MethodGet, "/userinfo", nil)
+	req.Header.Set("Authorization", "Bearer signing-rp-token")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	nested, err := jwt.Decrypt(rr.Body.String(), ecKey)
+	if err != nil {
+		t.Fatalf("Failed to decrypt userinfo: %v", err)
+	}
+	signed, err := jwt.VerifyToken(string(nested))
+	if err != nil {
+		t.Fatalf("Failed to verify nested userinfo JWT: %v", err)
+	}
+	if signed["aud"] != "signing-rp" || signed["sub"] != "signing-rp" {
+		t.Errorf("Unexpected nested claims %v", signed)
+	}
+
+	// A client without a suitable key cannot be answered
+	req = httptest.NewRequest(http.MethodGet, "/userinfo", nil)
+	req.Header.Set("Authorization", "Bearer misconfigured-token")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, rr.Code)
+	}
+}
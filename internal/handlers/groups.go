@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/store"
+)
+
+// groupsPath is the endpoint that lists the groups of a token's user. Tokens
+// whose user is in more groups than the overage threshold point to it.
+const groupsPath = "/groups"
+
+// GroupsResponse lists group IDs in the shape of Microsoft Graph's
+// getMemberObjects response
+type GroupsResponse struct {
+	Value []string `json:"value"`
+}
+
+// GroupsHandler lists the groups of the user an access token was issued for,
+// standing in for the Microsoft Graph call Entra ID clients make on groups
+// overage.
+//
+//	GET  /groups  lists the groups of the bearer token's user
+//	POST /groups  the same, as Graph's getMemberObjects is a POST
+type GroupsHandler struct {
+	store store.Store
+}
+
+// NewGroupsHandler creates a new GroupsHandler
+func NewGroupsHandler(store store.Store) *GroupsHandler {
+	return &GroupsHandler{store: store}
+}
+
+// ServeHTTP authenticates the bearer access token and lists its user's groups
+func (h *GroupsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token, isBearer := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	record, exists := h.store.GetAccessToken(token)
+	if !isBearer || !exists || record.Expired() {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_token", "Invalid or expired access token")
+		return
+	}
+
+	// Tokens without a registered user, such as client credentials tokens,
+	// have no group memberships
+	response := GroupsResponse{Value: []string{}}
+	if user, exists := h.store.GetUser(record.UserID); exists && user.Groups != nil {
+		response.Value = user.Groups
+	}
+
+	log.Printf("Listed %d groups for token %s", len(response.Value), sanitizeLog(maskToken(token))) // #nosec G706 -- sanitizeLog strips CR/LF to prevent log injection
+	writeJSON(w, http.StatusOK, response)
+}
+
+// groupsEndpoint returns the URL of the groups endpoint of an issuer
+func groupsEndpoint(issuerURL string) string {
+	return strings.TrimSuffix(issuerURL, "/") + groupsPath
+}
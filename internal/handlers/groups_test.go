@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/jwt"
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/models"
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/store"
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/types"
+)
+
+// issueGroupsTestTokens redeems an authorization code for alice at test-client
+func issueGroupsTestTokens(t *testing.T, handler http.Handler, mockStore *store.MemoryStore) models.TokenResponse {
+	t.Helper()
+
+	mockStore.StoreAuthCode("alice-code", &models.AuthRequest{
+		ClientID:    "test-client",
+		RedirectURI: "http://example.com/callback",
+		Scope:       "openid email",
+		UserID:      "alice",
+	})
+	rr := postTokenRequest(handler, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {"alice-code"},
+		"client_id":     {"test-client"},
+		"client_secret": {"test-secret"},
+		"redirect_uri":  {"http://example.com/callback"},
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("code exchange failed: status %d, body %s", rr.Code, rr.Body.String())
+	}
+	var response models.TokenResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+	return response
+}
+
+func TestGroupsAndRolesClaims(t *testing.T) {
+	if err := jwt.InitKeys(); err != nil {
+		t.Fatalf("Failed to initialize keys: %v", err)
+	}
+
+	mockStore := store.NewMemoryStore()
+	mockStore.StoreClient(&models.Client{ClientID: "test-client", ClientSecret: "test-secret"})
+	mockStore.StoreUser(&models.UserInfo{
+		Sub:    "alice",
+		Email:  "alice@example.com",
+		Groups: []string{"g-admins", "g-billing", "g-reports"},
+		AppRoles: map[string][]string{
+			models.AllApplications: {"User"},
+			"test-client":          {"Admin", "User"},
+			"other-client":         {"Reader"},
+		},
+	})
+	handler := NewTokenHandler(mockStore)
+	wantGroups := []interface{}{"g-admins", "g-billing", "g-reports"}
+	wantRoles := []interface{}{"User", "Admin"}
+
+	response := issueGroupsTestTokens(t, handler, mockStore)
+	for name, token := range map[string]string{"ID token": response.IDToken, "access token": response.AccessToken} {
+		claims, err := jwt.VerifyToken(token)
+		if err != nil {
+			t.Fatalf("Failed to verify %s: %v", name, err)
+		}
+		if !reflect.DeepEqual(claims["groups"], wantGroups) {
+			t.Errorf("%s groups = %v; want %v", name, claims["groups"], wantGroups)
+		}
+		if !reflect.DeepEqual(claims["roles"], wantRoles) {
+			t.Errorf("%s roles = %v; want %v", name, claims["roles"], wantRoles)
+		}
+		if _, exists := claims["_claim_names"]; exists {
+			t.Errorf("%s has an overage pointer below the threshold", name)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/userinfo", nil)
+	req.Header.Set("Authorization", "Bearer "+response.AccessToken)
+	rr := httptest.NewRecorder()
+	(&UserInfoHandler{Store: mockStore}).ServeHTTP(rr, req)
+	var userInfo map[string]interface{}
+	if err := json.NewDecoder(rr.Body).Decode(&userInfo); err != nil {
+		t.Fatalf("Error decoding userinfo response: %v", err)
+	}
+	if !reflect.DeepEqual(userInfo["groups"], wantGroups) || !reflect.DeepEqual(userInfo["roles"], wantRoles) {
+		t.Errorf("unexpected userinfo memberships %v", userInfo)
+	}
+	if _, exists := userInfo["app_roles"]; exists {
+		t.Error("expected app_roles to stay out of the userinfo response")
+	}
+
+	// Above the threshold the groups are replaced by a pointer to the groups endpoint
+	mockStore.StoreSettings(types.Settings{GroupsOverageThreshold: 2})
+	response = issueGroupsTestTokens(t, handler, mockStore)
+	wantNames := map[string]interface{}{"groups": "src1"}
+	wantSources := map[string]interface{}{"src1": map[string]interface{}{"endpoint": "http://localhost:8080/groups"}}
+	for name, token := range map[string]string{"ID token": response.IDToken, "access token": response.AccessToken} {
+		claims, err := jwt.VerifyToken(token)
+		if err != nil {
+			t.Fatalf("Failed to verify %s: %v", name, err)
+		}
+		if _, exists := claims["groups"]; exists {
+			t.Errorf("%s carries groups above the overage threshold", name)
+		}
+		if !reflect.DeepEqual(claims["_claim_names"], wantNames) || !reflect.DeepEqual(claims["_claim_sources"], wantSources) {
+			t.Errorf("%s overage pointer = %v, %v", name, claims["_claim_names"], claims["_claim_sources"])
+		}
+		if !reflect.DeepEqual(claims["roles"], wantRoles) {
+			t.Errorf("%s roles = %v; want %v", name, claims["roles"], wantRoles)
+		}
+	}
+
+	// The client follows the pointer to list the groups
+	groupsHandler := NewGroupsHandler(mockStore)
+	req = httptest.NewRequest(http.MethodPost, "/groups", nil)
+	req.Header.Set("Authorization", "Bearer "+response.AccessToken)
+	rr = httptest.NewRecorder()
+	groupsHandler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("groups request failed: status %d, body %s", rr.Code, rr.Body.String())
+	}
+	var groups GroupsResponse
+	if err := json.NewDecoder(rr.Body).Decode(&groups); err != nil {
+		t.Fatalf("Error decoding groups response: %v", err)
+	}
+	if !reflect.DeepEqual(groups.Value, []string{"g-admins", "g-billing", "g-reports"}) {
+		t.Errorf("groups endpoint listed %v", groups.Value)
+	}
+}
+
+func TestGroupsHandler(t *testing.T) {
+	mockStore := store.NewMemoryStore()
+	mockStore.StoreUser(&models.UserInfo{Sub: "alice", Groups: []string{"g-admins"}})
+	mockStore.StoreAccessToken(&models.TokenRecord{Token: "alice-token", ClientID: "test-client", UserID: "alice"})
+	mockStore.StoreAccessToken(&models.TokenRecord{Token: "machine-token", ClientID: "test-client", Subject: "test-client"})
+	handler := NewGroupsHandler(mockStore)
+
+	testCases := []struct {
+		name          string
+		method        string
+		authorization string
+		wantStatus    int
+		wantGroups    []string
+	}{
+		{"user token", http.MethodGet, "Bearer alice-token", http.StatusOK, []string{"g-admins"}},
+		{"token without user", http.MethodGet, "Bearer machine-token", http.StatusOK, []string{}},
+		{"unknown token", http.MethodGet, "Bearer unknown", http.StatusUnauthorized, nil},
+		{"missing bearer scheme", http.MethodGet, "alice-token", http.StatusUnauthorized, nil},
+		{"missing authorization", http.MethodGet, "", http.StatusUnauthorized, nil},
+		{"unsupported method", http.MethodDelete, "Bearer alice-token", http.StatusMethodNotAllowed, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, "/groups", nil)
+			if tc.authorization != "" {
+				req.Header.Set("Authorization", tc.authorization)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tc.wantStatus {
+				t.Fatalf("status = %d; want %d (body %s)", rr.Code, tc.wantStatus, rr.Body.String())
+			}
+			if tc.wantGroups == nil {
+				return
+			}
+			var response GroupsResponse
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("Error decoding response: %v", err)
+			}
+			if !reflect.DeepEqual(response.Value, tc.wantGroups) {
+				t.Errorf("groups = %v; want %v", response.Value, tc.wantGroups)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/models"
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/store"
+)
+
+// IntrospectionResponse represents an RFC 7662 token introspection response.
+// Inactive tokens only carry the active flag.
+type IntrospectionResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Iss       string `json:"iss,omitempty"`
+	Aud       string `json:"aud,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+}
+
+// IntrospectionHandler handles RFC 7662 token introspection requests
+type IntrospectionHandler struct {
+	store     store.Store
+	issuerURL string
+}
+
+// NewIntrospectionHandler creates a new IntrospectionHandler
+func NewIntrospectionHandler(store store.Store, issuerURL string) *IntrospectionHandler {
+	return &IntrospectionHandler{
+		store:     store,
+		issuerURL: issuerURL,
+	}
+}
+
+// ServeHTTP reports whether a token is active and describes it
+func (h *IntrospectionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // limit request body to 1MB
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	if _, _, ok := authenticateClient(h.store, r); !ok {
+		writeInvalidClient(w, r, "Client authentication failed")
+		return
+	}
+
+	token := r.FormValue("token")
+	if token == "" {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Missing token parameter")
+		return
+	}
+
+	response := h.introspect(token, r.FormValue("token_type_hint"))
+	log.Printf("Introspected token %s: active=%t", sanitizeLog(maskToken(token)), response.Active) // #nosec G706 -- sanitizeLog strips CR/LF to prevent log injection
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding introspection response: %v", err)
+	}
+}
+
+// introspect looks the token up as an access token and as a refresh token,
+// starting with the type named by the hint
+func (h *IntrospectionHandler) introspect(token, tokenTypeHint string) IntrospectionResponse {
+	lookups := []func(string) IntrospectionResponse{h.introspectAccessToken, h.introspectRefreshToken}
+	if tokenTypeHint == "refresh_token" {
+		lookups[0], lookups[1] = lookups[1], lookups[0]
+	}
+
+	for _, lookup := range lookups {
+		if response := lookup(token); response.Active {
+			return response
+		}
+	}
+	return IntrospectionResponse{Active: false}
+}
+
+func (h *IntrospectionHandler) introspectAccessToken(token string) IntrospectionResponse {
+	record, exists := h.store.GetAccessToken(token)
+	if !exists || record.Expired() {
+		return IntrospectionResponse{Active: false}
+	}
+	return h.describe(record, "Bearer")
+}
+
+func (h *IntrospectionHandler) introspectRefreshToken(token string) IntrospectionResponse {
+	record, exists := h.store.GetRefreshToken(token)
+	if !exists || record.Expired() || record.Used {
+		return IntrospectionResponse{Active: false}
+	}
+	return h.describe(record, "refresh_token")
+}
+
+// describe builds an active introspection response from a token record
+func (h *IntrospectionHandler) describe(record *models.TokenRecord, tokenType string) IntrospectionResponse {
+	response := IntrospectionResponse{
+		Active:    true,
+		Scope:     record.Scope,
+		ClientID:  record.ClientID,
+		Sub:       record.Subject,
+		Iss:       strings.TrimSuffix(h.issuerURL, "/"),
+		Aud:       record.ClientID,
+		TokenType: tokenType,
+	}
+	if !record.IssuedAt.IsZero() {
+		response.Iat = record.IssuedAt.Unix()
+	}
+	if !record.ExpiresAt.IsZero() {
+		response.Exp = record.ExpiresAt.Unix()
+	}
+	return response
+}
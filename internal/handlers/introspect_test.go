@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/models"
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/store"
+)
+
+func TestIntrospectionHandler(t *testing.T) {
+	memoryStore := store.NewMemoryStore()
+	now := time.Now()
+	memoryStore.StoreAccessToken(&models.TokenRecord{
+		Token:     "active-access",
+		ClientID:  "test-client",
+		Subject:   "user-test-client",
+		Scope:     "openid email",
+		IssuedAt:  now,
+		ExpiresAt: now.Add(time.Hour),
+	})
+	memoryStore.StoreAccessToken(&models.TokenRecord{
+		Token:     "expired-access",
+		ClientID:  "test-client",
+		IssuedAt:  now.Add(-2 * time.Hour),
+		ExpiresAt: now.Add(-time.Hour),
+	})
+	memoryStore.StoreRefreshToken(&models.TokenRecord{
+		Token:    "active-refresh",
+		ClientID: "test-client",
+		Subject:  "user-test-client",
+		Scope:    "openid",
+		IssuedAt: now,
+	})
+	memoryStore.StoreRefreshToken(&models.TokenRecord{
+		Token:    "rotated-refresh",
+		ClientID: "test-client",
+		Used:     true,
+	})
+	handler := NewIntrospectionHandler(memoryStore, "http://localhost:8080")
+
+	tests := []struct {
+		name              string
+		token             string
+		hint              string
+		expectedActive    bool
+		expectedTokenType string
+		expectedScope     string
+	}{
+		{"Active access token", "active-access", "", true, "Bearer", "openid email"},
+		{"Active refresh token", "active-refresh", "", true, "refresh_token", "openid"},
+		{"Refresh token with wrong hint", "active-refresh", "access_token", true, "refresh_token", "openid"},
+		{"Expired access token", "expired-access", "", false, "", ""},
+		{"Rotated refresh token", "rotated-refresh", "refresh_token", false, "", ""},
+		{"Unknown token", "unknown", "", false, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{"token": {tt.token}}
+			if tt.hint != "" {
+				form.Set("token_type_hint", tt.hint)
+			}
+			req := httptest.NewRequest(http.MethodPost, "/introspect", strings.NewReader(form.Encode()))
+			req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+			req.SetBasicAuth("resource-server", "secret")
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+			}
+
+			var response map[string]interface{}
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("Error decoding response: %v", err)
+			}
+			if response["active"] != tt.expectedActive {
+				t.Fatalf("expected active=%t, got %v", tt.expectedActive, response["active"])
+			}
+			if !tt.expectedActive {
+				if len(response) != 1 {
+					t.Errorf("expected inactive response to only contain active, got %v", response)
+				}
+				return
+			}
+
+			if response["token_type"] != tt.expectedTokenType || response["scope"] != tt.expectedScope {
+				t.Errorf("unexpected token_type/scope: %v/%v", response["token_type"], response["scope"])
+			}
+			for _, claim := range []string{"client_id", "sub", "iat", "iss", "aud"} {
+				if _, exists := response[claim]; !exists {
+					t.Errorf("expected %s in response", claim)
+				}
+			}
+		})
+	}
+}
+
+func TestIntrospectionHandler_RequiresClientAuthentication(t *testing.T) {
+	handler := NewIntrospectionHandler(store.NewMemoryStore(), "http://localhost:8080")
+
+	form := url.Values{"token": {"anything"}}
+	req := httptest.NewRequest(http.MethodPost, "/introspect", strings.NewReader(form.Encode()))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected status %d, got %d", http.StatusUnauthorized, rr.Code)
+	}
+	if errorCode := decodeOAuthError(t, rr); errorCode != "invalid_client" {
+		t.Errorf("expected invalid_client, got %q", errorCode)
+	}
+}
@@ -1,31 +1,68 @@
 package handlers
 
 import (
-	"encoding/json"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/jwt"
 )
 
+// defaultJWKSMaxAge is how long clients may cache the JWKS by default
+const defaultJWKSMaxAge = 5 * time.Minute
+
 // JWKSHandler handles requests for JSON Web Key Set
-type JWKSHandler struct{}
+type JWKSHandler struct {
+	maxAge time.Duration
+}
 
 // NewJWKSHandler creates a new JWKS handler
 func NewJWKSHandler() *JWKSHandler {
-	return &JWKSHandler{}
+	return NewJWKSHandlerWithMaxAge(defaultJWKSMaxAge)
+}
+
+// NewJWKSHandlerWithMaxAge creates a new JWKS handler whose responses may be
+// cached for maxAge
+func NewJWKSHandlerWithMaxAge(maxAge time.Duration) *JWKSHandler {
+	return &JWKSHandler{maxAge: maxAge}
 }
 
-// ServeHTTP handles HTTP requests for JWKS
+// ServeHTTP handles HTTP requests for JWKS. The ETag changes whenever the
+// published keys change, so clients can revalidate their cached copy.
 func (h *JWKSHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	jwks, err := jwt.GetJWKS()
+	body, err := jwt.MarshalJWKS()
 	if err != nil {
 		http.Error(w, "Error generating JWKS", http.StatusInternalServerError)
 		return
 	}
 
+	hash := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(hash[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d, must-revalidate", int(h.maxAge.Seconds())))
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(jwks); err != nil {
+	if _, err := w.Write(body); err != nil {
 		http.Error(w, "Error encoding JWKS", http.StatusInternalServerError)
 		return
 	}
 }
+
+// etagMatches reports whether an If-None-Match header lists the ETag (RFC 9110 section 13.1.2)
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
@@ -5,6 +5,9 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
+
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/jwt"
 )
 
 func TestJWKSHandler(t *testing.T) {
@@ -70,3 +73,43 @@ func TestJWKSHandler(t *testing.T) {
 		t.Errorf("Expected use to be sig, got %v", key["use"])
 	}
 }
+
+func TestJWKSHandler_Caching(t *testing.T) {
+	handler := NewJWKSHandlerWithMaxAge(time.Minute)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/jwks", nil))
+
+	if got := rr.Header().Get("Cache-Control"); got != "public, max-age=60, must-revalidate" {
+		t.Errorf("Expected Cache-Control with max-age=60, got %q", got)
+	}
+	etag := rr.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("Expected an ETag header")
+	}
+
+	// A matching If-None-Match is answered without a body
+	req := httptest.NewRequest("GET", "/jwks", nil)
+	req.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotModified {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotModified, rr.Code)
+	}
+	if rr.Body.Len() != 0 {
+		t.Errorf("Expected an empty body, got %q", rr.Body.String())
+	}
+
+	// Rotating the keys changes the ETag
+	if _, err := jwt.RotateKeys(time.Hour); err != nil {
+		t.Fatalf("Failed to rotate keys: %v", err)
+	}
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status code %d after rotation, got %d", http.StatusOK, rr.Code)
+	}
+	if rr.Header().Get("ETag") == etag {
+		t.Errorf("Expected the ETag to change after rotation")
+	}
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/jwt"
+)
+
+// keysPath is the admin API collection path for the signing keys
+const keysPath = "/admin/keys"
+
+// RotateKeysRequest optionally overrides the grace period of a rotation
+type RotateKeysRequest struct {
+	// GracePeriod is how long, in seconds, the previous key stays published
+	GracePeriod *int `json:"grace_period,omitempty"`
+}
+
+// KeysHandler manages the signing keys through the admin API.
+//
+//	GET    /admin/keys         lists the published keys, the active keys first
+//	POST   /admin/keys/rotate  signs with new keys and retires the active keys after a grace period
+//	DELETE /admin/keys/{kid}   stops publishing a retiring key right away
+type KeysHandler struct {
+	gracePeriod time.Duration
+}
+
+// NewKeysHandler creates a new KeysHandler whose rotations keep the previous
+// key published for gracePeriod unless a request says otherwise
+func NewKeysHandler(gracePeriod time.Duration) *KeysHandler {
+	return &KeysHandler{gracePeriod: gracePeriod}
+}
+
+// ServeHTTP dispatches admin key requests by method and path
+func (h *KeysHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	kid := strings.Trim(strings.TrimPrefix(r.URL.Path, keysPath), "/")
+
+	switch {
+	case kid == "" && r.Method == http.MethodGet:
+		h.writeKeys(w, http.StatusOK)
+	case kid == "rotate" && r.Method == http.MethodPost:
+		h.rotate(w, r)
+	case kid != "" && r.Method == http.MethodDelete:
+		switch err := jwt.RemoveKey(kid); {
+		case errors.Is(err, jwt.ErrActiveKey):
+			http.Error(w, "The active signing key cannot be removed", http.StatusConflict)
+		case errors.Is(err, jwt.ErrUnknownKey):
+			http.Error(w, "Key not found", http.StatusNotFound)
+		default:
+			log.Printf("Removed signing key %s", sanitizeLog(kid)) // #nosec G706 -- sanitizeLog strips CR/LF to prevent log injection
+			w.WriteHeader(http.StatusNoContent)
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// rotate switches signing to a new key
+func (h *KeysHandler) rotate(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20)) // limit request body to 1MB
+	if err != nil {
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	gracePeriod := h.gracePeriod
+	if len(body) > 0 {
+		var request RotateKeysRequest
+		if err := json.Unmarshal(body, &request); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if request.GracePeriod != nil {
+			if *request.GracePeriod < 0 {
+				http.Error(w, "grace_period must not be negative", http.StatusBadRequest)
+				return
+			}
+			gracePeriod = time.Duration(*request.GracePeriod) * time.Second
+		}
+	}
+
+	kids, err := jwt.RotateKeys(gracePeriod)
+	if err != nil {
+		http.Error(w, "Failed to rotate signing keys", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Rotated signing keys, now signing with %s (grace period %s)", strings.Join(kids, ", "), gracePeriod)
+
+	h.writeKeys(w, http.StatusCreated)
+}
+
+// writeKeys writes the published keys
+func (h *KeysHandler) writeKeys(w http.ResponseWriter, status int) {
+	keys, err := jwt.ListKeys()
+	if err != nil {
+		http.Error(w, "Failed to list signing keys", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, status, keys)
+}
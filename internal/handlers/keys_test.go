@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/jwt"
+)
+
+func TestKeysHandler(t *testing.T) {
+	handler := NewKeysHandler(time.Hour)
+
+	listKeys := func(t *testing.T) []jwt.KeyInfo {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/admin/keys", nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+		var keys []jwt.KeyInfo
+		if err := json.NewDecoder(rr.Body).Decode(&keys); err != nil {
+			t.Fatalf("Failed to decode keys: %v", err)
+		}
+		return keys
+	}
+
+	before := listKeys(t)
+	if len(before) == 0 || !before[0].Active {
+		t.Fatalf("Expected the active key first, got %+v", before)
+	}
+
+	t.Run("rotate", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/admin/keys/rotate", strings.NewReader(`{"grace_period": 60}`))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("Expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+		}
+
+		var keys []jwt.KeyInfo
+		if err := json.NewDecoder(rr.Body).Decode(&keys); err != nil {
+			t.Fatalf("Failed to decode keys: %v", err)
+		}
+		if len(keys) < 2 || keys[0].Kid == before[0].Kid || !keys[0].Active {
+			t.Fatalf("Expected a new active key first, got %+v", keys)
+		}
+		previous := retiringKey(keys, before[0].Kid)
+		if previous == nil {
+			t.Fatalf("Expected %s to be retiring, got %+v", before[0].Kid, keys)
+		}
+		if previous.RetiresAt == nil || time.Until(*previous.RetiresAt) > time.Minute {
+			t.Errorf("Expected the previous key to retire within the requested grace period, got %v", previous.RetiresAt)
+		}
+	})
+
+	t.Run("negative grace period", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/admin/keys/rotate", strings.NewReader(`{"grace_period": -1}`))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		keys := listKeys(t)
+		retiring := retiringKey(keys, "")
+		if retiring == nil {
+			t.Fatalf("Expected a retiring key, got %+v", keys)
+		}
+
+		tests := []struct {
+			name string
+			kid  string
+			want int
+		}{
+			{name: "active key", kid: keys[0].Kid, want: http.StatusConflict},
+			{name: "unknown key", kid: "no-such-key", want: http.StatusNotFound},
+			{name: "retiring key", kid: retiring.Kid, want: http.StatusNoContent},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				rr := httptest.NewRecorder()
+				handler.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/admin/keys/"+tt.kid, nil))
+				if rr.Code != tt.want {
+					t.Errorf("Expected status code %d, got %d", tt.want, rr.Code)
+				}
+			})
+		}
+
+		for _, key := range listKeys(t) {
+			if key.Kid == retiring.Kid {
+				t.Errorf("Expected %s to be removed from the key list", key.Kid)
+			}
+		}
+	})
+}
+
+// retiringKey returns the rotated-out key with the given kid, or the first one when kid is empty
+func retiringKey(keys []jwt.KeyInfo, kid string) *jwt.KeyInfo {
+	for i := range keys {
+		if !keys[i].Active && (kid == "" || keys[i].Kid == kid) {
+			return &keys[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/models"
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/store"
+)
+
+const (
+	// loginModeParam is the /authorize query parameter that selects the login mode
+	loginModeParam = "mock_login"
+	// loginModeCookie selects the login mode when the query parameter is absent
+	loginModeCookie = "mock_login"
+	// loginModeInteractive renders the login page instead of auto-approving
+	loginModeInteractive = "interactive"
+
+	loginActionApprove = "approve"
+	loginActionDeny    = "deny"
+)
+
+// authorizeControlFields are the login and consent form fields that are not
+// part of the original authorization request. The consent mode is carried
+// through the login form so it still applies once the user is chosen.
+var authorizeControlFields = map[string]bool{
+	loginModeParam:  true,
+	"action":        true,
+	"step":          true,
+	"user_id":       true,
+	"email":         true,
+	"name":          true,
+	"granted_scope": true,
+}
+
+// loginPage is the data rendered into the login template
+type loginPage struct {
+	ClientID     string
+	Scope        string
+	Params       []authorizeParam
+	Users        []*models.UserInfo
+	SelectedUser string
+}
+
+// authorizeParam is an original /authorize parameter carried through the login
+// and consent forms
+type authorizeParam struct {
+	Name  string
+	Value string
+}
+
+var loginTemplate = template.Must(template.New("login").Parse(`{{define "params"}}{{range .Params}}
+  <input type="hidden" name="{{.Name}}" value="{{.Value}}">{{end}}{{end}}<!DOCTYPE html>
+<html>
+<head><title>Mock OAuth2 Server - Sign in</title></head>
+<body>
+<h1>Sign in</h1>
+<p>Client <strong>{{.ClientID}}</strong> is requesting: {{.Scope}}</p>
+{{if .Users}}<form method="POST" action="/authorize">{{template "params" .}}
+  <label for="user_id">Registered user</label>
+  <select id="user_id" name="user_id">
+    {{range .Users}}<option value="{{.Sub}}"{{if eq .Sub $.SelectedUser}} selected{{end}}>{{.Name}} ({{.Email}})</option>{{end}}
+  </select>
+  <button type="submit" name="action" value="approve">Sign in</button>
+</form>{{end}}
+<form method="POST" action="/authorize">{{template "params" .}}
+  <label for="email">Email</label>
+  <input id="email" name="email" type="email">
+  <label for="name">Name</label>
+  <input id="name" name="name">
+  <button type="submit" name="action" value="approve">Sign in as new user</button>
+</form>
+<form method="POST" action="/authorize">{{template "params" .}}
+  <button type="submit" name="action" value="deny">Deny</button>
+</form>
+</body>
+</html>
+`))
+
+// interactiveLogin reports whether the request asks for the login page rather
+// than auto-approval
+func interactiveLogin(r *http.Request) bool {
+	return requestMode(r, loginModeParam, loginModeCookie) == loginModeInteractive
+}
+
+// loginSubmitted reports whether the request is a submission of the login
+// page. Other POSTs are authorization requests sent as a form.
+func loginSubmitted(r *http.Request) bool {
+	return r.Method == http.MethodPost && r.PostForm.Has("action")
+}
+
+// requestMode reads a per-request mode. The parameter takes precedence over the cookie.
+func requestMode(r *http.Request, param, cookieName string) string {
+	if mode := r.FormValue(param); mode != "" {
+		return mode
+	}
+	if cookie, err := r.Cookie(cookieName); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+// renderLoginPage shows the user picker, carrying the original authorization
+// request parameters through hidden form fields
+func renderLoginPage(w http.ResponseWriter, r *http.Request, s store.Store, selectedUser string) {
+	page := loginPage{
+		ClientID:     r.FormValue("client_id"),
+		Scope:        r.FormValue("scope"),
+		Params:       authorizeParams(r.Form),
+		Users:        s.ListUsers(),
+		SelectedUser: selectedUser,
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := loginTemplate.Execute(w, page); err != nil {
+		log.Printf("Error rendering login page: %v", err)
+	}
+}
+
+// authorizeParams returns the original authorization request parameters in a
+// stable order, leaving out mode selectors and form controls
+func authorizeParams(form url.Values) []authorizeParam {
+	params := make([]authorizeParam, 0, len(form))
+	for name, values := range form {
+		if authorizeControlFields[name] || len(values) == 0 {
+			continue
+		}
+		params = append(params, authorizeParam{Name: name, Value: values[0]})
+	}
+	sort.Slice(params, func(i, j int) bool { return params[i].Name < params[j].Name })
+	return params
+}
+
+// loginUser resolves the user chosen on the login page. A registered user is
+// picked by user_id; otherwise an ad-hoc user is registered from email and name.
+func loginUser(s store.Store, r *http.Request) (string, bool) {
+	if userID := r.FormValue("user_id"); userID != "" {
+		user, exists := s.GetUser(userID)
+		if !exists {
+			return "", false
+		}
+		return user.Sub, true
+	}
+
+	email := strings.TrimSpace(r.FormValue("email"))
+	if email == "" {
+		return "", false
+	}
+	if user, exists := s.FindUser(email); exists {
+		return user.Sub, true
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" {
+		name = email
+	}
+	givenName, familyName, _ := strings.Cut(name, " ")
+	user := &models.UserInfo{
+		Sub:           email,
+		ID:            email,
+		Name:          name,
+		GivenName:     givenName,
+		FamilyName:    familyName,
+		Email:         email,
+		EmailVerified: true,
+	}
+	s.StoreUser(user)
+	log.Printf("Registered ad-hoc user %s from the login page", sanitizeLog(email)) // #nosec G706 -- sanitizeLog strips CR/LF to prevent log injection
+
+	return user.Sub, true
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/models"
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/store"
+)
+
+func loginTestParams() url.Values {
+	return url.Values{
+		"client_id":     {"test-client"},
+		"redirect_uri":  {"http://localhost/callback"},
+		"scope":         {"openid email"},
+		"response_type": {"code"},
+		"state":         {"test-state"},
+	}
+}
+
+// postLoginForm submits the login page with the given choice
+func postLoginForm(handler http.Handler, choice url.Values) *httptest.ResponseRecorder {
+	form := loginTestParams()
+	for name, values := range choice {
+		form[name] = values
+	}
+	req := httptest.NewRequest(http.MethodPost, "/authorize", strings.NewReader(form.Encode()))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+// redirectedCode returns the auth request bound to the code in a login redirect
+func redirectedCode(t *testing.T, memoryStore *store.MemoryStore, rr *httptest.ResponseRecorder) *models.AuthRequest {
+	t.Helper()
+
+	if rr.Code != http.StatusFound {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusFound, rr.Code, rr.Body.String())
+	}
+	location, err := url.Parse(rr.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("Error parsing redirect: %v", err)
+	}
+	authRequest, exists := memoryStore.GetAuthCode(location.Query().Get("code"))
+	if !exists {
+		t.Fatalf("expected redirect %s to carry a stored code", location)
+	}
+	return authRequest
+}
+
+func TestAuthorizeHandler_InteractiveLoginPage(t *testing.T) {
+	memoryStore := store.NewMemoryStore()
+	memoryStore.StoreUser(&models.UserInfo{Sub: "alice", Email: "alice@example.com", Name: "Alice"})
+	handler := &AuthorizeHandler{Store: memoryStore}
+
+	tests := []struct {
+		name         string
+		modeParam    string
+		modeCookie   string
+		expectedPage bool
+	}{
+		{"Auto-approve by default", "", "", false},
+		{"Interactive by query parameter", "interactive", "", true},
+		{"Interactive by cookie", "", "interactive", true},
+		{"Query parameter overrides cookie", "auto", "interactive", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := loginTestParams()
+			query.Set("login_hint", "alice@example.com")
+			if tt.modeParam != "" {
+				query.Set("mock_login", tt.modeParam)
+			}
+			req := httptest.NewRequest(http.MethodGet, "/authorize?"+query.Encode(), nil)
+			if tt.modeCookie != "" {
+				req.AddCookie(&http.Cookie{Name: "mock_login", Value: tt.modeCookie})
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if !tt.expectedPage {
+				if rr.Code != http.StatusFound {
+					t.Errorf("expected redirect, got status %d", rr.Code)
+				}
+				return
+			}
+
+			body := rr.Body.String()
+			if rr.Code != http.StatusOK || !strings.Contains(body, "<form") {
+				t.Fatalf("expected login page, got status %d: %s", rr.Code, body)
+			}
+			if !strings.Contains(body, `<option value="alice" selected>`) {
+				t.Errorf("expected login_hint user to be preselected: %s", body)
+			}
+			if !strings.Contains(body, `name="state" value="test-state"`) {
+				t.Errorf("expected original parameters as hidden fields: %s", body)
+			}
+			if strings.Contains(body, `name="mock_login"`) {
+				t.Errorf("expected mode selector to be left out of the form: %s", body)
+			}
+		})
+	}
+}
+
+func TestAuthorizeHandler_InteractiveLoginSubmit(t *testing.T) {
+	memoryStore := store.NewMemoryStore()
+	memoryStore.StoreUser(&models.UserInfo{Sub: "alice", Email: "alice@example.com", Name: "Alice"})
+	handler := &AuthorizeHandler{Store: memoryStore}
+
+	t.Run("Pick registered user", func(t *testing.T) {
+		rr := postLoginForm(handler, url.Values{"action": {"approve"}, "user_id": {"alice"}})
+		if authRequest := redirectedCode(t, memoryStore, rr); authRequest.UserID != "alice" {
+			t.Errorf("expected code bound to alice, got %q", authRequest.UserID)
+		}
+	})
+
+	t.Run("Ad-hoc user", func(t *testing.T) {
+		rr := postLoginForm(handler, url.Values{"action": {"approve"}, "email": {"carol@example.com"}, "name": {"Carol Example"}})
+		authRequest := redirectedCode(t, memoryStore, rr)
+		user, exists := memoryStore.GetUser(authRequest.UserID)
+		if !exists || user.Email != "carol@example.com" || user.Name != "Carol Example" || user.FamilyName != "Example" {
+			t.Errorf("expected ad-hoc user to be registered, got %+v", user)
+		}
+	})
+
+	t.Run("Deny", func(t *testing.T) {
+		rr := postLoginForm(handler, url.Values{"action": {"deny"}})
+		location, _ := url.Parse(rr.Header().Get("Location"))
+		if location.Query().Get("error") != "access_denied" || location.Query().Get("state") != "test-state" {
+			t.Errorf("expected access_denied redirect with state, got %s", location)
+		}
+	})
+
+	t.Run("Unknown user", func(t *testing.T) {
+		rr := postLoginForm(handler, url.Values{"action": {"approve"}, "user_id": {"mallory"}})
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("No user chosen", func(t *testing.T) {
+		rr := postLoginForm(handler, url.Values{"action": {"approve"}})
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+}
+
+func TestAuthorizeHandler_PostAutoApprove(t *testing.T) {
+	memoryStore := store.NewMemoryStore()
+	memoryStore.StoreUser(&models.UserInfo{Sub: "alice", Email: "alice@example.com"})
+	handler := &AuthorizeHandler{Store: memoryStore}
+
+	// An authorization request sent as a form is not a login page submission
+	rr := postLoginForm(handler, url.Values{})
+	if authRequest := redirectedCode(t, memoryStore, rr); authRequest.UserID != "" || authRequest.Scope != "openid email" {
+		t.Errorf("expected an auto-approved code, got %+v", authRequest)
+	}
+
+	rr = postLoginForm(handler, url.Values{"login_hint": {"alice@example.com"}})
+	if authRequest := redirectedCode(t, memoryStore, rr); authRequest.UserID != "alice" {
+		t.Errorf("expected code bound to the login_hint user, got %q", authRequest.UserID)
+	}
+}
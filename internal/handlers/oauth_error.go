@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// writeOAuthError writes an RFC 6749 section 5.2 JSON error response
+func writeOAuthError(w http.ResponseWriter, statusCode int, errorCode, description string) {
+	errorResponse := map[string]string{
+		"error": errorCode,
+	}
+	if description != "" {
+		errorResponse["error_description"] = description
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(errorResponse); err != nil {
+		log.Printf("Error encoding error response: %v", err)
+	}
+}
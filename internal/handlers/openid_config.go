@@ -5,45 +5,93 @@ import (
 	"encoding/json"
 	"net/http"
 	"strings"
+
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/jwt"
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/models"
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/store"
 )
 
+// standardScopes are the scopes the server supports without configuration
+var standardScopes = []string{"openid", "email", "profile", "address", "phone"}
+
+// clientAuthMethods lists the client authentication methods accepted at the
+// token, introspection and revocation endpoints
+var clientAuthMethods = []string{"client_secret_post", "client_secret_basic", jwt.AuthMethodClientSecretJWT, jwt.AuthMethodPrivateKeyJWT}
+
 // OpenIDConfigHandler handles requests to the OpenID Connect discovery endpoint
 type OpenIDConfigHandler struct {
 	BaseURL string
+	store   store.Store
 }
 
-// NewOpenIDConfigHandler creates a new OpenID Connect configuration handler
-func NewOpenIDConfigHandler(baseURL string) *OpenIDConfigHandler {
+// NewOpenIDConfigHandler creates a new OpenID Connect configuration handler.
+// The store's custom scopes are advertised alongside the standard scopes.
+func NewOpenIDConfigHandler(store store.Store, baseURL string) *OpenIDConfigHandler {
 	// Ensure the baseURL doesn't end with a slash
 	baseURL = strings.TrimSuffix(baseURL, "/")
 	return &OpenIDConfigHandler{
 		BaseURL: baseURL,
+		store:   store,
 	}
 }
 
 // ServeHTTP handles HTTP requests for OpenID Connect configuration
 func (h *OpenIDConfigHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	scopes := append([]string(nil), standardScopes...)
+	for _, scope := range h.store.ListScopes() {
+		scopes = append(scopes, scope.Name)
+	}
+
 	// Create the OpenID Connect configuration
 	config := map[string]interface{}{
-		"issuer":                                h.BaseURL,
-		"authorization_endpoint":                h.BaseURL + "/authorize",
-		"token_endpoint":                        h.BaseURL + "/token",
-		"userinfo_endpoint":                     h.BaseURL + "/userinfo",
-		"jwks_uri":                              h.BaseURL + "/jwks",
-		"response_types_supported":              []string{"code"},
-		"subject_types_supported":               []string{"public"},
-		"id_token_signing_alg_values_supported": []string{"RS256"},
-		"scopes_supported":                      []string{"openid", "email", "profile"},
-		"token_endpoint_auth_methods_supported": []string{"client_secret_post", "client_secret_basic"},
+		"issuer":                                           h.BaseURL,
+		"authorization_endpoint":                           h.BaseURL + "/authorize",
+		"token_endpoint":                                   h.BaseURL + "/token",
+		"userinfo_endpoint":                                h.BaseURL + "/userinfo",
+		"jwks_uri":                                         h.BaseURL + "/jwks",
+		"device_authorization_endpoint":                    h.BaseURL + "/device/code",
+		"introspection_endpoint":                           h.BaseURL + "/introspect",
+		"revocation_endpoint":                              h.BaseURL + "/revoke",
+		"registration_endpoint":                            h.BaseURL + registerPath,
+		"response_types_supported":                         []string{"code"},
+		"grant_types_supported":                            []string{"authorization_code", "refresh_token", "client_credentials", deviceCodeGrantType},
+		"subject_types_supported":                          []string{models.SubjectTypePublic, models.SubjectTypePairwise},
+		"id_token_signing_alg_values_supported":            jwt.SigningAlgorithms(),
+		"id_token_encryption_alg_values_supported":         jwt.EncryptionAlgorithms(),
+		"id_token_encryption_enc_values_supported":         jwt.ContentEncryptionAlgorithms(),
+		"userinfo_signing_alg_values_supported":            jwt.SigningAlgorithms(),
+		"userinfo_encryption_alg_values_supported":         jwt.EncryptionAlgorithms(),
+		"userinfo_encryption_enc_values_supported":         jwt.ContentEncryptionAlgorithms(),
+		"scopes_supported":                                 scopes,
+		"token_endpoint_auth_methods_supported":            clientAuthMethods,
+		"token_endpoint_auth_signing_alg_values_supported": jwt.ClientAssertionAlgorithms(),
+		"introspection_endpoint_auth_methods_supported":    clientAuthMethods,
+		"revocation_endpoint_auth_methods_supported":       clientAuthMethods,
+		"code_challenge_methods_supported":                 []string{"S256", "plain"},
+		"claims_parameter_supported":                       true,
 		"claims_supported": []string{
 			"sub",
 			"iss",
+			"aud",
+			"exp",
+			"iat",
+			"auth_time",
+			"nonce",
+			"azp",
+			"at_hash",
+			"acr",
 			"name",
 			"given_name",
 			"family_name",
 			"email",
 			"email_verified",
 			"picture",
+			"locale",
+			"phone_number",
+			"phone_number_verified",
+			"address",
+			"groups",
+			"roles",
 		},
 	}
 
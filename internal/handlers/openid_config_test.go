@@ -5,7 +5,12 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"slices"
 	"testing"
+
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/models"
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/store"
 )
 
 func TestOpenIDConfigHandler_ServeHTTP(t *testing.T) {
@@ -34,7 +39,7 @@ func TestOpenIDConfigHandler_ServeHTTP(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			handler := NewOpenIDConfigHandler(tc.baseURL)
+			handler := NewOpenIDConfigHandler(store.NewMemoryStore(), tc.baseURL)
 			req := httptest.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil)
 			resp := httptest.NewRecorder()
 
@@ -84,6 +89,7 @@ func TestOpenIDConfigHandler_ServeHTTP(t *testing.T) {
 				"scopes_supported",
 				"token_endpoint_auth_methods_supported",
 				"claims_supported",
+				"code_challenge_methods_supported",
 			}
 
 			for _, array := range requiredArrays {
@@ -94,3 +100,27 @@ func TestOpenIDConfigHandler_ServeHTTP(t *testing.T) {
 		})
 	}
 }
+
+func TestOpenIDConfigHandler_CustomScopes(t *testing.T) {
+	memoryStore := store.NewMemoryStore()
+	memoryStore.StoreScope(&models.Scope{Name: "invoices.read", Claims: []string{"tenant_id"}})
+	handler := NewOpenIDConfigHandler(memoryStore, "http://localhost:8080")
+
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil))
+
+	var config struct {
+		ScopesSupported []string `json:"scopes_supported"`
+		ClaimsSupported []string `json:"claims_supported"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &config); err != nil {
+		t.Fatalf("failed to parse response as JSON: %v", err)
+	}
+	want := []string{"openid", "email", "profile", "address", "phone", "invoices.read"}
+	if !reflect.DeepEqual(config.ScopesSupported, want) {
+		t.Errorf("scopes_supported = %v; want %v", config.ScopesSupported, want)
+	}
+	if !slices.Contains(config.ClaimsSupported, "groups") || !slices.Contains(config.ClaimsSupported, "roles") {
+		t.Errorf("expected claims_supported to list groups and roles, got %v", config.ClaimsSupported)
+	}
+}
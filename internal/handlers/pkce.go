@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"regexp"
+)
+
+// Supported PKCE code challenge methods
+const (
+	pkceMethodS256  = "S256"
+	pkceMethodPlain = "plain"
+)
+
+// codeVerifierPattern matches the code_verifier grammar from RFC 7636 section 4.1
+var codeVerifierPattern = regexp.MustCompile(`^[A-Za-z0-9\-._~]{43,128}$`)
+
+// verifyCodeChallenge checks a code_verifier against the challenge stored with
+// the authorization code
+func verifyCodeChallenge(verifier, challenge, method string) bool {
+	if !codeVerifierPattern.MatchString(verifier) {
+		return false
+	}
+
+	expected := verifier
+	if method == pkceMethodS256 {
+		sum := sha256.Sum256([]byte(verifier))
+		expected = base64.RawURLEncoding.EncodeToString(sum[:])
+	}
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
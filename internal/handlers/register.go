@@ -0,0 +1,309 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/models"
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/store"
+	"github.com/google/uuid"
+)
+
+// registerPath is the dynamic client registration endpoint
+const registerPath = "/register"
+
+// registrableGrantTypes are the grant types a client may register for
+var registrableGrantTypes = map[string]bool{
+	"authorization_code": true,
+	"refresh_token":      true,
+	"client_credentials": true,
+	deviceCodeGrantType:  true,
+}
+
+// clientMetadata is the client metadata of RFC 7591 section 2, as sent in
+// registration requests and returned in registration responses
+type clientMetadata struct {
+	ClientID                string          `json:"client_id,omitempty"`
+	ClientSecret            string          `json:"client_secret,omitempty"`
+	ClientIDIssuedAt        int64           `json:"client_id_issued_at,omitempty"`
+	ClientSecretExpiresAt   *int64          `json:"client_secret_expires_at,omitempty"`
+	RegistrationAccessToken string          `json:"registration_access_token,omitempty"`
+	RegistrationClientURI   string          `json:"registration_client_uri,omitempty"`
+	RedirectURIs            []string        `json:"redirect_uris,omitempty"`
+	GrantTypes              []string        `json:"grant_types,omitempty"`
+	ResponseTypes           []string        `json:"response_types,omitempty"`
+	TokenEndpointAuthMethod string          `json:"token_endpoint_auth_method,omitempty"`
+	ClientName              string          `json:"client_name,omitempty"`
+	Scope                   string          `json:"scope,omitempty"`
+	JWKS                    json.RawMessage `json:"jwks,omitempty"`
+	JWKSURI                 string          `json:"jwks_uri,omitempty"`
+
+	IDTokenSignedResponseAlg     string `json:"id_token_signed_response_alg,omitempty"`
+	AccessTokenSignedResponseAlg string `json:"access_token_signed_response_alg,omitempty"`
+	IDTokenEncryptedResponseAlg  string `json:"id_token_encrypted_response_alg,omitempty"`
+	IDTokenEncryptedResponseEnc  string `json:"id_token_encrypted_response_enc,omitempty"`
+	UserinfoSignedResponseAlg    string `json:"userinfo_signed_response_alg,omitempty"`
+	UserinfoEncryptedResponseAlg string `json:"userinfo_encrypted_response_alg,omitempty"`
+	UserinfoEncryptedResponseEnc string `json:"userinfo_encrypted_response_enc,omitempty"`
+	SubjectType                  string `json:"subject_type,omitempty"`
+	SectorIdentifierURI          string `json:"sector_identifier_uri,omitempty"`
+}
+
+// RegistrationHandler implements Dynamic Client Registration (RFC 7591) and
+// its management protocol (RFC 7592).
+//
+//	POST   /register       registers a client
+//	GET    /register/{id}  reads a client's registration
+//	PUT    /register/{id}  replaces a client's metadata
+//	DELETE /register/{id}  deletes a client
+//
+// Management requests authenticate with the registration_access_token
+// returned at registration as a Bearer token.
+type RegistrationHandler struct {
+	store     store.Store
+	issuerURL string
+}
+
+// NewRegistrationHandler creates a new RegistrationHandler
+func NewRegistrationHandler(store store.Store, issuerURL string) *RegistrationHandler {
+	return &RegistrationHandler{
+		store:     store,
+		issuerURL: strings.TrimSuffix(issuerURL, "/"),
+	}
+}
+
+// ServeHTTP dispatches registration requests by method and path
+func (h *RegistrationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	clientID := strings.Trim(strings.TrimPrefix(r.URL.Path, registerPath), "/")
+
+	switch {
+	case clientID == "" && r.Method == http.MethodPost:
+		h.register(w, r)
+	case clientID != "" && (r.Method == http.MethodGet || r.Method == http.MethodPut || r.Method == http.MethodDelete):
+		client, ok := h.authorizeManagement(r, clientID)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			writeOAuthError(w, http.StatusUnauthorized, "invalid_token", "Invalid registration access token")
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			h.writeRegistration(w, http.StatusOK, client)
+		case http.MethodPut:
+			h.update(w, r, client)
+		default:
+			h.store.RemoveClient(clientID)
+			log.Printf("Deleted dynamically registered client %s", sanitizeLog(clientID)) // #nosec G706 -- sanitizeLog strips CR/LF to prevent log injection
+			w.WriteHeader(http.StatusNoContent)
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// register creates a client from the metadata in the request body (RFC 7591 section 3)
+func (h *RegistrationHandler) register(w http.ResponseWriter, r *http.Request) {
+	metadata, ok := readClientMetadata(w, r)
+	if !ok {
+		return
+	}
+
+	client := &models.Client{
+		ClientID:                uuid.New().String(),
+		RegistrationAccessToken: "mock-registration-token-" + uuid.New().String(),
+	}
+	if !applyClientMetadata(w, client, metadata) {
+		return
+	}
+
+	h.store.StoreClient(client)
+	log.Printf("Dynamically registered client %s", sanitizeLog(client.ClientID)) // #nosec G706 -- sanitizeLog strips CR/LF to prevent log injection
+
+	h.writeRegistration(w, http.StatusCreated, client)
+}
+
+// update replaces a client's metadata, keeping its client ID, secret and
+// registration access token (RFC 7592 section 2.2)
+func (h *RegistrationHandler) update(w http.ResponseWriter, r *http.Request, client *models.Client) {
+	metadata, ok := readClientMetadata(w, r)
+	if !ok {
+		return
+	}
+	if metadata.ClientID != client.ClientID {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "client_id must match the registered client")
+		return
+	}
+	if metadata.ClientSecret != "" && !client.HasSecret(metadata.ClientSecret) {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "client_secret must match the issued client secret")
+		return
+	}
+
+	updated := &models.Client{
+		ClientID:                client.ClientID,
+		ClientSecret:            client.ClientSecret,
+		RegistrationAccessToken: client.RegistrationAccessToken,
+	}
+	if !applyClientMetadata(w, updated, metadata) {
+		return
+	}
+
+	h.store.StoreClient(updated)
+	log.Printf("Updated dynamically registered client %s", sanitizeLog(client.ClientID)) // #nosec G706 -- sanitizeLog strips CR/LF to prevent log injection
+
+	h.writeRegistration(w, http.StatusOK, updated)
+}
+
+// authorizeManagement returns the client named in a management request if the
+// request carries its registration access token
+func (h *RegistrationHandler) authorizeManagement(r *http.Request, clientID string) (*models.Client, bool) {
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found || token == "" {
+		return nil, false
+	}
+	client, exists := h.store.GetClient(clientID)
+	if !exists || client.RegistrationAccessToken == "" {
+		return nil, false
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(client.RegistrationAccessToken)) != 1 {
+		return nil, false
+	}
+	return client, true
+}
+
+// writeRegistration writes the client information response (RFC 7591 section 3.2.1)
+func (h *RegistrationHandler) writeRegistration(w http.ResponseWriter, status int, client *models.Client) {
+	response := clientMetadata{
+		ClientID:                client.ClientID,
+		ClientSecret:            client.ClientSecret,
+		RegistrationAccessToken: client.RegistrationAccessToken,
+		RegistrationClientURI:   h.issuerURL + registerPath + "/" + url.PathEscape(client.ClientID),
+		RedirectURIs:            client.RedirectURIs,
+		GrantTypes:              client.GrantTypes,
+		TokenEndpointAuthMethod: client.TokenEndpointAuthMethod,
+		ClientName:              client.ClientName,
+		Scope:                   strings.Join(client.AllowedScopes, " "),
+		JWKS:                    client.JWKS,
+		JWKSURI:                 client.JWKSURI,
+
+		IDTokenSignedResponseAlg:     client.IDTokenSignedResponseAlg,
+		AccessTokenSignedResponseAlg: client.AccessTokenSignedResponseAlg,
+		IDTokenEncryptedResponseAlg:  client.IDTokenEncryptedResponseAlg,
+		IDTokenEncryptedResponseEnc:  client.IDTokenEncryptedResponseEnc,
+		UserinfoSignedResponseAlg:    client.UserinfoSignedResponseAlg,
+		UserinfoEncryptedResponseAlg: client.UserinfoEncryptedResponseAlg,
+		UserinfoEncryptedResponseEnc: client.UserinfoEncryptedResponseEnc,
+		SubjectType:                  client.SubjectType,
+		SectorIdentifierURI:          client.SectorIdentifierURI,
+	}
+	if client.AllowsGrantType("authorization_code") {
+		response.ResponseTypes = []string{"code"}
+	}
+	if client.ClientSecret != "" {
+		// Issued secrets never expire
+		neverExpires := int64(0)
+		response.ClientSecretExpiresAt = &neverExpires
+	}
+	if status == http.StatusCreated {
+		response.ClientIDIssuedAt = time.Now().Unix()
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, status, response)
+}
+
+// readClientMetadata decodes the client metadata in a request body
+func readClientMetadata(w http.ResponseWriter, r *http.Request) (*clientMetadata, bool) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20)) // limit request body to 1MB
+	if err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Failed to read request body")
+		return nil, false
+	}
+
+	var metadata clientMetadata
+	if err := json.Unmarshal(body, &metadata); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_client_metadata", "Invalid JSON")
+		return nil, false
+	}
+	return &metadata, true
+}
+
+// applyClientMetadata validates registered metadata and copies it onto the client,
+// defaulting as RFC 7591 section 2 describes. A client secret is issued when the
+// authentication method needs one and dropped when it does not.
+func applyClientMetadata(w http.ResponseWriter, client *models.Client, metadata *clientMetadata) bool {
+	grantTypes := metadata.GrantTypes
+	if len(grantTypes) == 0 {
+		grantTypes = []string{"authorization_code"}
+	}
+	for _, grantType := range grantTypes {
+		if !registrableGrantTypes[grantType] {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_client_metadata", fmt.Sprintf("Unsupported grant type %q", grantType))
+			return false
+		}
+	}
+	for _, responseType := range metadata.ResponseTypes {
+		if responseType != "code" {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_client_metadata", fmt.Sprintf("Unsupported response type %q", responseType))
+			return false
+		}
+	}
+
+	for _, redirectURI := range metadata.RedirectURIs {
+		if u, err := url.Parse(redirectURI); err != nil || !u.IsAbs() || u.Fragment != "" {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_redirect_uri", fmt.Sprintf("Redirect URI %q must be absolute and have no fragment", redirectURI))
+			return false
+		}
+	}
+	if len(metadata.RedirectURIs) == 0 && slices.Contains(grantTypes, "authorization_code") {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_redirect_uri", "redirect_uris are required for the authorization_code grant")
+		return false
+	}
+
+	authMethod := metadata.TokenEndpointAuthMethod
+	if authMethod == "" {
+		authMethod = "client_secret_basic"
+	}
+
+	client.RedirectURIs = metadata.RedirectURIs
+	client.GrantTypes = grantTypes
+	client.TokenEndpointAuthMethod = authMethod
+	client.ClientName = metadata.ClientName
+	client.AllowedScopes = strings.Fields(metadata.Scope)
+	client.JWKS = metadata.JWKS
+	client.JWKSURI = metadata.JWKSURI
+	client.IDTokenSignedResponseAlg = metadata.IDTokenSignedResponseAlg
+	client.AccessTokenSignedResponseAlg = metadata.AccessTokenSignedResponseAlg
+	client.IDTokenEncryptedResponseAlg = metadata.IDTokenEncryptedResponseAlg
+	client.IDTokenEncryptedResponseEnc = metadata.IDTokenEncryptedResponseEnc
+	client.UserinfoSignedResponseAlg = metadata.UserinfoSignedResponseAlg
+	client.UserinfoEncryptedResponseAlg = metadata.UserinfoEncryptedResponseAlg
+	client.UserinfoEncryptedResponseEnc = metadata.UserinfoEncryptedResponseEnc
+	client.SubjectType = metadata.SubjectType
+	client.SectorIdentifierURI = metadata.SectorIdentifierURI
+	client.ClientType = models.ClientTypeConfidential
+	switch authMethod {
+	case "none":
+		client.ClientType = models.ClientTypePublic
+		client.ClientSecret = ""
+	case "private_key_jwt":
+		client.ClientSecret = ""
+	default:
+		if client.ClientSecret == "" {
+			client.ClientSecret = "mock-client-secret-" + uuid.New().String()
+		}
+	}
+
+	if err := client.Validate(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_client_metadata", err.Error())
+		return false
+	}
+	return true
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/store"
+)
+
+func TestRegistrationHandler(t *testing.T) {
+	memoryStore := store.NewMemoryStore()
+	handler := NewRegistrationHandler(memoryStore, "http://localhost:8080/")
+
+	serve := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+	decode := func(rr *httptest.ResponseRecorder) clientMetadata {
+		t.Helper()
+		var metadata clientMetadata
+		if err := json.NewDecoder(rr.Body).Decode(&metadata); err != nil {
+			t.Fatalf("Error decoding registration: %v", err)
+		}
+		return metadata
+	}
+
+	rr := serve(http.MethodPost, "/register", "", `{"client_name":"Tenant App","redirect_uris":["https://tenant.example.com/callback"],"scope":"openid email"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	if rr.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("expected Cache-Control no-store, got %q", rr.Header().Get("Cache-Control"))
+	}
+	registered := decode(rr)
+	if registered.ClientID == "" || registered.ClientSecret == "" || registered.RegistrationAccessToken == "" {
+		t.Fatalf("expected client_id, client_secret and registration_access_token, got %+v", registered)
+	}
+	if registered.TokenEndpointAuthMethod != "client_secret_basic" || len(registered.GrantTypes) != 1 || registered.GrantTypes[0] != "authorization_code" {
+		t.Errorf("expected RFC 7591 defaults, got %+v", registered)
+	}
+	if registered.RegistrationClientURI != "http://localhost:8080/register/"+registered.ClientID {
+		t.Errorf("unexpected registration_client_uri %s", registered.RegistrationClientURI)
+	}
+	if registered.ClientSecretExpiresAt == nil || *registered.ClientSecretExpiresAt != 0 {
+		t.Errorf("expected client_secret_expires_at 0, got %v", registered.ClientSecretExpiresAt)
+	}
+
+	// The registered client is enforced like any other registered client
+	client, exists := memoryStore.GetClient(registered.ClientID)
+	if !exists || !client.HasSecret(registered.ClientSecret) || client.AllowsRedirectURI("https://evil.example.com/callback") || client.AllowsScope("admin") {
+		t.Errorf("unexpected stored client %+v", client)
+	}
+
+	clientPath := "/register/" + registered.ClientID
+	if rr := serve(http.MethodGet, clientPath, "", ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected status %d without token, got %d", http.StatusUnauthorized, rr.Code)
+	}
+	if rr := serve(http.MethodGet, clientPath, "wrong-token", ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected status %d with wrong token, got %d", http.StatusUnauthorized, rr.Code)
+	}
+	rr = serve(http.MethodGet, clientPath, registered.RegistrationAccessToken, "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if read := decode(rr); read.ClientName != "Tenant App" || read.ClientSecret != registered.ClientSecret {
+		t.Errorf("unexpected registration %+v", read)
+	}
+
+	update := `{"client_id":"` + registered.ClientID + `","client_name":"Renamed App","redirect_uris":["https://tenant.example.com/new"],"grant_types":["authorization_code","refresh_token"]}`
+	rr = serve(http.MethodPut, clientPath, registered.RegistrationAccessToken, update)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if updated := decode(rr); updated.ClientName != "Renamed App" || updated.ClientSecret != registered.ClientSecret || len(updated.GrantTypes) != 2 {
+		t.Errorf("unexpected update %+v", updated)
+	}
+	if client, _ := memoryStore.GetClient(registered.ClientID); client.AllowsRedirectURI("https://tenant.example.com/callback") || len(client.AllowedScopes) != 0 {
+		t.Errorf("expected metadata to be replaced, got %+v", client)
+	}
+	if rr := serve(http.MethodPut, clientPath, registered.RegistrationAccessToken, `{"client_id":"other","redirect_uris":["https://a.example.com/"]}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for mismatched client_id, got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	if rr := serve(http.MethodDelete, clientPath, registered.RegistrationAccessToken, ""); rr.Code != http.StatusNoContent {
+		t.Errorf("expected status %d, got %d", http.StatusNoContent, rr.Code)
+	}
+	if _, exists := memoryStore.GetClient(registered.ClientID); exists {
+		t.Errorf("expected client to be deleted")
+	}
+	if rr := serve(http.MethodGet, clientPath, registered.RegistrationAccessToken, ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected status %d after delete, got %d", http.StatusUnauthorized, rr.Code)
+	}
+}
+
+func TestRegistrationHandler_Metadata(t *testing.T) {
+	memoryStore := store.NewMemoryStore()
+	handler := NewRegistrationHandler(memoryStore, "http://localhost:8080")
+
+	tests := []struct {
+		name          string
+		body          string
+		expectedError string
+		expectSecret  bool
+	}{
+		{"Public native app", `{"redirect_uris":["http://127.0.0.1/callback"],"token_endpoint_auth_method":"none"}`, "", false},
+		{"Service with keys", `{"grant_types":["client_credentials"],"token_endpoint_auth_method":"private_key_jwt","jwks":{"keys":[{"kty":"EC","crv":"P-256","x":"a","y":"b"}]}}`, "", false},
+		{"Service with client_secret_jwt", `{"grant_types":["client_credentials"],"token_endpoint_auth_method":"client_secret_jwt"}`, "", true},
+		{"Missing redirect URIs", `{}`, "invalid_redirect_uri", false},
+		{"Relative redirect URI", `{"redirect_uris":["/callback"]}`, "invalid_redirect_uri", false},
+		{"Redirect URI with fragment", `{"redirect_uris":["https://app.example.com/cb#frag"]}`, "invalid_redirect_uri", false},
+		{"Unsupported grant type", `{"grant_types":["password"]}`, "invalid_client_metadata", false},
+		{"Unsupported response type", `{"redirect_uris":["https://app.example.com/cb"],"response_types":["token"]}`, "invalid_client_metadata", false},
+		{"Unsupported auth method", `{"redirect_uris":["https://app.example.com/cb"],"token_endpoint_auth_method":"tls_client_auth"}`, "invalid_client_metadata", false},
+		{"private_key_jwt without keys", `{"grant_types":["client_credentials"],"token_endpoint_auth_method":"private_key_jwt"}`, "invalid_client_metadata", false},
+		{"Both jwks and jwks_uri", `{"grant_types":["client_credentials"],"jwks":{"keys":[{}]},"jwks_uri":"https://app.example.com/jwks"}`, "invalid_client_metadata", false},
+		{"Invalid JSON", `{`, "invalid_client_metadata", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if tt.expectedError != "" {
+				if rr.Code != http.StatusBadRequest {
+					t.Fatalf("expected status %d, got %d: %s", http.StatusBadRequest, rr.Code, rr.Body.String())
+				}
+				if errorCode := decodeOAuthError(t, rr); errorCode != tt.expectedError {
+					t.Errorf("expected error %q, got %q", tt.expectedError, errorCode)
+				}
+				return
+			}
+
+			if rr.Code != http.StatusCreated {
+				t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+			}
+			var metadata clientMetadata
+			if err := json.NewDecoder(rr.Body).Decode(&metadata); err != nil {
+				t.Fatalf("Error decoding registration: %v", err)
+			}
+			if (metadata.ClientSecret != "") != tt.expectSecret {
+				t.Errorf("expected client secret issued = %v, got %+v", tt.expectSecret, metadata)
+			}
+		})
+	}
+}
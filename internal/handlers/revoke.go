@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/models"
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/store"
+)
+
+// RevocationHandler handles RFC 7009 token revocation requests
+type RevocationHandler struct {
+	store store.Store
+}
+
+// NewRevocationHandler creates a new RevocationHandler
+func NewRevocationHandler(store store.Store) *RevocationHandler {
+	return &RevocationHandler{store: store}
+}
+
+// ServeHTTP revokes an access or refresh token issued to the calling client
+func (h *RevocationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // limit request body to 1MB
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	// Confidential clients must authenticate; public clients only identify themselves
+	clientID, _, ok := identifyClient(h.store, r)
+	if clientID == "" {
+		writeInvalidClient(w, r, "Missing client_id parameter")
+		return
+	}
+	if !ok {
+		writeInvalidClient(w, r, "Client authentication failed")
+		return
+	}
+
+	token := r.FormValue("token")
+	if token == "" {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Missing token parameter")
+		return
+	}
+
+	// The hint only tells us where to look first; both token types are checked
+	owner, known := h.tokenOwner(token, r.FormValue("token_type_hint"))
+	if known {
+		if owner != clientID {
+			writeOAuthError(w, http.StatusBadRequest, "unauthorized_client", "Token was not issued to this client")
+			return
+		}
+		h.store.RevokeToken(token)
+		log.Printf("Revoked token %s for client %s", sanitizeLog(maskToken(token)), sanitizeLog(clientID)) // #nosec G706 -- sanitizeLog strips CR/LF to prevent log injection
+	}
+
+	// RFC 7009 section 2.2: unknown and already revoked tokens also get a 200 response
+	w.WriteHeader(http.StatusOK)
+}
+
+// tokenOwner returns the client a token was issued to
+func (h *RevocationHandler) tokenOwner(token, tokenTypeHint string) (string, bool) {
+	lookups := []func(string) (*models.TokenRecord, bool){h.store.GetAccessToken, h.store.GetRefreshToken}
+	if tokenTypeHint == "refresh_token" {
+		lookups[0], lookups[1] = lookups[1], lookups[0]
+	}
+
+	for _, lookup := range lookups {
+		if record, exists := lookup(token); exists {
+			return record.ClientID, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/store"
+)
+
+// postRevocation sends a revocation request on behalf of test-client
+func postRevocation(handler http.Handler, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/revoke", strings.NewReader(form.Encode()))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestRevocationHandler_AccessToken(t *testing.T) {
+	memoryStore := store.NewMemoryStore()
+	tokens := exchangeTestCode(t, NewTokenHandler(memoryStore), memoryStore, "openid")
+	handler := NewRevocationHandler(memoryStore)
+
+	rr := postRevocation(handler, url.Values{"token": {tokens.AccessToken}, "client_id": {"test-client"}})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	// The revoked access token no longer works at /userinfo
+	req := httptest.NewRequest(http.MethodGet, "/userinfo", nil)
+	req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+	resp := httptest.NewRecorder()
+	(&UserInfoHandler{Store: memoryStore}).ServeHTTP(resp, req)
+	if resp.Code != http.StatusUnauthorized {
+		t.Errorf("expected revoked token to be rejected by /userinfo, got %d", resp.Code)
+	}
+
+	// Revoking an access token leaves the refresh token alone
+	if _, exists := memoryStore.GetRefreshToken(tokens.RefreshToken); !exists {
+		t.Error("expected refresh token to survive access token revocation")
+	}
+}
+
+func TestRevocationHandler_RefreshTokenRevokesFamily(t *testing.T) {
+	memoryStore := store.NewMemoryStore()
+	tokens := exchangeTestCode(t, NewTokenHandler(memoryStore), memoryStore, "openid")
+	handler := NewRevocationHandler(memoryStore)
+
+	rr := postRevocation(handler, url.Values{
+		"token":           {tokens.RefreshToken},
+		"token_type_hint": {"refresh_token"},
+		"client_id":       {"test-client"},
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	if _, exists := memoryStore.GetRefreshToken(tokens.RefreshToken); exists {
+		t.Error("expected refresh token to be revoked")
+	}
+	if _, exists := memoryStore.GetAccessToken(tokens.AccessToken); exists {
+		t.Error("expected access token issued from the refresh token's grant to be revoked")
+	}
+}
+
+func TestRevocationHandler_Errors(t *testing.T) {
+	memoryStore := store.NewMemoryStore()
+	tokens := exchangeTestCode(t, NewTokenHandler(memoryStore), memoryStore, "openid")
+	handler := NewRevocationHandler(memoryStore)
+
+	tests := []struct {
+		name           string
+		form           url.Values
+		expectedStatus int
+	}{
+		{"Unknown token", url.Values{"token": {"unknown"}, "client_id": {"test-client"}}, http.StatusOK},
+		{"Token of another client", url.Values{"token": {tokens.AccessToken}, "client_id": {"other-client"}}, http.StatusBadRequest},
+		{"Missing token", url.Values{"client_id": {"test-client"}}, http.StatusBadRequest},
+		{"Missing client", url.Values{"token": {tokens.AccessToken}}, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := postRevocation(handler, tt.form)
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+		})
+	}
+
+	if _, exists := memoryStore.GetAccessToken(tokens.AccessToken); !exists {
+		t.Error("expected access token to survive rejected revocations")
+	}
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/models"
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/store"
+)
+
+// rulesPath is the admin API collection path for claim rules
+const rulesPath = "/admin/rules"
+
+// RulesHandler manages expression-based claim rules through the admin API.
+//
+//	GET    /admin/rules         lists claim rules in the order they are applied
+//	POST   /admin/rules         creates or replaces a rule (name is required)
+//	GET    /admin/rules/{name}  returns a single rule
+//	DELETE /admin/rules/{name}  removes a rule
+type RulesHandler struct {
+	store store.Store
+}
+
+// NewRulesHandler creates a new RulesHandler
+func NewRulesHandler(store store.Store) *RulesHandler {
+	return &RulesHandler{store: store}
+}
+
+// ServeHTTP dispatches admin claim rule requests by method and path
+func (h *RulesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, rulesPath), "/")
+
+	switch {
+	case name == "" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, h.store.ListClaimRules())
+	case name == "" && r.Method == http.MethodPost:
+		h.storeRule(w, r)
+	case name != "" && r.Method == http.MethodGet:
+		rule, exists := h.store.GetClaimRule(name)
+		if !exists {
+			http.Error(w, "Rule not found", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, rule)
+	case name != "" && r.Method == http.MethodDelete:
+		if !h.store.RemoveClaimRule(name) {
+			http.Error(w, "Rule not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Removed claim rule %s", sanitizeLog(name)) // #nosec G706 -- sanitizeLog strips CR/LF to prevent log injection
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// storeRule decodes a claim rule from the request body and defines it
+func (h *RulesHandler) storeRule(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20)) // limit request body to 1MB
+	if err != nil {
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	var rule models.ClaimRule
+	if err := json.Unmarshal(body, &rule); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := rule.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.store.StoreClaimRule(&rule)
+	log.Printf("Defined claim rule %s for claim %s", sanitizeLog(rule.Name), sanitizeLog(rule.Claim)) // #nosec G706 -- sanitizeLog strips CR/LF to prevent log injection
+
+	writeJSON(w, http.StatusCreated, &rule)
+}
+
+// ruleVariables returns the variables claim rules are evaluated with. The user
+// and client are nil when they are not registered.
+func ruleVariables(user *models.UserInfo, clientID string, client *models.Client, scope string, request map[string]interface{}) map[string]interface{} {
+	userClaims := map[string]interface{}{}
+	if user != nil {
+		userClaims = user.ClaimsFor(clientID)
+	}
+
+	clientVariable := map[string]interface{}{"client_id": clientID}
+	if client != nil {
+		clientVariable["client_name"] = client.ClientName
+	}
+
+	scopes := make([]interface{}, 0)
+	for _, s := range strings.Fields(scope) {
+		scopes = append(scopes, s)
+	}
+
+	return map[string]interface{}{
+		"user":    userClaims,
+		"client":  clientVariable,
+		"scopes":  scopes,
+		"request": request,
+	}
+}
+
+// applyClaimRules sets the claims the rules compute for the target. Rules
+// that fail to evaluate, e.g. by calling a string function on a missing
+// claim, are logged and skipped.
+func applyClaimRules(s store.Store, claims map[string]interface{}, target string, variables map[string]interface{}) {
+	for _, rule := range s.ListClaimRules() {
+		if !rule.Applies(target) {
+			continue
+		}
+		value, err := rule.Evaluate(variables)
+		if err != nil {
+			log.Printf("Claim rule %s failed: %s", sanitizeLog(rule.Name), sanitizeLog(err.Error())) // #nosec G706 -- sanitizeLog strips CR/LF to prevent log injection
+			continue
+		}
+		if value != nil {
+			claims[rule.Claim] = value
+		}
+	}
+}
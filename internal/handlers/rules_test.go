@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/jwt"
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/models"
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/store"
+)
+
+func TestRulesHandler(t *testing.T) {
+	memoryStore := store.NewMemoryStore()
+	handler := NewRulesHandler(memoryStore)
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for _, body := range []string{
+		`{"claim":"roles","expression":"[]"}`,
+		`{"name":"roles","claim":"roles","expression":"user.groups.exists(g"}`,
+		`{"name":"roles","claim":"roles","expression":"tenant.roles"}`,
+		`{"name":"issuer","claim":"iss","expression":"'x'"}`,
+		`not json`,
+	} {
+		if rr := serve(http.MethodPost, "/admin/rules", body); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d for %s, got %d", http.StatusBadRequest, body, rr.Code)
+		}
+	}
+
+	rr := serve(http.MethodPost, "/admin/rules", `{"name":"roles","claim":"roles","expression":"[\"reader\"]"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	serve(http.MethodPost, "/admin/rules", `{"name":"domain","claim":"domain","expression":"user.email.split('@')[1]"}`)
+
+	rr = serve(http.MethodGet, "/admin/rules", "")
+	var rules []models.ClaimRule
+	if err := json.NewDecoder(rr.Body).Decode(&rules); err != nil {
+		t.Fatalf("Error decoding rules: %v", err)
+	}
+	if len(rules) != 2 || rules[0].Name != "domain" || rules[1].Name != "roles" {
+		t.Fatalf("expected rules domain and roles, got %+v", rules)
+	}
+
+	if rr := serve(http.MethodGet, "/admin/rules/domain", ""); rr.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	if rr := serve(http.MethodDelete, "/admin/rules/domain", ""); rr.Code != http.StatusNoContent {
+		t.Errorf("expected status %d, got %d", http.StatusNoContent, rr.Code)
+	}
+	if rr := serve(http.MethodGet, "/admin/rules/domain", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("expected status %d after delete, got %d", http.StatusNotFound, rr.Code)
+	}
+	if rr := serve(http.MethodPut, "/admin/rules", ""); rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status %d, got %d", http.StatusMethodNotAllowed, rr.Code)
+	}
+}
+
+func TestClaimRules(t *testing.T) {
+	if err := jwt.InitKeys(); err != nil {
+		t.Fatalf("Failed to initialize keys: %v", err)
+	}
+
+	mockStore := store.NewMemoryStore()
+	mockStore.StoreUser(&models.UserInfo{
+		Sub:          "alice",
+		Email:        "alice@corp.example.com",
+		CustomClaims: &models.CustomClaims{IDToken: map[string]interface{}{"roles": []interface{}{"custom"}}},
+	})
+	mockStore.StoreClient(&models.Client{ClientID: "test-client", ClientSecret: "test-secret", ClientName: "Test App"})
+	for _, rule := range []*models.ClaimRule{
+		{Name: "roles", Claim: "roles", Expression: `has(user.email) && user.email.endsWith("@corp.example.com") ? ["employee"] : ["guest"]`},
+		{Name: "tier", Claim: "tier", Expression: `request.grant_type == "client_credentials" ? "machine" : "human"`, Targets: []string{models.RuleTargetAccessToken}},
+		{Name: "app", Claim: "app", Expression: `client.client_name + " (" + string(size(scopes)) + " scopes)"`, Targets: []string{models.RuleTargetUserInfo}},
+		{Name: "broken", Claim: "broken", Expression: `user.hd.endsWith("example.com")`},
+		{Name: "unset", Claim: "unset", Expression: `has(user.hd) ? user.hd : null`},
+	} {
+		mockStore.StoreClaimRule(rule)
+	}
+	handler := NewTokenHandler(mockStore)
+
+	mockStore.StoreAuthCode("alice-code", &models.AuthRequest{
+		ClientID:    "test-client",
+		RedirectURI: "http://example.com/callback",
+		Scope:       "openid email",
+		UserID:      "alice",
+	})
+	rr := postTokenRequest(handler, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {"alice-code"},
+		"client_id":     {"test-client"},
+		"client_secret": {"test-secret"},
+		"redirect_uri":  {"http://example.com/callback"},
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("code exchange failed: status %d, body %s", rr.Code, rr.Body.String())
+	}
+	var response models.TokenResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+
+	idClaims, err := jwt.VerifyToken(response.IDToken)
+	if err != nil {
+		t.Fatalf("Failed to verify ID token: %v", err)
+	}
+	if roles, ok := idClaims["roles"].([]interface{}); !ok || len(roles) != 1 || roles[0] != "employee" {
+		t.Errorf("expected the rule to replace the custom roles claim, got %v", idClaims["roles"])
+	}
+	for _, claim := range []string{"tier", "app", "broken", "unset"} {
+		if _, ok := idClaims[claim]; ok {
+			t.Errorf("expected no %s claim in the ID token, got %v", claim, idClaims[claim])
+		}
+	}
+
+	accessClaims, err := jwt.VerifyToken(response.AccessToken)
+	if err != nil {
+		t.Fatalf("Failed to verify access token: %v", err)
+	}
+	if accessClaims["tier"] != "human" || accessClaims["roles"] == nil {
+		t.Errorf("unexpected access token claims %v", accessClaims)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/userinfo", nil)
+	req.Header.Set("Authorization", "Bearer "+response.AccessToken)
+	rr = httptest.NewRecorder()
+	(&UserInfoHandler{Store: mockStore}).ServeHTTP(rr, req)
+	var userInfo map[string]interface{}
+	if err := json.NewDecoder(rr.Body).Decode(&userInfo); err != nil {
+		t.Fatalf("Error decoding userinfo response: %v", err)
+	}
+	if userInfo["app"] != "Test App (2 scopes)" || userInfo["roles"] == nil || userInfo["tier"] != nil {
+		t.Errorf("unexpected userinfo claims %v", userInfo)
+	}
+
+	rr = postTokenRequest(handler, url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {"test-client"},
+		"client_secret": {"test-secret"},
+	})
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+	accessClaims, err = jwt.VerifyToken(response.AccessToken)
+	if err != nil {
+		t.Fatalf("Failed to verify access token: %v", err)
+	}
+	if roles, ok := accessClaims["roles"].([]interface{}); !ok || roles[0] != "guest" || accessClaims["tier"] != "machine" {
+		t.Errorf("unexpected client credentials claims %v", accessClaims)
+	}
+}
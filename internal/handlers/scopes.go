@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/models"
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/store"
+)
+
+// scopesPath is the admin API collection path for custom scopes
+const scopesPath = "/admin/scopes"
+
+// ScopesHandler manages custom scope-to-claim mappings through the admin API.
+//
+//	GET    /admin/scopes         lists custom scopes
+//	POST   /admin/scopes         creates or replaces a scope (name is required)
+//	GET    /admin/scopes/{name}  returns a single scope
+//	DELETE /admin/scopes/{name}  removes a scope
+type ScopesHandler struct {
+	store store.Store
+}
+
+// NewScopesHandler creates a new ScopesHandler
+func NewScopesHandler(store store.Store) *ScopesHandler {
+	return &ScopesHandler{store: store}
+}
+
+// ServeHTTP dispatches admin scope requests by method and path
+func (h *ScopesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, scopesPath), "/")
+
+	switch {
+	case name == "" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, h.store.ListScopes())
+	case name == "" && r.Method == http.MethodPost:
+		h.storeScope(w, r)
+	case name != "" && r.Method == http.MethodGet:
+		scope, exists := h.store.GetScope(name)
+		if !exists {
+			http.Error(w, "Scope not found", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, scope)
+	case name != "" && r.Method == http.MethodDelete:
+		if !h.store.RemoveScope(name) {
+			http.Error(w, "Scope not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Removed scope %s", sanitizeLog(name)) // #nosec G706 -- sanitizeLog strips CR/LF to prevent log injection
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// storeScope decodes a scope from the request body and defines it
+func (h *ScopesHandler) storeScope(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20)) // limit request body to 1MB
+	if err != nil {
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	var scope models.Scope
+	if err := json.Unmarshal(body, &scope); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := scope.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.store.StoreScope(&scope)
+	log.Printf("Defined scope %s", sanitizeLog(scope.Name)) // #nosec G706 -- sanitizeLog strips CR/LF to prevent log injection
+
+	writeJSON(w, http.StatusCreated, &scope)
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/jwt"
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/models"
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/store"
+)
+
+func TestScopesHandler(t *testing.T) {
+	memoryStore := store.NewMemoryStore()
+	handler := NewScopesHandler(memoryStore)
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for _, body := range []string{
+		`{"claims":["groups"]}`,
+		`{"name":"groups"}`,
+		`{"name":"email","claims":["groups"]}`,
+		`{"name":"groups","claims":["sub"]}`,
+		`not json`,
+	} {
+		if rr := serve(http.MethodPost, "/admin/scopes", body); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d for %s, got %d", http.StatusBadRequest, body, rr.Code)
+		}
+	}
+
+	rr := serve(http.MethodPost, "/admin/scopes", `{"name":"groups","claims":["groups"]}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	serve(http.MethodPost, "/admin/scopes", `{"name":"contact","claims":["email","phone_number"]}`)
+
+	rr = serve(http.MethodGet, "/admin/scopes", "")
+	var scopes []models.Scope
+	if err := json.NewDecoder(rr.Body).Decode(&scopes); err != nil {
+		t.Fatalf("Error decoding scopes: %v", err)
+	}
+	if len(scopes) != 2 || scopes[0].Name != "contact" || scopes[1].Name != "groups" {
+		t.Fatalf("expected scopes contact and groups, got %+v", scopes)
+	}
+
+	rr = serve(http.MethodGet, "/admin/scopes/contact", "")
+	var contact models.Scope
+	if err := json.NewDecoder(rr.Body).Decode(&contact); err != nil {
+		t.Fatalf("Error decoding scope: %v", err)
+	}
+	if len(contact.Claims) != 2 || contact.Claims[1] != "phone_number" {
+		t.Errorf("unexpected scope %+v", contact)
+	}
+
+	if rr := serve(http.MethodDelete, "/admin/scopes/contact", ""); rr.Code != http.StatusNoContent {
+		t.Errorf("expected status %d, got %d", http.StatusNoContent, rr.Code)
+	}
+	if rr := serve(http.MethodGet, "/admin/scopes/contact", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("expected status %d after delete, got %d", http.StatusNotFound, rr.Code)
+	}
+	if rr := serve(http.MethodPut, "/admin/scopes", ""); rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status %d, got %d", http.StatusMethodNotAllowed, rr.Code)
+	}
+}
+
+func TestTokenHandler_IDTokenScopeClaims(t *testing.T) {
+	if err := jwt.InitKeys(); err != nil {
+		t.Fatalf("Failed to initialize keys: %v", err)
+	}
+
+	mockStore := store.NewMemoryStore()
+	mockStore.StoreUser(&models.UserInfo{
+		Sub:           "alice",
+		ID:            "alice",
+		Name:          "Alice",
+		GivenName:     "Alice",
+		Email:         "alice@example.com",
+		EmailVerified: true,
+		PhoneNumber:   "+15551234567",
+	})
+	mockStore.StoreScope(&models.Scope{Name: "contact", Claims: []string{"phone_number"}})
+	handler := NewTokenHandler(mockStore)
+
+	tests := []struct {
+		scope  string
+		want   []string
+		absent []string
+	}{
+		{scope: "openid", absent: []string{"email", "name", "phone_number", "id"}},
+		{scope: "openid email", want: []string{"email", "email_verified"}, absent: []string{"name", "given_name"}},
+		{scope: "openid profile contact", want: []string{"name", "given_name", "phone_number"}, absent: []string{"email"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.scope, func(t *testing.T) {
+			mockStore.StoreAuthCode("alice-code", &models.AuthRequest{
+				ClientID:    "test-client",
+				RedirectURI: "http://example.com/callback",
+				Scope:       tt.scope,
+				UserID:      "alice",
+			})
+			rr := postTokenRequest(handler, url.Values{
+				"grant_type":   {"authorization_code"},
+				"code":         {"alice-code"},
+				"client_id":    {"test-client"},
+				"redirect_uri": {"http://example.com/callback"},
+			})
+			if rr.Code != http.StatusOK {
+				t.Fatalf("code exchange failed: status %d, body %s", rr.Code, rr.Body.String())
+			}
+			var response models.TokenResponse
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("Error decoding response: %v", err)
+			}
+
+			claims, err := jwt.VerifyToken(response.IDToken)
+			if err != nil {
+				t.Fatalf("Failed to verify ID token: %v", err)
+			}
+			if claims["sub"] != "alice" {
+				t.Errorf("Expected sub alice, got %v", claims["sub"])
+			}
+			for _, claim := range tt.want {
+				if _, ok := claims[claim]; !ok {
+					t.Errorf("Expected claim %s, got %v", claim, claims)
+				}
+			}
+			for _, claim := range tt.absent {
+				if _, ok := claims[claim]; ok {
+					t.Errorf("Expected no %s claim, got %v", claim, claims)
+				}
+			}
+		})
+	}
+}
@@ -10,8 +10,16 @@ import (
 	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/jwt"
 	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/models"
 	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/store"
+
+	"github.com/google/uuid"
 )
 
+// accessTokenLifetime is how long issued access tokens remain valid
+const accessTokenLifetime = time.Hour
+
+// deviceCodeGrantType is the RFC 8628 grant type used to poll for device tokens
+const deviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
 // TokenHandler handles OAuth2 token exchange requests
 type TokenHandler struct {
 	store     store.Store
@@ -69,68 +77,361 @@ func (h *TokenHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Extract parameters
-	grantType := r.FormValue("grant_type")
+	switch r.FormValue("grant_type") {
+	case "authorization_code":
+		h.handleAuthorizationCode(w, r)
+	case "refresh_token":
+		h.handleRefreshToken(w, r)
+	case "client_credentials":
+		h.handleClientCredentials(w, r)
+	case deviceCodeGrantType:
+		h.handleDeviceCode(w, r)
+	default:
+		writeOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "Unsupported grant type")
+	}
+}
+
+// handleAuthorizationCode exchanges an authorization code for a new token family
+func (h *TokenHandler) handleAuthorizationCode(w http.ResponseWriter, r *http.Request) {
 	code := r.FormValue("code")
-	clientID := r.FormValue("client_id")
 	redirectURI := r.FormValue("redirect_uri")
+	_, clientSecret := clientCredentials(r)
 
-	// Validate grant type
-	if grantType != "authorization_code" {
-		http.Error(w, "Unsupported grant type", http.StatusBadRequest)
+	clientID, client, ok := identifyClient(h.store, r)
+	if !ok {
+		writeInvalidClient(w, r, "Client authentication failed")
+		return
+	}
+	if !allowsGrantType(w, client, "authorization_code") {
 		return
 	}
 
 	// Look up authorization code
 	authRequest, exists := h.store.GetAuthCode(code)
 	if !exists {
-		http.Error(w, "Invalid authorization code", http.StatusBadRequest)
+		writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "Invalid authorization code")
+		return
+	}
+
+	// A replayed code revokes every token issued from it (RFC 6749 section 10.5)
+	if authRequest.Used {
+		h.store.RevokeTokenFamily(authRequest.FamilyID)
+		log.Printf("Authorization code replayed by client %s; revoked tokens issued from it", sanitizeLog(clientID)) // #nosec G706 -- sanitizeLog strips CR/LF to prevent log injection
+		writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "Authorization code has already been used")
+		return
+	}
+
+	if authRequest.Expired() {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "Authorization code has expired")
 		return
 	}
 
 	// Validate client ID
 	if authRequest.ClientID != clientID {
-		http.Error(w, "Client ID mismatch", http.StatusBadRequest)
+		writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "Client ID mismatch")
 		return
 	}
 
 	// Validate redirect URI
 	if authRequest.RedirectURI != redirectURI {
-		http.Error(w, "Redirect URI mismatch", http.StatusBadRequest)
+		writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "Redirect URI mismatch")
+		return
+	}
+
+	// Verify the PKCE code_verifier against the challenge sent to /authorize
+	if authRequest.CodeChallenge != "" {
+		if !verifyCodeChallenge(r.FormValue("code_verifier"), authRequest.CodeChallenge, authRequest.CodeChallengeMethod) {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "PKCE verification failed")
+			return
+		}
+	} else if isPublicClient(client, clientSecret) && h.store.GetSettings().RequirePKCE {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "PKCE is required for public clients")
+		return
+	}
+
+	// Claim the code atomically so parallel redemptions issue at most one token family
+	familyID := uuid.New().String()
+	if !h.store.RedeemAuthCode(code, familyID) {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "Authorization code has already been used")
+		return
+	}
+
+	grant := h.startTokenFamily(familyID, clientID, authRequest.UserID, authRequest.Scope, authRequest.AuthTime, authRequest.Claims)
+	h.issueTokens(w, grant, "authorization_code", authRequest.Scope, authRequest.Nonce)
+}
+
+// handleDeviceCode answers a device polling for the outcome of an RFC 8628
+// device authorization
+func (h *TokenHandler) handleDeviceCode(w http.ResponseWriter, r *http.Request) {
+	deviceCode := r.FormValue("device_code")
+
+	clientID, client, ok := identifyClient(h.store, r)
+	if !ok {
+		writeInvalidClient(w, r, "Client authentication failed")
+		return
+	}
+	if !allowsGrantType(w, client, deviceCodeGrantType) {
+		return
+	}
+
+	// Record the poll and claim an approved authorization in a single store update,
+	// so a device code can only ever be redeemed once
+	slowDown := false
+	redeemed := false
+	authorization, exists := h.store.UpdateDeviceAuthorization(deviceCode, func(a *models.DeviceAuthorization) {
+		if a.ClientID != clientID {
+			return
+		}
+		now := time.Now()
+		if !a.LastPolledAt.IsZero() && now.Sub(a.LastPolledAt) < a.Interval {
+			// RFC 8628 section 3.5: back off by five seconds on every early poll
+			a.Interval += 5 * time.Second
+			slowDown = true
+		}
+		a.LastPolledAt = now
+		if a.Status == models.DeviceStatusApproved && !a.Expired() {
+			a.Status = models.DeviceStatusRedeemed
+			redeemed = true
+		}
+	})
+
+	switch {
+	case !exists || authorization.ClientID != clientID:
+		writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "Invalid device code")
+	case authorization.Expired():
+		writeOAuthError(w, http.StatusBadRequest, "expired_token", "The device code has expired")
+	case redeemed:
+		grant := h.startTokenFamily(uuid.New().String(), clientID, authorization.UserID, authorization.Scope, authorization.AuthTime, nil)
+		h.issueTokens(w, grant, deviceCodeGrantType, authorization.Scope, "")
+	case authorization.Status == models.DeviceStatusDenied:
+		writeOAuthError(w, http.StatusBadRequest, "access_denied", "The user denied the authorization request")
+	case authorization.Status == models.DeviceStatusRedeemed:
+		writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "The device code has already been used")
+	case slowDown:
+		writeOAuthError(w, http.StatusBadRequest, "slow_down", "Polling too frequently")
+	default:
+		writeOAuthError(w, http.StatusBadRequest, "authorization_pending", "The user has not yet completed authorization")
+	}
+}
+
+// handleRefreshToken redeems a refresh token for a new access token and ID token.
+// Depending on the server settings the refresh token is rotated, and a replayed
+// refresh token revokes its whole family.
+func (h *TokenHandler) handleRefreshToken(w http.ResponseWriter, r *http.Request) {
+	refreshToken := r.FormValue("refresh_token")
+	clientID := requestClientID(r)
+
+	if refreshToken == "" {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Missing refresh_token parameter")
+		return
+	}
+
+	record, exists := h.store.GetRefreshToken(refreshToken)
+	if !exists || record.Expired() {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "Invalid refresh token")
+		return
+	}
+
+	if clientID != "" && clientID != record.ClientID {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "Refresh token was issued to another client")
+		return
+	}
+
+	// A client that leaves out client_id is the one the refresh token was issued to
+	client, ok := verifyRequestClient(h.store, r, record.ClientID, false)
+	if !ok {
+		writeInvalidClient(w, r, "Client authentication failed")
+		return
+	}
+	if !allowsGrantType(w, client, "refresh_token") {
+		return
+	}
+
+	// The client may narrow, but never widen, the originally granted scope
+	scope := record.Scope
+	if requestedScope := r.FormValue("scope"); requestedScope != "" {
+		if !scopeSubset(requestedScope, record.Scope) {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_scope", "Requested scope exceeds the original grant")
+			return
+		}
+		scope = requestedScope
+	}
+
+	settings := h.store.GetSettings()
+	if record.Used || (settings.RefreshTokenRotation && !h.store.MarkRefreshTokenUsed(refreshToken)) {
+		if settings.RefreshTokenReuseDetection {
+			log.Printf("Refresh token reuse detected for client %s, revoking token family", sanitizeLog(record.ClientID)) // #nosec G706 -- sanitizeLog strips CR/LF to prevent log injection
+			h.store.RevokeTokenFamily(record.FamilyID)
+		}
+		writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "Refresh token has already been used")
+		return
+	}
+
+	if settings.RefreshTokenRotation {
+		rotated := *record
+		rotated.Token = generateRefreshToken()
+		rotated.IssuedAt = time.Now()
+		rotated.Used = false
+		h.store.StoreRefreshToken(&rotated)
+		record = &rotated
+	}
+
+	// Refreshed ID tokens keep the original auth_time but carry no nonce (OIDC Core section 12.2)
+	h.issueTokens(w, record, "refresh_token", scope, "")
+}
+
+// handleClientCredentials issues a machine access token whose subject is the
+// authenticated client itself. No ID token or refresh token is returned.
+func (h *TokenHandler) handleClientCredentials(w http.ResponseWriter, r *http.Request) {
+	clientID, client, ok := authenticateClient(h.store, r)
+	if !ok {
+		writeInvalidClient(w, r, "Client authentication failed")
+		return
+	}
+	if !allowsGrantType(w, client, "client_credentials") {
 		return
 	}
 
-	// Generate token response
-	accessToken, err := generateAccessToken(h.issuerURL, clientID, authRequest.Scope)
+	scope := r.FormValue("scope")
+	if client != nil {
+		if !client.AllowsScope(scope) {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_scope", "Requested scope is not allowed for this client")
+			return
+		}
+		// Without an explicit request the client receives all of its allowed scopes
+		if scope == "" {
+			scope = strings.Join(client.AllowedScopes, " ")
+		}
+	}
+
+	claims := h.customClaims(clientID, "").AccessToken
+	applyClaimRules(h.store, claims, models.RuleTargetAccessToken, h.ruleVariables(clientID, "", scope, "client_credentials"))
+
+	now := time.Now()
+	accessToken, err := jwt.GenerateAccessTokenWithOptions(h.issuerURL, clientID, clientID, strings.Fields(scope), jwt.AccessTokenOptions{
+		Signing: h.signingOptions(clientID, false),
+		Claims:  claims,
+	})
+	if err != nil {
+		log.Printf("Error generating access token: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	h.store.StoreAccessToken(&models.TokenRecord{
+		Token:     accessToken,
+		ClientID:  clientID,
+		Subject:   clientID,
+		Scope:     scope,
+		GrantType: "client_credentials",
+		IssuedAt:  now,
+		ExpiresAt: now.Add(accessTokenLifetime),
+	})
+
+	writeTokenResponse(w, models.TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(accessTokenLifetime.Seconds()),
+		Scope:       scope,
+	})
+}
+
+// startTokenFamily creates and returns the refresh token record for a new grant.
+// Every token issued from the grant, including later refreshes, shares its family
+// ID and the claims requested with the claims parameter.
+func (h *TokenHandler) startTokenFamily(familyID, clientID, userID, scope string, authTime time.Time, claims *models.ClaimsRequest) *models.TokenRecord {
+	grant := models.TokenRecord{
+		Token:    generateRefreshToken(),
+		ClientID: clientID,
+		UserID:   userID,
+		Subject:  h.grantSubject(clientID, userID),
+		Scope:    scope,
+		FamilyID: familyID,
+		IssuedAt: time.Now(),
+		AuthTime: authTime,
+		Claims:   claims,
+	}
+	h.store.StoreRefreshToken(&grant)
+	return &grant
+}
+
+// issueTokens mints an access token and ID token for the grant described by a
+// refresh token record, records the access token in the grant's family and
+// writes the token response. A non-empty nonce is echoed in the ID token.
+func (h *TokenHandler) issueTokens(w http.ResponseWriter, grant *models.TokenRecord, grantType, scope, nonce string) {
+	// Claims computed by rules take precedence over the custom claims, which
+	// take precedence over the user's groups and roles in the access token
+	claims := h.customClaims(grant.ClientID, grant.UserID)
+	accessTokenClaims := h.membershipClaims(grant.ClientID, grant.UserID, scope)
+	for claim, value := range claims.AccessToken {
+		accessTokenClaims[claim] = value
+	}
+	claims.AccessToken = accessTokenClaims
+	variables := h.ruleVariables(grant.ClientID, grant.UserID, scope, grantType)
+	applyClaimRules(h.store, claims.AccessToken, models.RuleTargetAccessToken, variables)
+	applyClaimRules(h.store, claims.IDToken, models.RuleTargetIDToken, variables)
+
+	accessToken, err := generateAccessToken(h.issuerURL, grant.ClientID, grant.Subject, scope, jwt.AccessTokenOptions{
+		Signing: h.signingOptions(grant.ClientID, false),
+		Claims:  claims.AccessToken,
+	})
 	if err != nil {
 		log.Printf("Error generating access token: %v", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
-	idToken, err := h.generateIDToken(h.issuerURL, clientID)
+	idToken, err := h.generateIDToken(h.issuerURL, grant, scope, claims.IDToken, jwt.IDTokenOptions{
+		Nonce:       nonce,
+		AccessToken: accessToken,
+		AuthTime:    grant.AuthTime,
+		Signing:     h.signingOptions(grant.ClientID, true),
+	})
 	if err != nil {
 		log.Printf("Error generating ID token: %v", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
-	tokenResponse := models.TokenResponse{
-		AccessToken:  accessToken,
-		TokenType:    "Bearer",
-		ExpiresIn:    3600,
-		RefreshToken: generateRefreshToken(clientID),
-		IDToken:      idToken,
+	// Clients that registered an encryption algorithm receive a nested JWE
+	if client, exists := h.store.GetClient(grant.ClientID); exists && client.IDTokenEncryptedResponseAlg != "" {
+		idToken, err = encryptForClient(client, []byte(idToken), client.IDTokenEncryptedResponseAlg, client.IDTokenEncryptedResponseEnc, "JWT")
+		if err != nil {
+			log.Printf("Error encrypting ID token: %v", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
 	}
 
 	// Store the token in the store for future validation
-	h.store.StoreToken(tokenResponse.AccessToken, clientID)
+	now := time.Now()
+	h.store.StoreAccessToken(&models.TokenRecord{
+		Token:     accessToken,
+		ClientID:  grant.ClientID,
+		UserID:    grant.UserID,
+		Subject:   grant.Subject,
+		Scope:     scope,
+		GrantType: grantType,
+		FamilyID:  grant.FamilyID,
+		IssuedAt:  now,
+		ExpiresAt: now.Add(accessTokenLifetime),
+		Claims:    grant.Claims,
+	})
 
-	// Remove the used authorization code
-	h.store.RemoveAuthCode(code)
+	writeTokenResponse(w, models.TokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(accessTokenLifetime.Seconds()),
+		RefreshToken: grant.Token,
+		IDToken:      idToken,
+		Scope:        scope,
+	})
+}
 
-	// Return token response
+// writeTokenResponse writes a successful token endpoint response
+func writeTokenResponse(w http.ResponseWriter, tokenResponse models.TokenResponse) {
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
 	if err := json.NewEncoder(w).Encode(tokenResponse); err != nil { // #nosec G117 -- OAuth2 token endpoint must marshal access_token
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		// Log the error for debugging purposes
@@ -139,45 +440,169 @@ func (h *TokenHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// subjectFor returns the subject identifier for a grant. Grants bound to a
+// registered user use that user's sub; others derive a mock subject from the client.
+func subjectFor(clientID, userID string) string {
+	if userID != "" {
+		return userID
+	}
+	return "user-" + clientID
+}
+
+// grantSubject returns the sub of a new grant: the local subject identifier, or
+// its pairwise identifier for clients with the pairwise subject type
+func (h *TokenHandler) grantSubject(clientID, userID string) string {
+	client, _ := h.store.GetClient(clientID)
+	return client.Subject(subjectFor(clientID, userID), h.store.GetSettings().PairwiseSalt)
+}
+
+// customClaims returns the custom claims of the tokens issued to a client for
+// a user: the global custom claims, overridden by the client's, overridden by
+// the user's. The user ID is empty for grants without a registered user.
+func (h *TokenHandler) customClaims(clientID, userID string) models.CustomClaims {
+	levels := []*models.CustomClaims{h.store.GetCustomClaims()}
+	if client, exists := h.store.GetClient(clientID); exists {
+		levels = append(levels, client.CustomClaims)
+	}
+	if user, exists := h.store.GetUser(userID); exists {
+		levels = append(levels, user.CustomClaims)
+	}
+	return models.MergeCustomClaims(levels...)
+}
+
+// membershipClaims returns the groups and roles claims of the access tokens
+// issued to a client for a user. They are released by the granted scope like
+// the ID token's, and the groups overage applies.
+func (h *TokenHandler) membershipClaims(clientID, userID, scope string) map[string]interface{} {
+	membership := make(map[string]interface{})
+	user, exists := h.store.GetUser(userID)
+	if !exists {
+		return membership
+	}
+	userClaims := user.ClaimsFor(clientID)
+	for _, claim := range []string{"groups", "roles"} {
+		if value, exists := userClaims[claim]; exists {
+			membership[claim] = value
+		}
+	}
+	membership = models.FilterClaims(membership, scope, h.store.ListScopes())
+	models.ApplyGroupsOverage(membership, h.store.GetSettings().GroupsOverageThreshold, groupsEndpoint(h.issuerURL))
+	return membership
+}
+
+// ruleVariables returns the variables claim rules are evaluated with for a
+// grant issued at the token endpoint
+func (h *TokenHandler) ruleVariables(clientID, userID, scope, grantType string) map[string]interface{} {
+	user, _ := h.store.GetUser(userID)
+	client, _ := h.store.GetClient(clientID)
+	return ruleVariables(user, clientID, client, scope, map[string]interface{}{"endpoint": "token", "grant_type": grantType})
+}
+
+// scopeSubset reports whether every scope in requested is also present in granted
+func scopeSubset(requested, granted string) bool {
+	grantedScopes := make(map[string]bool)
+	for _, scope := range strings.Fields(granted) {
+		grantedScopes[scope] = true
+	}
+	for _, scope := range strings.Fields(requested) {
+		if !grantedScopes[scope] {
+			return false
+		}
+	}
+	return true
+}
+
+// signingOptions returns how tokens for a client are signed, using the
+// algorithm the client registered for the token type
+func (h *TokenHandler) signingOptions(clientID string, idToken bool) jwt.SigningOptions {
+	client, exists := h.store.GetClient(clientID)
+	if !exists {
+		return clientSigningOptions(h.store, nil, "")
+	}
+	if idToken {
+		return clientSigningOptions(h.store, client, client.IDTokenSignedResponseAlg)
+	}
+	return clientSigningOptions(h.store, client, client.AccessTokenSignedResponseAlg)
+}
+
+// clientSigningOptions returns how a response for a client is signed: with the
+// algorithm the client registered, else the server default. HS256 signs with
+// the client secret, so clients without one fall back to RS256. The client may
+// be nil for unregistered clients.
+func clientSigningOptions(s store.Store, client *models.Client, registeredAlg string) jwt.SigningOptions {
+	signing := jwt.SigningOptions{Alg: s.GetSettings().SigningAlg}
+	if registeredAlg != "" {
+		signing.Alg = registeredAlg
+	}
+	if client != nil {
+		if secrets := client.Secrets(); len(secrets) > 0 {
+			signing.Secret = secrets[0]
+		}
+	}
+	if signing.Alg == "HS256" && signing.Secret == "" {
+		signing.Alg = jwt.DefaultSigningAlgorithm
+	}
+	return signing
+}
+
 // Helper function to generate a mock access token
-func generateAccessToken(issuerURL, clientID, scope string) (string, error) {
+func generateAccessToken(issuerURL, clientID, sub, scope string, opts jwt.AccessTokenOptions) (string, error) {
 	// Parse scopes from the scope string
 	scopes := strings.Fields(scope)
 	if len(scopes) == 0 {
 		scopes = []string{"openid"}
 	}
 
-	// Generate a subject ID based on client ID
-	sub := "user-" + clientID
-
-	return jwt.GenerateAccessToken(issuerURL, clientID, sub, scopes)
+	return jwt.GenerateAccessTokenWithOptions(issuerURL, clientID, sub, scopes, opts)
 }
 
-// Helper function to generate a mock refresh token
-func generateRefreshToken(clientID string) string {
-	return "mock-refresh-token-" + clientID + "-" + time.Now().Format("20060102150405")
+// Helper function to generate an opaque refresh token
+func generateRefreshToken() string {
+	return "mock-refresh-token-" + uuid.New().String()
 }
 
-// Helper function to generate a mock ID token
-func (h *TokenHandler) generateIDToken(issuerURL, clientID string) (string, error) {
-	// Generate a subject ID based on client ID
-	sub := "user-" + clientID
+// Helper function to generate a mock ID token. It carries the user claims
+// released by the granted scope or requested with the claims parameter, and
+// the custom and rule-computed claims, which take precedence over them.
+func (h *TokenHandler) generateIDToken(issuerURL string, grant *models.TokenRecord, scope string, customClaims map[string]interface{}, opts jwt.IDTokenOptions) (string, error) {
+	userClaims := make(map[string]interface{})
 
-	// Check if there's a configured email in the token config
-	var email string
-	var name string
-	tokenConfig := h.store.GetTokenConfig()
-	if tokenConfig != nil {
+	// Tokens bound to a registered user carry that user's claims
+	if user, exists := h.store.GetUser(grant.UserID); exists {
+		userClaims = user.ClaimsFor(grant.ClientID)
+		// The grant's subject is the sub, and id is a userinfo alias of it
+		delete(userClaims, "sub")
+		delete(userClaims, "id")
+	} else if tokenConfig := h.store.GetTokenConfig(); tokenConfig != nil {
+		// Otherwise use the configured email and name, if any (don't default to a generated email)
 		if userInfoConfig, ok := tokenConfig["user_info"].(map[string]interface{}); ok {
-			if configuredEmail, ok := userInfoConfig["email"].(string); ok {
-				email = configuredEmail
+			if configuredEmail, ok := userInfoConfig["email"].(string); ok && configuredEmail != "" {
+				userClaims["email"] = configuredEmail
 			}
-			if configuredName, ok := userInfoConfig["name"].(string); ok {
-				name = configuredName
+			if configuredName, ok := userInfoConfig["name"].(string); ok && configuredName != "" {
+				userClaims["name"] = configuredName
 			}
 		}
 	}
 
-	// If no email is configured, pass empty string (don't default to generated email)
-	return jwt.GenerateIDToken(issuerURL, clientID, sub, email, name)
+	var requested map[string]*models.ClaimRequest
+	if grant.Claims != nil {
+		requested = grant.Claims.IDToken
+	}
+	opts.Claims = models.ReleasedClaims(userClaims, scope, h.store.ListScopes(), requested)
+	models.ApplyGroupsOverage(opts.Claims, h.store.GetSettings().GroupsOverageThreshold, groupsEndpoint(issuerURL))
+	for claim, value := range customClaims {
+		opts.Claims[claim] = value
+	}
+
+	// The mock satisfies any requested authentication context class
+	if acr := requested["acr"]; acr != nil {
+		if acr.Value != nil {
+			opts.Claims["acr"] = acr.Value
+		} else if len(acr.Values) > 0 {
+			opts.Claims["acr"] = acr.Values[0]
+		}
+	}
+
+	return jwt.GenerateIDTokenWithOptions(issuerURL, grant.ClientID, grant.Subject, "", "", opts)
 }
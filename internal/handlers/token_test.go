@@ -1,16 +1,22 @@
 package handlers
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/jwt"
 	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/models"
 	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/store"
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/types"
 	jwtlib "github.com/golang-jwt/jwt/v5"
 )
 
@@ -124,3 +130,770 @@ func TestTokenHandler(t *testing.T) {
 		t.Error("Access token should have 'alg' header")
 	}
 }
+
+// postTokenRequest sends a form-encoded request to the token handler
+func postTokenRequest(handler http.Handler, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/token", strings.NewReader(form.Encode()))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+// exchangeTestCode stores an authorization code and redeems it for tokens
+func exchangeTestCode(t *testing.T, handler http.Handler, mockStore *store.MemoryStore, scope string) models.TokenResponse {
+	t.Helper()
+
+	mockStore.StoreAuthCode("refresh-code", &models.AuthRequest{
+		ClientID:    "test-client",
+		RedirectURI: "http://example.com/callback",
+		Scope:       scope,
+	})
+
+	rr := postTokenRequest(handler, url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {"refresh-code"},
+		"client_id":    {"test-client"},
+		"redirect_uri": {"http://example.com/callback"},
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("code exchange failed: status %d, body %s", rr.Code, rr.Body.String())
+	}
+
+	var response models.TokenResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+	if response.RefreshToken == "" {
+		t.Fatal("Expected refresh token to be present")
+	}
+	return response
+}
+
+// decodeOAuthError decodes the error code from a JSON error response
+func decodeOAuthError(t *testing.T, rr *httptest.ResponseRecorder) string {
+	t.Helper()
+
+	var errorResponse map[string]string
+	if err := json.NewDecoder(rr.Body).Decode(&errorResponse); err != nil {
+		t.Fatalf("Error decoding error response: %v", err)
+	}
+	return errorResponse["error"]
+}
+
+func TestTokenHandler_RefreshToken(t *testing.T) {
+	mockStore := store.NewMemoryStore()
+	handler := NewTokenHandler(mockStore)
+	initial := exchangeTestCode(t, handler, mockStore, "openid email")
+
+	rr := postTokenRequest(handler, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {initial.RefreshToken},
+		"client_id":     {"test-client"},
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var response models.TokenResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+	if response.AccessToken == "" || response.IDToken == "" {
+		t.Error("Expected new access and ID tokens")
+	}
+	if response.RefreshToken != initial.RefreshToken {
+		t.Errorf("Expected refresh token to be reused without rotation, got %s", response.RefreshToken)
+	}
+	if response.Scope != "openid email" {
+		t.Errorf("Expected scope 'openid email', got %q", response.Scope)
+	}
+	if _, exists := mockStore.GetClientIDByToken(response.AccessToken); !exists {
+		t.Error("Expected refreshed access token to be stored")
+	}
+
+	// Refresh tokens are bound to the client they were issued to
+	rr = postTokenRequest(handler, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {initial.RefreshToken},
+		"client_id":     {"other-client"},
+	})
+	if rr.Code != http.StatusBadRequest || decodeOAuthError(t, rr) != "invalid_grant" {
+		t.Errorf("Expected invalid_grant for another client, got status %d", rr.Code)
+	}
+}
+
+func TestTokenHandler_RefreshTokenRotationReuseDetection(t *testing.T) {
+	mockStore := store.NewMemoryStore()
+	mockStore.StoreSettings(types.Settings{
+		RefreshTokenRotation:       true,
+		RefreshTokenReuseDetection: true,
+	})
+	handler := NewTokenHandler(mockStore)
+	initial := exchangeTestCode(t, handler, mockStore, "openid")
+
+	rr := postTokenRequest(handler, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {initial.RefreshToken},
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var rotated models.TokenResponse
+	if err := json.NewDecoder(rr.Body).Decode(&rotated); err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+	if rotated.RefreshToken == initial.RefreshToken {
+		t.Fatal("Expected a new refresh token after rotation")
+	}
+
+	// Replaying the rotated-out token revokes the whole family
+	rr = postTokenRequest(handler, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {initial.RefreshToken},
+	})
+	if rr.Code != http.StatusBadRequest || decodeOAuthError(t, rr) != "invalid_grant" {
+		t.Errorf("Expected invalid_grant on reuse, got status %d", rr.Code)
+	}
+
+	if _, exists := mockStore.GetRefreshToken(rotated.RefreshToken); exists {
+		t.Error("Expected rotated refresh token to be revoked")
+	}
+	for _, accessToken := range []string{initial.AccessToken, rotated.AccessToken} {
+		if _, exists := mockStore.GetClientIDByToken(accessToken); exists {
+			t.Error("Expected access tokens in the family to be revoked")
+		}
+	}
+}
+
+func TestTokenHandler_RefreshTokenScopeNarrowing(t *testing.T) {
+	mockStore := store.NewMemoryStore()
+	handler := NewTokenHandler(mockStore)
+	initial := exchangeTestCode(t, handler, mockStore, "openid email profile")
+
+	rr := postTokenRequest(handler, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {initial.RefreshToken},
+		"scope":         {"openid email"},
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var response models.TokenResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+	if response.Scope != "openid email" {
+		t.Errorf("Expected narrowed scope 'openid email', got %q", response.Scope)
+	}
+
+	rr = postTokenRequest(handler, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {initial.RefreshToken},
+		"scope":         {"openid admin"},
+	})
+	if rr.Code != http.StatusBadRequest || decodeOAuthError(t, rr) != "invalid_scope" {
+		t.Errorf("Expected invalid_scope when widening the grant, got status %d", rr.Code)
+	}
+}
+
+func TestTokenHandler_IDTokenNonceAndAuthTime(t *testing.T) {
+	if err := jwt.InitKeys(); err != nil {
+		t.Fatalf("Failed to initialize keys: %v", err)
+	}
+
+	mockStore := store.NewMemoryStore()
+	authorizeHandler := &AuthorizeHandler{Store: mockStore}
+	handler := NewTokenHandler(mockStore)
+
+	query := url.Values{
+		"client_id":     {"test-client"},
+		"redirect_uri":  {"http://example.com/callback"},
+		"scope":         {"openid"},
+		"response_type": {"code"},
+		"nonce":         {"client-nonce-123"},
+	}
+	rr := httptest.NewRecorder()
+	authorizeHandler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/authorize?"+query.Encode(), nil))
+	location, err := url.Parse(rr.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("Error parsing redirect: %v", err)
+	}
+
+	rr = postTokenRequest(handler, url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {location.Query().Get("code")},
+		"client_id":    {"test-client"},
+		"redirect_uri": {"http://example.com/callback"},
+	})
+	var response models.TokenResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+	claims, err := jwt.VerifyToken(response.IDToken)
+	if err != nil {
+		t.Fatalf("Error verifying ID token: %v", err)
+	}
+	if claims["nonce"] != "client-nonce-123" {
+		t.Errorf("expected nonce to be echoed, got %v", claims["nonce"])
+	}
+	if claims["at_hash"] != jwt.AccessTokenHash(response.AccessToken) {
+		t.Errorf("expected at_hash of the issued access token, got %v", claims["at_hash"])
+	}
+	if claims["azp"] != "test-client" {
+		t.Errorf("expected azp test-client, got %v", claims["azp"])
+	}
+	authTime := claims["auth_time"]
+
+	// A refreshed ID token keeps auth_time and drops the nonce
+	rr = postTokenRequest(handler, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {response.RefreshToken},
+		"client_id":     {"test-client"},
+	})
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+	claims, err = jwt.VerifyToken(response.IDToken)
+	if err != nil {
+		t.Fatalf("Error verifying ID token: %v", err)
+	}
+	if _, exists := claims["nonce"]; exists {
+		t.Errorf("expected no nonce after refresh, got %v", claims["nonce"])
+	}
+	if claims["auth_time"] != authTime {
+		t.Errorf("expected auth_time %v after refresh, got %v", authTime, claims["auth_time"])
+	}
+}
+
+func TestTokenHandler_AuthorizationCodeExpiry(t *testing.T) {
+	mockStore := store.NewMemoryStore()
+	mockStore.StoreAuthCode("expired-code", &models.AuthRequest{
+		ClientID:    "test-client",
+		RedirectURI: "http://example.com/callback",
+		Expiration:  time.Now().Add(-time.Minute),
+	})
+	handler := NewTokenHandler(mockStore)
+
+	rr := postTokenRequest(handler, url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {"expired-code"},
+		"client_id":    {"test-client"},
+		"redirect_uri": {"http://example.com/callback"},
+	})
+	if rr.Code != http.StatusBadRequest || decodeOAuthError(t, rr) != "invalid_grant" {
+		t.Errorf("expected invalid_grant for expired code, got status %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestTokenHandler_AuthorizationCodeReplayRevokesTokens(t *testing.T) {
+	mockStore := store.NewMemoryStore()
+	handler := NewTokenHandler(mockStore)
+
+	response := exchangeTestCode(t, handler, mockStore, "openid")
+
+	// Presenting the same code again fails and revokes everything issued from it
+	rr := postTokenRequest(handler, url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {"refresh-code"},
+		"client_id":    {"test-client"},
+		"redirect_uri": {"http://example.com/callback"},
+	})
+	if rr.Code != http.StatusBadRequest || decodeOAuthError(t, rr) != "invalid_grant" {
+		t.Fatalf("expected invalid_grant for replayed code, got status %d: %s", rr.Code, rr.Body.String())
+	}
+	if _, exists := mockStore.GetAccessToken(response.AccessToken); exists {
+		t.Errorf("expected access token to be revoked after code replay")
+	}
+	if _, exists := mockStore.GetRefreshToken(response.RefreshToken); exists {
+		t.Errorf("expected refresh token to be revoked after code replay")
+	}
+}
+
+func TestTokenHandler_AuthorizationCodeParallelRedemption(t *testing.T) {
+	mockStore := store.NewMemoryStore()
+	mockStore.StoreAuthCode("parallel-code", &models.AuthRequest{
+		ClientID:    "test-client",
+		RedirectURI: "http://example.com/callback",
+	})
+	handler := NewTokenHandler(mockStore)
+
+	const attempts = 10
+	var wg sync.WaitGroup
+	var successes atomic.Int32
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rr := postTokenRequest(handler, url.Values{
+				"grant_type":   {"authorization_code"},
+				"code":         {"parallel-code"},
+				"client_id":    {"test-client"},
+				"redirect_uri": {"http://example.com/callback"},
+			})
+			if rr.Code == http.StatusOK {
+				successes.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if successes.Load() != 1 {
+		t.Errorf("expected exactly one successful redemption, got %d", successes.Load())
+	}
+}
+
+func TestTokenHandler_PKCE(t *testing.T) {
+	verifier := strings.Repeat("a1b2c3d4", 6)
+	sum := sha256.Sum256([]byte(verifier))
+	s256Challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	tests := []struct {
+		name           string
+		challenge      string
+		method         string
+		verifier       string
+		clientSecret   string
+		requirePKCE    bool
+		expectedStatus int
+	}{
+		{"S256 match", s256Challenge, "S256", verifier, "", false, http.StatusOK},
+		{"S256 mismatch", s256Challenge, "S256", strings.Repeat("z", 43), "", false, http.StatusBadRequest},
+		{"S256 missing verifier", s256Challenge, "S256", "", "", false, http.StatusBadRequest},
+		{"plain match", verifier, "plain", verifier, "", false, http.StatusOK},
+		{"verifier too short", "short", "plain", "short", "", false, http.StatusBadRequest},
+		{"no PKCE when optional", "", "", "", "", false, http.StatusOK},
+		{"no PKCE for public client when required", "", "", "", "", true, http.StatusBadRequest},
+		{"no PKCE for confidential client when required", "", "", "", "test-secret", true, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := store.NewMemoryStore()
+			mockStore.StoreSettings(types.Settings{RequirePKCE: tt.requirePKCE})
+			mockStore.StoreAuthCode("pkce-code", &models.AuthRequest{
+				ClientID:            "test-client",
+				RedirectURI:         "http://example.com/callback",
+				Scope:               "openid",
+				CodeChallenge:       tt.challenge,
+				CodeChallengeMethod: tt.method,
+			})
+			handler := NewTokenHandler(mockStore)
+
+			form := url.Values{
+				"grant_type":   {"authorization_code"},
+				"code":         {"pkce-code"},
+				"client_id":    {"test-client"},
+				"redirect_uri": {"http://example.com/callback"},
+			}
+			if tt.verifier != "" {
+				form.Set("code_verifier", tt.verifier)
+			}
+			if tt.clientSecret != "" {
+				form.Set("client_secret", tt.clientSecret)
+			}
+
+			rr := postTokenRequest(handler, form)
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if tt.expectedStatus == http.StatusBadRequest && decodeOAuthError(t, rr) != "invalid_grant" {
+				t.Error("expected invalid_grant error")
+			}
+		})
+	}
+}
+
+func TestTokenHandler_ClientCredentials(t *testing.T) {
+	mockStore := store.NewMemoryStore()
+	mockStore.StoreClient(&models.Client{
+		ClientID:      "service-a",
+		ClientSecret:  "service-secret",
+		AllowedScopes: []string{"read", "write"},
+	})
+	handler := NewTokenHandler(mockStore)
+
+	tests := []struct {
+		name           string
+		form           url.Values
+		basicAuth      []string
+		expectedStatus int
+		expectedError  string
+		expectedScope  string
+		expectedSub    string
+	}{
+		{
+			name:           "Unregistered client via POST body",
+			form:           url.Values{"client_id": {"service-b"}, "client_secret": {"any"}, "scope": {"anything"}},
+			expectedStatus: http.StatusOK,
+			expectedScope:  "anything",
+			expectedSub:    "service-b",
+		},
+		{
+			name:           "Registered client via Basic auth",
+			form:           url.Values{"scope": {"read"}},
+			basicAuth:      []string{"service-a", "service-secret"},
+			expectedStatus: http.StatusOK,
+			expectedScope:  "read",
+			expectedSub:    "service-a",
+		},
+		{
+			name:           "Registered client defaults to allowed scopes",
+			form:           url.Values{},
+			basicAuth:      []string{"service-a", "service-secret"},
+			expectedStatus: http.StatusOK,
+			expectedScope:  "read write",
+			expectedSub:    "service-a",
+		},
+		{
+			name:           "Scope outside the allowed scopes",
+			form:           url.Values{"scope": {"read admin"}},
+			basicAuth:      []string{"service-a", "service-secret"},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "invalid_scope",
+		},
+		{
+			name:           "Wrong secret",
+			form:           url.Values{},
+			basicAuth:      []string{"service-a", "wrong"},
+			expectedStatus: http.StatusUnauthorized,
+			expectedError:  "invalid_client",
+		},
+		{
+			name:           "Missing secret",
+			form:           url.Values{"client_id": {"service-b"}},
+			expectedStatus: http.StatusUnauthorized,
+			expectedError:  "invalid_client",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.form.Set("grant_type", "client_credentials")
+			req := httptest.NewRequest("POST", "/token", strings.NewReader(tt.form.Encode()))
+			req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+			if tt.basicAuth != nil {
+				req.SetBasicAuth(tt.basicAuth[0], tt.basicAuth[1])
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if tt.expectedError != "" {
+				if errorCode := decodeOAuthError(t, rr); errorCode != tt.expectedError {
+					t.Errorf("expected error %q, got %q", tt.expectedError, errorCode)
+				}
+				return
+			}
+
+			var response map[string]interface{}
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("Error decoding response: %v", err)
+			}
+			if _, exists := response["id_token"]; exists {
+				t.Error("client_credentials response must not contain an ID token")
+			}
+			if _, exists := response["refresh_token"]; exists {
+				t.Error("client_credentials response must not contain a refresh token")
+			}
+			if response["scope"] != tt.expectedScope {
+				t.Errorf("expected scope %q, got %v", tt.expectedScope, response["scope"])
+			}
+
+			claims, err := jwt.VerifyToken(response["access_token"].(string))
+			if err != nil {
+				t.Fatalf("Failed to verify access token: %v", err)
+			}
+			if claims["sub"] != tt.expectedSub {
+				t.Errorf("expected sub %q, got %v", tt.expectedSub, claims["sub"])
+			}
+		})
+	}
+}
+
+func TestTokenHandler_SigningAlgorithms(t *testing.T) {
+	if err := jwt.InitKeys(); err != nil {
+		t.Fatalf("Failed to initialize keys: %v", err)
+	}
+
+	// tokenAlg returns the alg header of a token
+	tokenAlg := func(t *testing.T, token string) string {
+		t.Helper()
+		parsed, _, err := jwtlib.NewParser().ParseUnverified(token, jwtlib.MapClaims{})
+		if err != nil {
+			t.Fatalf("Error parsing token: %v", err)
+		}
+		return parsed.Method.Alg()
+	}
+
+	t.Run("server default", func(t *testing.T) {
+		mockStore := store.NewMemoryStore()
+		mockStore.StoreSettings(types.Settings{SigningAlg: "ES256"})
+		handler := NewTokenHandler(mockStore)
+
+		response := exchangeTestCode(t, handler, mockStore, "openid")
+		for name, token := range map[string]string{"access token": response.AccessToken, "ID token": response.IDToken} {
+			if alg := tokenAlg(t, token); alg != "ES256" {
+				t.Errorf("Expected %s signed with ES256, got %s", name, alg)
+			}
+			if _, err := jwt.VerifyToken(token); err != nil {
+				t.Errorf("Expected %s to verify against the JWKS: %v", name, err)
+			}
+		}
+	})
+
+	t.Run("per client", func(t *testing.T) {
+		mockStore := store.NewMemoryStore()
+		mockStore.StoreSettings(types.Settings{SigningAlg: "PS256"})
+		mockStore.StoreClient(&models.Client{
+			ClientID:                 "legacy-app",
+			ClientSecret:             "legacy-secret",
+			IDTokenSignedResponseAlg: "HS256",
+		})
+		handler := NewTokenHandler(mockStore)
+
+		mockStore.StoreAuthCode("legacy-code", &models.AuthRequest{
+			ClientID:    "legacy-app",
+			RedirectURI: "http://example.com/callback",
+			Scope:       "openid",
+		})
+		rr := postTokenRequest(handler, url.Values{
+			"grant_type":    {"authorization_code"},
+			"code":          {"legacy-code"},
+			"client_id":     {"legacy-app"},
+			"client_secret": {"legacy-secret"},
+			"redirect_uri":  {"http://example.com/callback"},
+		})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("code exchange failed: status %d, body %s", rr.Code, rr.Body.String())
+		}
+		var response models.TokenResponse
+		if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+			t.Fatalf("Error decoding response: %v", err)
+		}
+
+		// The ID token is signed with the client secret, the access token with the server default
+		if _, err := jwtlib.Parse(response.IDToken, func(*jwtlib.Token) (interface{}, error) {
+			return []byte("legacy-secret"), nil
+		}, jwtlib.WithValidMethods([]string{"HS256"})); err != nil {
+			t.Errorf("Expected the ID token to verify with the client secret: %v", err)
+		}
+		if alg := tokenAlg(t, response.AccessToken); alg != "PS256" {
+			t.Errorf("Expected access token signed with PS256, got %s", alg)
+		}
+	})
+
+	t.Run("HS256 without a client secret", func(t *testing.T) {
+		mockStore := store.NewMemoryStore()
+		mockStore.StoreSettings(types.Settings{SigningAlg: "HS256"})
+		handler := NewTokenHandler(mockStore)
+
+		response := exchangeTestCode(t, handler, mockStore, "openid")
+		if alg := tokenAlg(t, response.IDToken); alg != jwt.DefaultSigningAlgorithm {
+			t.Errorf("Expected a client without a secret to fall back to %s, got %s", jwt.DefaultSigningAlgorithm, alg)
+		}
+	})
+}
+
+func TestTokenHandler_PairwiseSubject(t *testing.T) {
+	if err := jwt.InitKeys(); err != nil {
+		t.Fatalf("Failed to initialize keys: %v", err)
+	}
+
+	mockStore := store.NewMemoryStore()
+	mockStore.StoreUser(&models.UserInfo{Sub: "alice", ID: "alice", Email: "alice@example.com", Name: "Alice"})
+	for _, client := range []*models.Client{
+		{ClientID: "shop", RedirectURIs: []string{"https://shop.example.com/callback"}, SubjectType: models.SubjectTypePairwise},
+		{ClientID: "shop-admin", RedirectURIs: []string{"https://admin.example.com/callback"}, SubjectType: models.SubjectTypePairwise, SectorIdentifierURI: "https://shop.example.com/sector.json"},
+		{ClientID: "blog", RedirectURIs: []string{"https://blog.example.com/callback"}, SubjectType: models.SubjectTypePairwise},
+		{ClientID: "public", RedirectURIs: []string{"https://public.example.com/callback"}},
+	} {
+		mockStore.StoreClient(client)
+	}
+	handler := NewTokenHandler(mockStore)
+
+	// subjects returns the subs one client sees in its ID token, access token,
+	// userinfo response and introspection response
+	subjects := func(t *testing.T, clientID string) []interface{} {
+		t.Helper()
+		client, _ := mockStore.GetClient(clientID)
+		mockStore.StoreAuthCode("pairwise-code", &models.AuthRequest{
+			ClientID:    clientID,
+			RedirectURI: client.RedirectURIs[0],
+			Scope:       "openid email profile",
+			UserID:      "alice",
+		})
+		rr := postTokenRequest(handler, url.Values{
+			"grant_type":   {"authorization_code"},
+			"code":         {"pairwise-code"},
+			"client_id":    {clientID},
+			"redirect_uri": {client.RedirectURIs[0]},
+		})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("code exchange failed: status %d, body %s", rr.Code, rr.Body.String())
+		}
+		var response models.TokenResponse
+		if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+			t.Fatalf("Error decoding response: %v", err)
+		}
+
+		idClaims, err := jwt.VerifyToken(response.IDToken)
+		if err != nil {
+			t.Fatalf("Failed to verify ID token: %v", err)
+		}
+		accessClaims, err := jwt.VerifyToken(response.AccessToken)
+		if err != nil {
+			t.Fatalf("Failed to verify access token: %v", err)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/userinfo", nil)
+		req.Header.Set("Authorization", "Bearer "+response.AccessToken)
+		rr = httptest.NewRecorder()
+		(&UserInfoHandler{Store: mockStore}).ServeHTTP(rr, req)
+		var userInfo map[string]interface{}
+		if err := json.NewDecoder(rr.Body).Decode(&userInfo); err != nil {
+			t.Fatalf("Error decoding userinfo response: %v", err)
+		}
+
+		req = httptest.NewRequest(http.MethodPost, "/introspect", strings.NewReader(url.Values{"token": {response.AccessToken}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth("resource-server", "secret")
+		rr = httptest.NewRecorder()
+		NewIntrospectionHandler(mockStore, "http://localhost:8080").ServeHTTP(rr, req)
+		var introspection map[string]interface{}
+		if err := json.NewDecoder(rr.Body).Decode(&introspection); err != nil {
+			t.Fatalf("Error decoding introspection response: %v", err)
+		}
+
+		return []interface{}{idClaims["sub"], accessClaims["sub"], userInfo["sub"], userInfo["id"], introspection["sub"]}
+	}
+
+	shop := subjects(t, "shop")
+	for _, sub := range shop {
+		if sub != shop[0] {
+			t.Errorf("Expected the same sub everywhere, got %v", shop)
+		}
+	}
+	if shop[0] == "alice" {
+		t.Errorf("Expected a pairwise sub, got %v", shop[0])
+	}
+	if admin := subjects(t, "shop-admin"); admin[0] != shop[0] {
+		t.Errorf("Expected clients of one sector to share a sub, got %v and %v", admin[0], shop[0])
+	}
+	if blog := subjects(t, "blog"); blog[0] == shop[0] {
+		t.Errorf("Expected clients of different sectors to see different subs, got %v", blog[0])
+	}
+	if public := subjects(t, "public"); public[0] != "alice" {
+		t.Errorf("Expected the public sub alice, got %v", public[0])
+	}
+
+	// A different salt derives different pairwise subjects
+	settings := mockStore.GetSettings()
+	settings.PairwiseSalt = "pepper"
+	mockStore.StoreSettings(settings)
+	if salted := subjects(t, "shop"); salted[0] == shop[0] {
+		t.Errorf("Expected the salt to change the pairwise sub, got %v", salted[0])
+	}
+}
+
+func TestTokenHandler_CustomClaims(t *testing.T) {
+	if err := jwt.InitKeys(); err != nil {
+		t.Fatalf("Failed to initialize keys: %v", err)
+	}
+
+	mockStore := store.NewMemoryStore()
+	mockStore.StoreCustomClaims(&models.CustomClaims{
+		IDToken:     map[string]interface{}{"tenant_id": "global", "env": "test"},
+		AccessToken: map[string]interface{}{"tenant_id": "global", "env": "test"},
+	})
+	mockStore.StoreClient(&models.Client{
+		ClientID:     "test-client",
+		ClientSecret: "test-secret",
+		CustomClaims: &models.CustomClaims{
+			IDToken:     map[string]interface{}{"tenant_id": "client"},
+			AccessToken: map[string]interface{}{"tenant_id": "client", "roles": []interface{}{"reader"}},
+		},
+	})
+	mockStore.StoreUser(&models.UserInfo{
+		Sub:   "alice",
+		Email: "alice@example.com",
+		CustomClaims: &models.CustomClaims{
+			IDToken:     map[string]interface{}{"email": "alice@corp.example.com", "https://example.com/claims": map[string]interface{}{"plan": "pro"}},
+			AccessToken: map[string]interface{}{"roles": []interface{}{"admin"}},
+		},
+	})
+	handler := NewTokenHandler(mockStore)
+
+	mockStore.StoreAuthCode("alice-code", &models.AuthRequest{
+		ClientID:    "test-client",
+		RedirectURI: "http://example.com/callback",
+		Scope:       "openid email",
+		UserID:      "alice",
+	})
+	rr := postTokenRequest(handler, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {"alice-code"},
+		"client_id":     {"test-client"},
+		"client_secret": {"test-secret"},
+		"redirect_uri":  {"http://example.com/callback"},
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("code exchange failed: status %d, body %s", rr.Code, rr.Body.String())
+	}
+	var response models.TokenResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+
+	idClaims, err := jwt.VerifyToken(response.IDToken)
+	if err != nil {
+		t.Fatalf("Failed to verify ID token: %v", err)
+	}
+	if idClaims["tenant_id"] != "client" || idClaims["env"] != "test" || idClaims["email"] != "alice@corp.example.com" {
+		t.Errorf("unexpected ID token claims %v", idClaims)
+	}
+	if nested, ok := idClaims["https://example.com/claims"].(map[string]interface{}); !ok || nested["plan"] != "pro" {
+		t.Errorf("expected the nested claim, got %v", idClaims["https://example.com/claims"])
+	}
+	if _, ok := idClaims["roles"]; ok {
+		t.Errorf("expected access token claims to stay out of the ID token, got %v", idClaims["roles"])
+	}
+
+	accessClaims, err := jwt.VerifyToken(response.AccessToken)
+	if err != nil {
+		t.Fatalf("Failed to verify access token: %v", err)
+	}
+	if roles, ok := accessClaims["roles"].([]interface{}); !ok || len(roles) != 1 || roles[0] != "admin" {
+		t.Errorf("expected the user's roles to replace the client's, got %v", accessClaims["roles"])
+	}
+	if accessClaims["tenant_id"] != "client" || accessClaims["sub"] != "alice" {
+		t.Errorf("unexpected access token claims %v", accessClaims)
+	}
+	if _, ok := accessClaims["https://example.com/claims"]; ok {
+		t.Errorf("expected ID token claims to stay out of the access token")
+	}
+
+	// Client credentials tokens carry the global and client claims
+	rr = postTokenRequest(handler, url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {"test-client"},
+		"client_secret": {"test-secret"},
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("client credentials grant failed: status %d, body %s", rr.Code, rr.Body.String())
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+	accessClaims, err = jwt.VerifyToken(response.AccessToken)
+	if err != nil {
+		t.Fatalf("Failed to verify access token: %v", err)
+	}
+	if roles, ok := accessClaims["roles"].([]interface{}); !ok || roles[0] != "reader" || accessClaims["env"] != "test" {
+		t.Errorf("unexpected client credentials claims %v", accessClaims)
+	}
+}
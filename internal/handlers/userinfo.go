@@ -6,12 +6,16 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/jwt"
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/models"
 	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/store"
 )
 
 // UserInfoHandler handles requests to the OAuth2 userinfo endpoint
 type UserInfoHandler struct {
 	Store *store.MemoryStore
+	// IssuerURL is the iss of signed userinfo responses. Empty uses http://localhost:8080.
+	IssuerURL string
 }
 
 func (h *UserInfoHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -61,13 +65,132 @@ func (h *UserInfoHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Client credentials tokens are issued to the client itself, so there is
+	// no user to describe
+	record, hasRecord := h.Store.GetAccessToken(token)
+	if hasRecord && record.GrantType == "client_credentials" {
+		log.Printf("UserInfo request failed: Token was not issued for a user")
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_token", "The access token was not issued for a user")
+		return
+	}
+
 	log.Printf("UserInfo request successful for user: %s", sanitizeLog(userInfo.Email)) // #nosec G706 -- sanitizeLog strips newlines/CRs to prevent log injection
+
+	clientID, _ := h.Store.GetClientIDByToken(token)
+	client, _ := h.Store.GetClient(clientID)
+
+	// Only the claims released by the scopes granted to the token, or requested
+	// with the claims parameter, are returned. Tokens recorded without a scope
+	// release all of the user's claims.
+	var scope, subject string
+	var requested map[string]*models.ClaimRequest
+	if hasRecord {
+		scope = record.Scope
+		subject = record.Subject
+		if record.Claims != nil {
+			requested = record.Claims.UserInfo
+		}
+	}
+	claims := userInfo.ClaimsFor(clientID)
+	if scope != "" {
+		claims = models.ReleasedClaims(claims, scope, h.Store.ListScopes(), requested)
+	}
+	models.ApplyGroupsOverage(claims, h.Store.GetSettings().GroupsOverageThreshold, groupsEndpoint(h.issuerURL()))
+
+	// Pairwise clients see the sub the token was issued with, also in place of
+	// Google's id, which would otherwise let clients correlate the user
+	if client != nil && client.SubjectType == models.SubjectTypePairwise {
+		if subject == "" {
+			subject = client.Subject(userInfo.Sub, h.Store.GetSettings().PairwiseSalt)
+		}
+		claims["sub"] = subject
+		if _, exists := claims["id"]; exists {
+			claims["id"] = subject
+		}
+	}
+
+	variables := ruleVariables(userInfo, clientID, client, scope, map[string]interface{}{"endpoint": "userinfo"})
+	applyClaimRules(h.Store, claims, models.RuleTargetUserInfo, variables)
+
+	// Clients that registered a signing or encryption algorithm, or that accept
+	// application/jwt, receive the claims as a JWT
+	sign := acceptsJWT(r) || (client != nil && client.UserinfoSignedResponseAlg != "")
+	if sign || (client != nil && client.UserinfoEncryptedResponseAlg != "") {
+		h.writeJWT(w, clientID, client, claims, sign)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(userInfo); err != nil {
+	if err := json.NewEncoder(w).Encode(claims); err != nil {
+		log.Printf("Error encoding user info response: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// writeJWT writes the userinfo claims as a signed JWT, as a JWE, or as a signed
+// JWT nested in a JWE (OIDC Core section 5.3.2). The client is nil when it is
+// not registered; such clients only receive signed responses.
+func (h *UserInfoHandler) writeJWT(w http.ResponseWriter, clientID string, client *models.Client, claims map[string]interface{}, sign bool) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
 		log.Printf("Error encoding user info response: %v", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+
+	contentType := ""
+	if sign {
+		registeredAlg := ""
+		if client != nil {
+			registeredAlg = client.UserinfoSignedResponseAlg
+		}
+		signed, err := jwt.GenerateUserInfoToken(h.issuerURL(), clientID, claims, clientSigningOptions(h.Store, client, registeredAlg))
+		if err != nil {
+			log.Printf("Error signing user info response: %v", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		payload = []byte(signed)
+		contentType = "JWT"
+	}
+
+	if client != nil && client.UserinfoEncryptedResponseAlg != "" {
+		encrypted, err := encryptForClient(client, payload, client.UserinfoEncryptedResponseAlg, client.UserinfoEncryptedResponseEnc, contentType)
+		if err != nil {
+			log.Printf("Error encrypting user info response: %v", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		payload = []byte(encrypted)
+	}
+
+	w.Header().Set("Content-Type", "application/jwt")
+	if _, err := w.Write(payload); err != nil {
+		log.Printf("Error writing user info response: %v", err)
+	}
+}
+
+// issuerURL returns the issuer of signed userinfo responses
+func (h *UserInfoHandler) issuerURL() string {
+	if h.IssuerURL == "" {
+		return "http://localhost:8080"
+	}
+	return h.IssuerURL
+}
+
+// acceptsJWT reports whether the request's Accept header lists application/jwt
+func acceptsJWT(r *http.Request) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, mediaRange := range strings.Split(accept, ",") {
+			mediaType, _, _ := strings.Cut(mediaRange, ";")
+			if strings.EqualFold(strings.TrimSpace(mediaType), "application/jwt") {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 // maskToken hides most of the token for security in logs
@@ -7,8 +7,10 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/jwt"
 	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/models"
 	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/store"
+	jwtlib "github.com/golang-jwt/jwt/v5"
 )
 
 func TestUserInfoHandler_ServeHTTP(t *testing.T) {
@@ -82,3 +84,146 @@ func TestUserInfoHandler_ServeHTTP(t *testing.T) {
 		})
 	}
 }
+
+func TestUserInfoHandler_Signed(t *testing.T) {
+	if err := jwt.InitKeys(); err != nil {
+		t.Fatalf("Failed to initialize keys: %v", err)
+	}
+
+	mockStore := store.NewMemoryStore()
+	mockStore.StoreClient(&models.Client{ClientID: "signed-rp", UserinfoSignedResponseAlg: "ES256"})
+	mockStore.StoreAccessToken(&models.TokenRecord{Token: "signed-token", ClientID: "signed-rp", Scope: "openid email"})
+	mockStore.StoreAccessToken(&models.TokenRecord{Token: "plain-token", ClientID: "client-123", Scope: "openid email"})
+	handler := &UserInfoHandler{Store: mockStore, IssuerURL: "https://issuer.example.com"}
+
+	tests := []struct {
+		name    string
+		token   string
+		accept  string
+		wantAlg string
+		wantAud string
+	}{
+		{name: "registered signing alg", token: "signed-token", wantAlg: "ES256", wantAud: "signed-rp"},
+		{name: "Accept application/jwt", token: "plain-token", accept: "application/json;q=0.5, application/jwt", wantAlg: "RS256", wantAud: "client-123"},
+		{name: "JSON by default", token: "plain-token", accept: "application/json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/userinfo", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+			}
+			if tt.wantAlg == "" {
+				if contentType := rr.Header().Get("Content-Type"); contentType != "application/json" {
+					t.Errorf("Expected Content-Type application/json, got %s", contentType)
+				}
+				return
+			}
+
+			if contentType := rr.Header().Get("Content-Type"); contentType != "application/jwt" {
+				t.Errorf("Expected Content-Type application/jwt, got %s", contentType)
+			}
+			claims, err := jwt.VerifyToken(rr.Body.String())
+			if err != nil {
+				t.Fatalf("Failed to verify userinfo JWT: %v", err)
+			}
+			if claims["iss"] != "https://issuer.example.com" || claims["aud"] != tt.wantAud || claims["sub"] != tt.wantAud {
+				t.Errorf("Unexpected claims %v", claims)
+			}
+			if claims["email"] != tt.wantAud+"@example.com" {
+				t.Errorf("Expected the userinfo claims in the JWT, got %v", claims)
+			}
+			token, _, _ := jwtlib.NewParser().ParseUnverified(rr.Body.String(), jwtlib.MapClaims{})
+			if token.Method.Alg() != tt.wantAlg {
+				t.Errorf("Expected alg %s, got %s", tt.wantAlg, token.Method.Alg())
+			}
+		})
+	}
+}
+
+func TestUserInfoHandler_ScopeClaims(t *testing.T) {
+	mockStore := store.NewMemoryStore()
+	mockStore.StoreUser(&models.UserInfo{
+		Sub:                 "alice",
+		ID:                  "alice",
+		Name:                "Alice",
+		GivenName:           "Alice",
+		FamilyName:          "Liddell",
+		Picture:             "https://example.com/alice.jpg",
+		Email:               "alice@example.com",
+		EmailVerified:       true,
+		HD:                  "example.com",
+		PhoneNumber:         "+15551234567",
+		PhoneNumberVerified: true,
+		Address:             &models.Address{Locality: "Springfield", Country: "US"},
+	})
+	mockStore.StoreScope(&models.Scope{Name: "contact", Claims: []string{"email", "phone_number"}})
+	handler := &UserInfoHandler{Store: mockStore}
+
+	tests := []struct {
+		scope string
+		want  []string
+	}{
+		{scope: "openid", want: []string{"sub", "id", "hd"}},
+		{scope: "openid email", want: []string{"sub", "id", "hd", "email", "email_verified"}},
+		{scope: "openid profile", want: []string{"sub", "id", "hd", "name", "given_name", "family_name", "picture"}},
+		{scope: "openid address phone", want: []string{"sub", "id", "hd", "address", "phone_number", "phone_number_verified"}},
+		{scope: "openid contact", want: []string{"sub", "id", "hd", "email", "phone_number"}},
+		{scope: "", want: []string{"sub", "id", "hd", "name", "given_name", "family_name", "picture", "email", "email_verified", "address", "phone_number", "phone_number_verified"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.scope, func(t *testing.T) {
+			mockStore.StoreAccessToken(&models.TokenRecord{Token: "alice-token", ClientID: "app", UserID: "alice", Scope: tt.scope})
+			req := httptest.NewRequest(http.MethodGet, "/userinfo", nil)
+			req.Header.Set("Authorization", "Bearer alice-token")
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+			}
+			var claims map[string]interface{}
+			if err := json.Unmarshal(rr.Body.Bytes(), &claims); err != nil {
+				t.Fatalf("Failed to unmarshal response body: %v", err)
+			}
+			if len(claims) != len(tt.want) {
+				t.Errorf("Expected claims %v, got %v", tt.want, claims)
+			}
+			for _, claim := range tt.want {
+				if _, ok := claims[claim]; !ok {
+					t.Errorf("Expected claim %s, got %v", claim, claims)
+				}
+			}
+		})
+	}
+}
+
+func TestUserInfoHandler_ClientCredentialsToken(t *testing.T) {
+	mockStore := store.NewMemoryStore()
+	mockStore.StoreAccessToken(&models.TokenRecord{Token: "service-token", ClientID: "billing-service", Subject: "billing-service", Scope: "invoices.read", GrantType: "client_credentials"})
+	handler := &UserInfoHandler{Store: mockStore}
+
+	req := httptest.NewRequest(http.MethodGet, "/userinfo", nil)
+	req.Header.Set("Authorization", "Bearer service-token")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected status %d, got %d", http.StatusUnauthorized, rr.Code)
+	}
+	if errorCode := decodeOAuthError(t, rr); errorCode != "invalid_token" {
+		t.Errorf("Expected error invalid_token, got %q", errorCode)
+	}
+	if header := rr.Header().Get("WWW-Authenticate"); header != `Bearer error="invalid_token"` {
+		t.Errorf("Expected WWW-Authenticate header, got %q", header)
+	}
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/models"
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/store"
+)
+
+// usersPath is the admin API collection path for the user registry
+const usersPath = "/admin/users"
+
+// UsersHandler manages the user registry through the admin API.
+//
+//	GET    /admin/users        lists registered users
+//	POST   /admin/users        creates or replaces a user (sub is required)
+//	GET    /admin/users/{sub}  returns a single user
+//	DELETE /admin/users/{sub}  removes a user
+type UsersHandler struct {
+	store store.Store
+}
+
+// NewUsersHandler creates a new UsersHandler
+func NewUsersHandler(store store.Store) *UsersHandler {
+	return &UsersHandler{store: store}
+}
+
+// ServeHTTP dispatches admin user requests by method and path
+func (h *UsersHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sub := strings.Trim(strings.TrimPrefix(r.URL.Path, usersPath), "/")
+
+	switch {
+	case sub == "" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, h.store.ListUsers())
+	case sub == "" && r.Method == http.MethodPost:
+		h.storeUser(w, r)
+	case sub != "" && r.Method == http.MethodGet:
+		user, exists := h.store.GetUser(sub)
+		if !exists {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, user)
+	case sub != "" && r.Method == http.MethodDelete:
+		if !h.store.RemoveUser(sub) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Removed user %s", sanitizeLog(sub)) // #nosec G706 -- sanitizeLog strips CR/LF to prevent log injection
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// storeUser decodes a user from the request body and registers it
+func (h *UsersHandler) storeUser(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20)) // limit request body to 1MB
+	if err != nil {
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	var user models.UserInfo
+	if err := json.Unmarshal(body, &user); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if user.Sub == "" {
+		http.Error(w, "Missing sub", http.StatusBadRequest)
+		return
+	}
+	if user.ID == "" {
+		user.ID = user.Sub
+	}
+	if err := user.CustomClaims.Validate(); err != nil {
+		http.Error(w, "Invalid custom_claims: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := user.ValidateMemberships(); err != nil {
+		http.Error(w, "Invalid user: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.store.StoreUser(&user)
+	log.Printf("Registered user %s", sanitizeLog(user.Sub)) // #nosec G706 -- sanitizeLog strips CR/LF to prevent log injection
+
+	writeJSON(w, http.StatusCreated, &user)
+}
+
+// writeJSON encodes a value as a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, statusCode int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/jwt"
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/models"
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/store"
+)
+
+func TestUsersHandler(t *testing.T) {
+	memoryStore := store.NewMemoryStore()
+	handler := NewUsersHandler(memoryStore)
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := serve(http.MethodPost, "/admin/users", `{"email":"nosub@example.com"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for missing sub, got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	if rr := serve(http.MethodPost, "/admin/users", `{"sub":"mallory","custom_claims":{"id_token":{"aud":"other-app"}}}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for a reserved custom claim, got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	rr := serve(http.MethodPost, "/admin/users", `{"sub":"alice","email":"alice@example.com","name":"Alice"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	serve(http.MethodPost, "/admin/users", `{"sub":"bob","email":"bob@example.com","name":"Bob"}`)
+
+	rr = serve(http.MethodGet, "/admin/users", "")
+	var users []models.UserInfo
+	if err := json.NewDecoder(rr.Body).Decode(&users); err != nil {
+		t.Fatalf("Error decoding users: %v", err)
+	}
+	if len(users) != 2 || users[0].Sub != "alice" || users[1].Sub != "bob" {
+		t.Fatalf("expected users alice and bob, got %+v", users)
+	}
+
+	rr = serve(http.MethodGet, "/admin/users/alice", "")
+	var alice models.UserInfo
+	if err := json.NewDecoder(rr.Body).Decode(&alice); err != nil {
+		t.Fatalf("Error decoding user: %v", err)
+	}
+	if alice.Email != "alice@example.com" || alice.ID != "alice" {
+		t.Errorf("unexpected user %+v", alice)
+	}
+
+	if rr := serve(http.MethodDelete, "/admin/users/alice", ""); rr.Code != http.StatusNoContent {
+		t.Errorf("expected status %d, got %d", http.StatusNoContent, rr.Code)
+	}
+	if rr := serve(http.MethodGet, "/admin/users/alice", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("expected status %d after delete, got %d", http.StatusNotFound, rr.Code)
+	}
+	if rr := serve(http.MethodDelete, "/admin/users/alice", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("expected status %d for unknown user, got %d", http.StatusNotFound, rr.Code)
+	}
+	if rr := serve(http.MethodPut, "/admin/users", ""); rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status %d, got %d", http.StatusMethodNotAllowed, rr.Code)
+	}
+}
+
+func TestLoginHintBindsTokensToUser(t *testing.T) {
+	if err := jwt.InitKeys(); err != nil {
+		t.Fatalf("Failed to initialize keys: %v", err)
+	}
+
+	memoryStore := store.NewMemoryStore()
+	memoryStore.StoreUser(&models.UserInfo{Sub: "alice", Email: "alice@example.com", Name: "Alice"})
+	memoryStore.StoreUser(&models.UserInfo{Sub: "bob", Email: "bob@example.com", Name: "Bob"})
+
+	authorizeHandler := &AuthorizeHandler{Store: memoryStore}
+	tokenHandler := NewTokenHandler(memoryStore)
+	userInfoHandler := &UserInfoHandler{Store: memoryStore}
+
+	// Log both users in concurrently, one by sub and one by email
+	for _, tt := range []struct{ loginHint, expectedSub, expectedEmail string }{
+		{"alice", "alice", "alice@example.com"},
+		{"BOB@example.com", "bob", "bob@example.com"},
+	} {
+		query := url.Values{
+			"client_id":     {"test-client"},
+			"redirect_uri":  {"http://localhost/callback"},
+			"scope":         {"openid email"},
+			"response_type": {"code"},
+			"login_hint":    {tt.loginHint},
+		}
+		rr := httptest.NewRecorder()
+		authorizeHandler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/authorize?"+query.Encode(), nil))
+		location, err := url.Parse(rr.Header().Get("Location"))
+		if err != nil {
+			t.Fatalf("Error parsing redirect: %v", err)
+		}
+
+		rr = postTokenRequest(tokenHandler, url.Values{
+			"grant_type":   {"authorization_code"},
+			"code":         {location.Query().Get("code")},
+			"client_id":    {"test-client"},
+			"redirect_uri": {"http://localhost/callback"},
+		})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("code exchange failed: status %d, body %s", rr.Code, rr.Body.String())
+		}
+		var tokens models.TokenResponse
+		if err := json.NewDecoder(rr.Body).Decode(&tokens); err != nil {
+			t.Fatalf("Error decoding response: %v", err)
+		}
+
+		claims, err := jwt.VerifyToken(tokens.IDToken)
+		if err != nil {
+			t.Fatalf("Error verifying ID token: %v", err)
+		}
+		if claims["sub"] != tt.expectedSub || claims["email"] != tt.expectedEmail {
+			t.Errorf("expected ID token for %s, got sub %v email %v", tt.expectedSub, claims["sub"], claims["email"])
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/userinfo", nil)
+		req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+		rr = httptest.NewRecorder()
+		userInfoHandler.ServeHTTP(rr, req)
+		var userInfo models.UserInfo
+		if err := json.NewDecoder(rr.Body).Decode(&userInfo); err != nil {
+			t.Fatalf("Error decoding userinfo: %v", err)
+		}
+		if userInfo.Sub != tt.expectedSub || userInfo.Email != tt.expectedEmail {
+			t.Errorf("expected userinfo for %s, got %+v", tt.expectedSub, userInfo)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestJSONWebKeyRoundTrip(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPublic, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, publicKey := range []crypto.PublicKey{&rsaKey.PublicKey, &ecKey.PublicKey, edPublic} {
+		jwk, err := NewJSONWebKey(publicKey, "kid", "")
+		if err != nil {
+			t.Fatalf("NewJSONWebKey(%T): %v", publicKey, err)
+		}
+		decoded, err := jwk.PublicKey()
+		if err != nil {
+			t.Fatalf("PublicKey() for %s: %v", jwk.Kty, err)
+		}
+		if !decoded.(interface{ Equal(crypto.PublicKey) bool }).Equal(publicKey) {
+			t.Errorf("%s key did not survive the round trip", jwk.Kty)
+		}
+	}
+
+	// Points that are not on the curve are rejected
+	jwk, _ := NewJSONWebKey(&ecKey.PublicKey, "kid", "ES256")
+	jwk.Y = jwk.X
+	if _, err := jwk.PublicKey(); err == nil {
+		t.Errorf("expected an invalid EC point to be rejected")
+	}
+}
+
+func TestVerifyClientAssertion(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwk, err := NewJSONWebKey(&ecKey.PublicKey, "client-key", "ES256")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := ClientAssertionOptions{
+		ClientID:  "service-a",
+		Audiences: []string{"http://localhost:8080/token"},
+		Keys:      &JSONWebKeySet{Keys: []JSONWebKey{jwk}},
+		Secrets:   []string{"old-secret", "new-secret"},
+	}
+	validClaims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss": "service-a",
+			"sub": "service-a",
+			"aud": "http://localhost:8080/token",
+			"exp": time.Now().Add(time.Minute).Unix(),
+			"jti": "assertion-1",
+		}
+	}
+	signES256 := func(claims jwt.MapClaims, kid string) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+		token.Header["kid"] = kid
+		signed, err := token.SignedString(ecKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	signHS256 := func(claims jwt.MapClaims, secret string) string {
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+
+	withClaim := func(name string, value interface{}) jwt.MapClaims {
+		claims := validClaims()
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name           string
+		assertion      string
+		expectedMethod string
+	}{
+		{"private_key_jwt", signES256(validClaims(), "client-key"), AuthMethodPrivateKeyJWT},
+		{"private_key_jwt without kid", signES256(validClaims(), ""), AuthMethodPrivateKeyJWT},
+		{"client_secret_jwt with rotated secret", signHS256(validClaims(), "old-secret"), AuthMethodClientSecretJWT},
+		{"Unknown kid", signES256(validClaims(), "other-key"), ""},
+		{"Wrong secret", signHS256(validClaims(), "wrong"), ""},
+		{"Wrong audience", signES256(withClaim("aud", "http://other.example.com/token"), "client-key"), ""},
+		{"Issuer is not the client", signES256(withClaim("iss", "service-b"), "client-key"), ""},
+		{"Subject is not the client", signES256(withClaim("sub", "service-b"), "client-key"), ""},
+		{"Expired", signES256(withClaim("exp", time.Now().Add(-time.Minute).Unix()), "client-key"), ""},
+		{"Missing exp", signES256(withClaim("exp", nil), "client-key"), ""},
+		{"Missing jti", signES256(withClaim("jti", nil), "client-key"), ""},
+		{"Unsigned", "eyJhbGciOiJub25lIn0.eyJpc3MiOiJzZXJ2aWNlLWEifQ.", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertion, err := VerifyClientAssertion(tt.assertion, opts)
+			if tt.expectedMethod == "" {
+				if err == nil {
+					t.Fatalf("expected assertion to be rejected")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if assertion.Method != tt.expectedMethod || assertion.ID != "assertion-1" {
+				t.Errorf("unexpected assertion %+v", assertion)
+			}
+		})
+	}
+
+	if issuer := UnverifiedAssertionIssuer(signHS256(validClaims(), "wrong")); issuer != "service-a" {
+		t.Errorf("expected unverified issuer service-a, got %q", issuer)
+	}
+}
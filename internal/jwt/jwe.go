@@ -0,0 +1,373 @@
+package jwt
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Key management algorithms for encrypted tokens (RFC 7518 section 4)
+const (
+	KeyAlgRSAOAEP256 = "RSA-OAEP-256"
+	KeyAlgECDHES     = "ECDH-ES"
+)
+
+// Content encryption algorithms for encrypted tokens (RFC 7518 section 5)
+const (
+	EncA128CBCHS256 = "A128CBC-HS256"
+	EncA256GCM      = "A256GCM"
+)
+
+// DefaultContentEncryption is used when a client registers a key management
+// algorithm but no content encryption (OIDC Registration section 2)
+const DefaultContentEncryption = EncA128CBCHS256
+
+// EncryptionAlgorithms lists the supported key management algorithms
+func EncryptionAlgorithms() []string {
+	return []string{KeyAlgRSAOAEP256, KeyAlgECDHES}
+}
+
+// ContentEncryptionAlgorithms lists the supported content encryption algorithms
+func ContentEncryptionAlgorithms() []string {
+	return []string{EncA128CBCHS256, EncA256GCM}
+}
+
+// jweHeader is the protected header of a JWE
+type jweHeader struct {
+	Alg string      `json:"alg"`
+	Enc string      `json:"enc"`
+	Kid string      `json:"kid,omitempty"`
+	Cty string      `json:"cty,omitempty"`
+	Epk *JSONWebKey `json:"epk,omitempty"`
+}
+
+// EncryptionKey returns the first key of the set that can receive content
+// encrypted with the key management algorithm alg
+func (s *JSONWebKeySet) EncryptionKey(alg string) (JSONWebKey, bool) {
+	kty := map[string]string{KeyAlgRSAOAEP256: "RSA", KeyAlgECDHES: "EC"}[alg]
+	for _, key := range s.Keys {
+		if key.Use == "sig" || key.Kty != kty || (key.Alg != "" && key.Alg != alg) {
+			continue
+		}
+		return key, true
+	}
+	return JSONWebKey{}, false
+}
+
+// Encrypt wraps plaintext in a compact JWE (RFC 7516) for the recipient's
+// public key. contentType is the cty header, "JWT" for a nested signed token.
+func Encrypt(plaintext []byte, recipient JSONWebKey, alg, enc, contentType string) (string, error) {
+	keySize, err := contentKeySize(enc)
+	if err != nil {
+		return "", err
+	}
+	publicKey, err := recipient.PublicKey()
+	if err != nil {
+		return "", err
+	}
+
+	header := jweHeader{Alg: alg, Enc: enc, Kid: recipient.Kid, Cty: contentType}
+	var cek, encryptedKey []byte
+	switch alg {
+	case KeyAlgRSAOAEP256:
+		rsaKey, ok := publicKey.(*rsa.PublicKey)
+		if !ok {
+			return "", fmt.Errorf("jwe: %s needs an RSA key", alg)
+		}
+		cek = make([]byte, keySize)
+		if _, err := rand.Read(cek); err != nil {
+			return "", err
+		}
+		if encryptedKey, err = rsa.EncryptOAEP(sha256.New(), rand.Reader, rsaKey, cek, nil); err != nil {
+			return "", fmt.Errorf("jwe: %w", err)
+		}
+	case KeyAlgECDHES:
+		ecKey, ok := publicKey.(*ecdsa.PublicKey)
+		if !ok {
+			return "", fmt.Errorf("jwe: %s needs an EC key", alg)
+		}
+		recipientKey, err := ecKey.ECDH()
+		if err != nil {
+			return "", fmt.Errorf("jwe: %w", err)
+		}
+		ephemeral, err := recipientKey.Curve().GenerateKey(rand.Reader)
+		if err != nil {
+			return "", err
+		}
+		sharedSecret, err := ephemeral.ECDH(recipientKey)
+		if err != nil {
+			return "", fmt.Errorf("jwe: %w", err)
+		}
+		epk, err := ephemeralPublicKey(ephemeral.PublicKey(), recipient.Crv)
+		if err != nil {
+			return "", err
+		}
+		header.Epk = &epk
+		// Direct key agreement: the agreed key is the content encryption key
+		cek = concatKDF(sharedSecret, enc, nil, nil, keySize)
+	default:
+		return "", fmt.Errorf("jwe: unsupported key management algorithm %q", alg)
+	}
+
+	encodedHeader, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	protected := base64.RawURLEncoding.EncodeToString(encodedHeader)
+
+	iv, ciphertext, tag, err := encryptContent(enc, cek, plaintext, []byte(protected))
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Join([]string{
+		protected,
+		base64.RawURLEncoding.EncodeToString(encryptedKey),
+		base64.RawURLEncoding.EncodeToString(iv),
+		base64.RawURLEncoding.EncodeToString(ciphertext),
+		base64.RawURLEncoding.EncodeToString(tag),
+	}, "."), nil
+}
+
+// Decrypt opens a compact JWE created by Encrypt with the recipient's private
+// key, an *rsa.PrivateKey or *ecdsa.PrivateKey. It lets tests play the relying party.
+func Decrypt(token string, privateKey crypto.PrivateKey) ([]byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 5 {
+		return nil, errors.New("jwe: not a compact JWE")
+	}
+	decoded := make([][]byte, len(parts))
+	for i, part := range parts {
+		var err error
+		if decoded[i], err = base64.RawURLEncoding.DecodeString(part); err != nil {
+			return nil, fmt.Errorf("jwe: %w", err)
+		}
+	}
+
+	var header jweHeader
+	if err := json.Unmarshal(decoded[0], &header); err != nil {
+		return nil, fmt.Errorf("jwe: parsing header: %w", err)
+	}
+	keySize, err := contentKeySize(header.Enc)
+	if err != nil {
+		return nil, err
+	}
+
+	var cek []byte
+	switch header.Alg {
+	case KeyAlgRSAOAEP256:
+		rsaKey, ok := privateKey.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("jwe: %s needs an RSA key", header.Alg)
+		}
+		if cek, err = rsa.DecryptOAEP(sha256.New(), nil, rsaKey, decoded[1], nil); err != nil {
+			return nil, fmt.Errorf("jwe: %w", err)
+		}
+	case KeyAlgECDHES:
+		ecKey, ok := privateKey.(*ecdsa.PrivateKey)
+		if !ok || header.Epk == nil {
+			return nil, fmt.Errorf("jwe: %s needs an EC key and an epk header", header.Alg)
+		}
+		epk, err := header.Epk.PublicKey()
+		if err != nil {
+			return nil, err
+		}
+		ecEpk, ok := epk.(*ecdsa.PublicKey)
+		if !ok {
+			return nil, errors.New("jwe: epk is not an EC key")
+		}
+		ephemeral, err := ecEpk.ECDH()
+		if err != nil {
+			return nil, fmt.Errorf("jwe: %w", err)
+		}
+		recipient, err := ecKey.ECDH()
+		if err != nil {
+			return nil, fmt.Errorf("jwe: %w", err)
+		}
+		sharedSecret, err := recipient.ECDH(ephemeral)
+		if err != nil {
+			return nil, fmt.Errorf("jwe: %w", err)
+		}
+		cek = concatKDF(sharedSecret, header.Enc, nil, nil, keySize)
+	default:
+		return nil, fmt.Errorf("jwe: unsupported key management algorithm %q", header.Alg)
+	}
+	if len(cek) != keySize {
+		return nil, errors.New("jwe: content encryption key has the wrong length")
+	}
+
+	return decryptContent(header.Enc, cek, decoded[2], decoded[3], decoded[4], []byte(parts[0]))
+}
+
+// contentKeySize returns the content encryption key length of enc in bytes
+func contentKeySize(enc string) (int, error) {
+	switch enc {
+	case EncA128CBCHS256, EncA256GCM:
+		return 32, nil
+	default:
+		return 0, fmt.Errorf("jwe: unsupported content encryption %q", enc)
+	}
+}
+
+// encryptContent encrypts plaintext with a fresh IV and authenticates it
+// together with the additional authenticated data
+func encryptContent(enc string, cek, plaintext, aad []byte) (iv, ciphertext, tag []byte, err error) {
+	switch enc {
+	case EncA256GCM:
+		gcm, err := newGCM(cek)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		iv = make([]byte, gcm.NonceSize())
+		if _, err := rand.Read(iv); err != nil {
+			return nil, nil, nil, err
+		}
+		sealed := gcm.Seal(nil, iv, plaintext, aad)
+		split := len(sealed) - gcm.Overhead()
+		return iv, sealed[:split], sealed[split:], nil
+	case EncA128CBCHS256:
+		iv = make([]byte, aes.BlockSize)
+		if _, err := rand.Read(iv); err != nil {
+			return nil, nil, nil, err
+		}
+		ciphertext, tag, err = cbcHMACEncrypt(cek, iv, plaintext, aad)
+		return iv, ciphertext, tag, err
+	default:
+		return nil, nil, nil, fmt.Errorf("jwe: unsupported content encryption %q", enc)
+	}
+}
+
+// decryptContent checks the authentication tag and decrypts the ciphertext
+func decryptContent(enc string, cek, iv, ciphertext, tag, aad []byte) ([]byte, error) {
+	switch enc {
+	case EncA256GCM:
+		gcm, err := newGCM(cek)
+		if err != nil {
+			return nil, err
+		}
+		if len(iv) != gcm.NonceSize() {
+			return nil, errors.New("jwe: invalid IV")
+		}
+		plaintext, err := gcm.Open(nil, iv, append(bytes.Clone(ciphertext), tag...), aad)
+		if err != nil {
+			return nil, fmt.Errorf("jwe: %w", err)
+		}
+		return plaintext, nil
+	case EncA128CBCHS256:
+		return cbcHMACDecrypt(cek, iv, ciphertext, tag, aad)
+	default:
+		return nil, fmt.Errorf("jwe: unsupported content encryption %q", enc)
+	}
+}
+
+// newGCM creates the AES-GCM cipher for a content encryption key
+func newGCM(cek []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, fmt.Errorf("jwe: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// cbcHMACEncrypt implements AES_128_CBC_HMAC_SHA_256 (RFC 7518 section 5.2.2.1)
+func cbcHMACEncrypt(cek, iv, plaintext, aad []byte) (ciphertext, tag []byte, err error) {
+	macKey, encKey := cek[:16], cek[16:]
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("jwe: %w", err)
+	}
+
+	// PKCS #7 padding
+	padding := aes.BlockSize - len(plaintext)%aes.BlockSize
+	padded := append(bytes.Clone(plaintext), bytes.Repeat([]byte{byte(padding)}, padding)...)
+	ciphertext = make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, padded)
+
+	return ciphertext, cbcHMACTag(macKey, iv, ciphertext, aad), nil
+}
+
+// cbcHMACDecrypt checks the tag and decrypts AES_128_CBC_HMAC_SHA_256 ciphertext
+// (RFC 7518 section 5.2.2.2)
+func cbcHMACDecrypt(cek, iv, ciphertext, tag, aad []byte) ([]byte, error) {
+	macKey, encKey := cek[:16], cek[16:]
+	if !hmac.Equal(tag, cbcHMACTag(macKey, iv, ciphertext, aad)) {
+		return nil, errors.New("jwe: authentication tag mismatch")
+	}
+	if len(iv) != aes.BlockSize || len(ciphertext) == 0 || len(ciphertext)%aes.BlockSize != 0 {
+		return nil, errors.New("jwe: invalid ciphertext")
+	}
+
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, fmt.Errorf("jwe: %w", err)
+	}
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, ciphertext)
+
+	padding := int(plaintext[len(plaintext)-1])
+	if padding == 0 || padding > aes.BlockSize ||
+		subtle.ConstantTimeCompare(plaintext[len(plaintext)-padding:], bytes.Repeat([]byte{byte(padding)}, padding)) != 1 {
+		return nil, errors.New("jwe: invalid padding")
+	}
+	return plaintext[:len(plaintext)-padding], nil
+}
+
+// cbcHMACTag computes the first half of HMAC-SHA-256 over AAD || IV || ciphertext || AL
+func cbcHMACTag(macKey, iv, ciphertext, aad []byte) []byte {
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write(aad)
+	mac.Write(iv)
+	mac.Write(ciphertext)
+	_ = binary.Write(mac, binary.BigEndian, uint64(len(aad))*8)
+	return mac.Sum(nil)[:16]
+}
+
+// concatKDF derives a key of keySize bytes from an ECDH shared secret with the
+// Concat KDF of NIST SP 800-56A, as RFC 7518 section 4.6.2 profiles it
+func concatKDF(sharedSecret []byte, algorithmID string, partyUInfo, partyVInfo []byte, keySize int) []byte {
+	var otherInfo bytes.Buffer
+	for _, field := range [][]byte{[]byte(algorithmID), partyUInfo, partyVInfo} {
+		_ = binary.Write(&otherInfo, binary.BigEndian, uint32(len(field)))
+		otherInfo.Write(field)
+	}
+	_ = binary.Write(&otherInfo, binary.BigEndian, uint32(keySize*8))
+
+	var derived []byte
+	for counter := uint32(1); len(derived) < keySize; counter++ {
+		hash := sha256.New()
+		_ = binary.Write(hash, binary.BigEndian, counter)
+		hash.Write(sharedSecret)
+		hash.Write(otherInfo.Bytes())
+		derived = hash.Sum(derived)
+	}
+	return derived[:keySize]
+}
+
+// ephemeralPublicKey encodes the ephemeral ECDH key for the epk header
+func ephemeralPublicKey(publicKey *ecdh.PublicKey, crv string) (JSONWebKey, error) {
+	point := publicKey.Bytes()
+	if len(point) == 0 || point[0] != 4 {
+		return JSONWebKey{}, errors.New("jwe: unexpected ephemeral key encoding")
+	}
+	size := (len(point) - 1) / 2
+	return JSONWebKey{
+		Kty: "EC",
+		Crv: crv,
+		X:   base64.RawURLEncoding.EncodeToString(point[1 : 1+size]),
+		Y:   base64.RawURLEncoding.EncodeToString(point[1+size:]),
+	}, nil
+}
@@ -0,0 +1,133 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate EC key: %v", err)
+	}
+	rsaJWK, _ := NewJSONWebKey(&rsaKey.PublicKey, "rsa-enc", "")
+	ecJWK, _ := NewJSONWebKey(&ecKey.PublicKey, "ec-enc", "")
+
+	plaintext := []byte(`{"sub":"user-123"}`)
+	for _, enc := range ContentEncryptionAlgorithms() {
+		for _, tt := range []struct {
+			alg        string
+			recipient  JSONWebKey
+			privateKey interface{}
+		}{
+			{KeyAlgRSAOAEP256, rsaJWK, rsaKey},
+			{KeyAlgECDHES, ecJWK, ecKey},
+		} {
+			t.Run(tt.alg+"/"+enc, func(t *testing.T) {
+				token, err := Encrypt(plaintext, tt.recipient, tt.alg, enc, "JWT")
+				if err != nil {
+					t.Fatalf("Failed to encrypt: %v", err)
+				}
+
+				parts := strings.Split(token, ".")
+				headerJSON, _ := base64.RawURLEncoding.DecodeString(parts[0])
+				var header map[string]interface{}
+				if err := json.Unmarshal(headerJSON, &header); err != nil {
+					t.Fatalf("Failed to decode header: %v", err)
+				}
+				if header["alg"] != tt.alg || header["enc"] != enc || header["kid"] != tt.recipient.Kid || header["cty"] != "JWT" {
+					t.Errorf("Unexpected header %v", header)
+				}
+
+				decrypted, err := Decrypt(token, tt.privateKey)
+				if err != nil {
+					t.Fatalf("Failed to decrypt: %v", err)
+				}
+				if string(decrypted) != string(plaintext) {
+					t.Errorf("Expected %s, got %s", plaintext, decrypted)
+				}
+
+				// A modified ciphertext fails authentication
+				parts[3] = base64.RawURLEncoding.EncodeToString(append([]byte{0}, []byte(parts[3])[1:]...))
+				if _, err := Decrypt(strings.Join(parts, "."), tt.privateKey); err == nil {
+					t.Errorf("Expected a tampered token to fail")
+				}
+			})
+		}
+	}
+
+	if _, err := Encrypt(plaintext, ecJWK, KeyAlgRSAOAEP256, EncA256GCM, ""); err == nil {
+		t.Errorf("Expected RSA-OAEP-256 with an EC key to fail")
+	}
+	if _, err := Encrypt(plaintext, rsaJWK, KeyAlgRSAOAEP256, "A128GCM", ""); err == nil {
+		t.Errorf("Expected an unsupported enc to fail")
+	}
+}
+
+func TestEncryptionKey(t *testing.T) {
+	keySet := JSONWebKeySet{Keys: []JSONWebKey{
+		{Kty: "RSA", Use: "sig", Kid: "rsa-sig"},
+		{Kty: "RSA", Kid: "rsa-oaep", Alg: "RSA-OAEP"},
+		{Kty: "RSA", Use: "enc", Kid: "rsa-enc"},
+		{Kty: "EC", Kid: "ec"},
+	}}
+
+	if key, ok := keySet.EncryptionKey(KeyAlgRSAOAEP256); !ok || key.Kid != "rsa-enc" {
+		t.Errorf("Expected rsa-enc for RSA-OAEP-256, got %v", key.Kid)
+	}
+	if key, ok := keySet.EncryptionKey(KeyAlgECDHES); !ok || key.Kid != "ec" {
+		t.Errorf("Expected ec for ECDH-ES, got %v", key.Kid)
+	}
+	if _, ok := (&JSONWebKeySet{Keys: keySet.Keys[:2]}).EncryptionKey(KeyAlgRSAOAEP256); ok {
+		t.Errorf("Expected no key for RSA-OAEP-256 without a suitable key")
+	}
+}
+
+// TestConcatKDF checks the ECDH-ES example of RFC 7518 appendix C
+func TestConcatKDF(t *testing.T) {
+	z := []byte{158, 86, 217, 29, 129, 113, 53, 211, 114, 131, 66, 131, 191, 132, 38, 156,
+		251, 49, 110, 163, 218, 128, 106, 72, 246, 218, 167, 121, 140, 254, 144, 196}
+
+	key := concatKDF(z, "A128GCM", []byte("Alice"), []byte("Bob"), 16)
+	if got := base64.RawURLEncoding.EncodeToString(key); got != "VqqN6vgjbSBcIijNcacQGg" {
+		t.Errorf("Expected VqqN6vgjbSBcIijNcacQGg, got %s", got)
+	}
+}
+
+// TestCBCHMAC checks the AES_128_CBC_HMAC_SHA_256 example of RFC 7518 appendix B.1
+func TestCBCHMAC(t *testing.T) {
+	key := make([]byte, 32)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	iv, _ := hex.DecodeString("1af38c2dc2b96ffdd86694092341bc04")
+	plaintext := []byte("A cipher system must not be required to be secret, and it must be able to fall into the hands of the enemy without inconvenience")
+	aad := []byte("The second principle of Auguste Kerckhoffs")
+
+	ciphertext, tag, err := cbcHMACEncrypt(key, iv, plaintext, aad)
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+	if got := hex.EncodeToString(tag); got != "652c3fa36b0a7c5b3219fab3a30bc1c4" {
+		t.Errorf("Expected tag 652c3fa36b0a7c5b3219fab3a30bc1c4, got %s", got)
+	}
+	if got := hex.EncodeToString(ciphertext[:16]); got != "c80edfa32ddf39d5ef00c0b468834279" {
+		t.Errorf("Unexpected first ciphertext block %s", got)
+	}
+
+	decrypted, err := cbcHMACDecrypt(key, iv, ciphertext, tag, aad)
+	if err != nil || string(decrypted) != string(plaintext) {
+		t.Errorf("Expected the plaintext back, got %q, %v", decrypted, err)
+	}
+}
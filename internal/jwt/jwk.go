@@ -0,0 +1,282 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// JSONWebKey is a JSON Web Key (RFC 7517) of type RSA, EC or OKP. Private
+// parameters are only set for keys created by NewPrivateJSONWebKey.
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Use string `json:"use,omitempty"`
+	Kid string `json:"kid,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	// RSA parameters
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC and OKP parameters
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	// Private parameters (RFC 7518 section 6.3.2 and 6.2.2, RFC 8037 section 2)
+	D  string `json:"d,omitempty"`
+	P  string `json:"p,omitempty"`
+	Q  string `json:"q,omitempty"`
+	DP string `json:"dp,omitempty"`
+	DQ string `json:"dq,omitempty"`
+	QI string `json:"qi,omitempty"`
+}
+
+// JSONWebKeySet is a JWK Set (RFC 7517 section 5)
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// NewJSONWebKey encodes an *rsa.PublicKey, *ecdsa.PublicKey or ed25519.PublicKey as a JWK
+func NewJSONWebKey(publicKey crypto.PublicKey, kid, alg string) (JSONWebKey, error) {
+	jwk := JSONWebKey{Use: "sig", Kid: kid, Alg: alg}
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(key.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())
+	case *ecdsa.PublicKey:
+		point, err := key.Bytes()
+		if err != nil {
+			return JSONWebKey{}, fmt.Errorf("jwk: %w", err)
+		}
+		size := (len(point) - 1) / 2
+		jwk.Kty = "EC"
+		jwk.Crv = key.Curve.Params().Name
+		jwk.X = base64.RawURLEncoding.EncodeToString(point[1 : 1+size])
+		jwk.Y = base64.RawURLEncoding.EncodeToString(point[1+size:])
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(key)
+	default:
+		return JSONWebKey{}, fmt.Errorf("jwk: unsupported public key type %T", publicKey)
+	}
+	return jwk, nil
+}
+
+// PublicKey decodes the key into an *rsa.PublicKey, *ecdsa.PublicKey or ed25519.PublicKey
+func (k JSONWebKey) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeKeyParam("n", k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeKeyParam("e", k.E)
+		if err != nil {
+			return nil, err
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+			return nil, errors.New("jwk: RSA exponent is too large")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("jwk: unsupported EC curve %q", k.Crv)
+		}
+		x, err := decodeKeyParam("x", k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeKeyParam("y", k.Y)
+		if err != nil {
+			return nil, err
+		}
+		// Build the uncompressed point so that the curve membership is checked
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x) > size || len(y) > size {
+			return nil, errors.New("jwk: EC coordinates do not match the curve")
+		}
+		point := make([]byte, 1+2*size)
+		point[0] = 4
+		copy(point[1+size-len(x):1+size], x)
+		copy(point[1+2*size-len(y):], y)
+		key, err := ecdsa.ParseUncompressedPublicKey(curve, point)
+		if err != nil {
+			return nil, fmt.Errorf("jwk: %w", err)
+		}
+		return key, nil
+
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("jwk: unsupported OKP curve %q", k.Crv)
+		}
+		x, err := decodeKeyParam("x", k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("jwk: Ed25519 key has the wrong length")
+		}
+		return ed25519.PublicKey(x), nil
+
+	default:
+		return nil, fmt.Errorf("jwk: unsupported key type %q", k.Kty)
+	}
+}
+
+// NewPrivateJSONWebKey encodes an *rsa.PrivateKey, *ecdsa.PrivateKey or
+// ed25519.PrivateKey as a JWK including its private parameters
+func NewPrivateJSONWebKey(privateKey crypto.Signer, kid, alg string) (JSONWebKey, error) {
+	jwk, err := NewJSONWebKey(privateKey.Public(), kid, alg)
+	if err != nil {
+		return JSONWebKey{}, err
+	}
+	switch key := privateKey.(type) {
+	case *rsa.PrivateKey:
+		if len(key.Primes) != 2 {
+			return JSONWebKey{}, errors.New("jwk: multi-prime RSA keys are not supported")
+		}
+		key.Precompute()
+		jwk.D = encodeKeyParam(key.D.Bytes())
+		jwk.P = encodeKeyParam(key.Primes[0].Bytes())
+		jwk.Q = encodeKeyParam(key.Primes[1].Bytes())
+		jwk.DP = encodeKeyParam(key.Precomputed.Dp.Bytes())
+		jwk.DQ = encodeKeyParam(key.Precomputed.Dq.Bytes())
+		jwk.QI = encodeKeyParam(key.Precomputed.Qinv.Bytes())
+	case *ecdsa.PrivateKey:
+		d, err := key.Bytes()
+		if err != nil {
+			return JSONWebKey{}, fmt.Errorf("jwk: %w", err)
+		}
+		jwk.D = encodeKeyParam(d)
+	case ed25519.PrivateKey:
+		jwk.D = encodeKeyParam(key.Seed())
+	default:
+		return JSONWebKey{}, fmt.Errorf("jwk: unsupported private key type %T", privateKey)
+	}
+	return jwk, nil
+}
+
+// PrivateKey decodes the key into an *rsa.PrivateKey, *ecdsa.PrivateKey or ed25519.PrivateKey
+func (k JSONWebKey) PrivateKey() (crypto.Signer, error) {
+	publicKey, err := k.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	d, err := decodeKeyParam("d", k.D)
+	if err != nil {
+		return nil, err
+	}
+
+	switch public := publicKey.(type) {
+	case *rsa.PublicKey:
+		p, err := decodeKeyParam("p", k.P)
+		if err != nil {
+			return nil, err
+		}
+		q, err := decodeKeyParam("q", k.Q)
+		if err != nil {
+			return nil, err
+		}
+		key := &rsa.PrivateKey{
+			PublicKey: *public,
+			D:         new(big.Int).SetBytes(d),
+			Primes:    []*big.Int{new(big.Int).SetBytes(p), new(big.Int).SetBytes(q)},
+		}
+		if err := key.Validate(); err != nil {
+			return nil, fmt.Errorf("jwk: %w", err)
+		}
+		key.Precompute()
+		return key, nil
+	case *ecdsa.PublicKey:
+		key, err := ecdsa.ParseRawPrivateKey(public.Curve, d)
+		if err != nil {
+			return nil, fmt.Errorf("jwk: %w", err)
+		}
+		if !key.PublicKey.Equal(public) {
+			return nil, errors.New("jwk: EC private key does not match x and y")
+		}
+		return key, nil
+	case ed25519.PublicKey:
+		if len(d) != ed25519.SeedSize {
+			return nil, errors.New("jwk: Ed25519 private key has the wrong length")
+		}
+		key := ed25519.NewKeyFromSeed(d)
+		if !key.Public().(ed25519.PublicKey).Equal(public) {
+			return nil, errors.New("jwk: Ed25519 private key does not match x")
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("jwk: unsupported key type %q", k.Kty)
+	}
+}
+
+// Thumbprint computes the RFC 7638 JWK thumbprint of the key's public parameters
+func (k JSONWebKey) Thumbprint() (string, error) {
+	// The required members, in lexicographic order
+	var members interface{}
+	switch k.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{k.E, k.Kty, k.N}
+	case "EC":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{k.Crv, k.Kty, k.X, k.Y}
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{k.Crv, k.Kty, k.X}
+	default:
+		return "", fmt.Errorf("jwk: unsupported key type %q", k.Kty)
+	}
+
+	encoded, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(encoded)
+	return encodeKeyParam(hash[:]), nil
+}
+
+// encodeKeyParam base64url-encodes a key parameter
+func encodeKeyParam(value []byte) string {
+	return base64.RawURLEncoding.EncodeToString(value)
+}
+
+// decodeKeyParam decodes a required base64url-encoded key parameter
+func decodeKeyParam(name, value string) ([]byte, error) {
+	if value == "" {
+		return nil, fmt.Errorf("jwk: missing %q parameter", name)
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("jwk: invalid %q parameter: %w", name, err)
+	}
+	return decoded, nil
+}
@@ -1,57 +1,79 @@
 package jwt
 
 import (
-	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"math/big"
-	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var (
-	privateKey *rsa.PrivateKey
-	publicKey  *rsa.PublicKey
-	keyID      string
-	once       sync.Once
-)
+// SigningOptions selects how a token is signed
+type SigningOptions struct {
+	// Alg is one of SigningAlgorithms. Empty uses DefaultSigningAlgorithm.
+	Alg string
+	// Secret is the client secret HS256 tokens are signed with
+	Secret string
+}
 
-// InitKeys initializes the RSA key pair for JWT signing
-func InitKeys() error {
-	var err error
-	once.Do(func() {
-		// Generate RSA key pair
-		privateKey, err = rsa.GenerateKey(rand.Reader, 2048)
-		if err != nil {
-			return
-		}
-		publicKey = &privateKey.PublicKey
-		keyID = "mock-key-1"
-	})
-	return err
+// IDTokenOptions holds the per-authentication values of an ID token
+type IDTokenOptions struct {
+	// Nonce is echoed from the authorization request. Empty omits the claim.
+	Nonce string
+	// AccessToken issued alongside the ID token, used to compute at_hash. Empty omits the claim.
+	AccessToken string
+	// AuthTime is when the user authenticated. Zero uses the issue time.
+	AuthTime time.Time
+	// Signing selects the signing algorithm
+	Signing SigningOptions
+	// Claims are further claims about the user, such as those released by the
+	// profile scope. They cannot replace the claims set above.
+	Claims map[string]interface{}
+}
+
+// AccessTokenOptions holds the per-client values of an access token
+type AccessTokenOptions struct {
+	// Signing selects the signing algorithm
+	Signing SigningOptions
+	// Claims are further claims, such as custom claims configured for the
+	// user or client. They cannot replace the claims set above.
+	Claims map[string]interface{}
 }
 
 // GenerateIDToken creates a signed JWT ID token
 func GenerateIDToken(issuer, clientID, sub, email, name string) (string, error) {
-	if privateKey == nil {
-		if err := InitKeys(); err != nil {
-			return "", err
-		}
-	}
+	return GenerateIDTokenWithOptions(issuer, clientID, sub, email, name, IDTokenOptions{})
+}
 
+// GenerateIDTokenWithOptions creates a signed JWT ID token carrying the nonce,
+// at_hash and auth_time claims described in OIDC Core section 2
+func GenerateIDTokenWithOptions(issuer, clientID, sub, email, name string, opts IDTokenOptions) (string, error) {
 	now := time.Now()
+	authTime := opts.AuthTime
+	if authTime.IsZero() {
+		authTime = now
+	}
 	claims := jwt.MapClaims{
-		"iss":   issuer,
-		"sub":   sub,
-		"aud":   clientID,
-		"exp":   now.Add(time.Hour).Unix(),
-		"iat":   now.Unix(),
-		"nonce": generateNonce(),
+		"iss":       issuer,
+		"sub":       sub,
+		"aud":       clientID,
+		"azp":       clientID,
+		"exp":       now.Add(time.Hour).Unix(),
+		"iat":       now.Unix(),
+		"auth_time": authTime.Unix(),
+	}
+
+	if opts.Nonce != "" {
+		claims["nonce"] = opts.Nonce
+	}
+	if opts.AccessToken != "" {
+		claims["at_hash"] = accessTokenHash(opts.Signing.alg(), opts.AccessToken)
 	}
 
 	// Only include email claim if an email is provided
@@ -63,20 +85,22 @@ func GenerateIDToken(issuer, clientID, sub, email, name string) (string, error)
 		claims["name"] = name
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
-	token.Header["kid"] = keyID
+	for claim, value := range opts.Claims {
+		if _, exists := claims[claim]; !exists {
+			claims[claim] = value
+		}
+	}
 
-	return token.SignedString(privateKey)
+	return signToken(claims, opts.Signing)
 }
 
 // GenerateAccessToken creates a signed JWT access token
 func GenerateAccessToken(issuer, clientID, sub string, scopes []string) (string, error) {
-	if privateKey == nil {
-		if err := InitKeys(); err != nil {
-			return "", err
-		}
-	}
+	return GenerateAccessTokenWithOptions(issuer, clientID, sub, scopes, AccessTokenOptions{})
+}
 
+// GenerateAccessTokenWithOptions creates a JWT access token signed as the options describe
+func GenerateAccessTokenWithOptions(issuer, clientID, sub string, scopes []string, opts AccessTokenOptions) (string, error) {
 	now := time.Now()
 	claims := jwt.MapClaims{
 		"iss":   issuer,
@@ -87,57 +111,113 @@ func GenerateAccessToken(issuer, clientID, sub string, scopes []string) (string,
 		"scope": scopes,
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
-	token.Header["kid"] = keyID
+	for claim, value := range opts.Claims {
+		if _, exists := claims[claim]; !exists {
+			claims[claim] = value
+		}
+	}
 
-	return token.SignedString(privateKey)
+	return signToken(claims, opts.Signing)
 }
 
-// GetJWKS returns the JSON Web Key Set
-func GetJWKS() (map[string]interface{}, error) {
-	if publicKey == nil {
-		if err := InitKeys(); err != nil {
-			return nil, err
+// GenerateUserInfoToken signs userinfo claims as a JWT for the client (OIDC
+// Core section 5.3.2). The issuer and the client become iss and aud.
+func GenerateUserInfoToken(issuer, clientID string, userInfo map[string]interface{}, opts SigningOptions) (string, error) {
+	claims := make(jwt.MapClaims, len(userInfo)+3)
+	for name, value := range userInfo {
+		claims[name] = value
+	}
+	claims["iss"] = issuer
+	claims["aud"] = clientID
+	claims["iat"] = time.Now().Unix()
+
+	return signToken(claims, opts)
+}
+
+// alg returns the signing algorithm, defaulting to DefaultSigningAlgorithm
+func (o SigningOptions) alg() string {
+	if o.Alg == "" {
+		return DefaultSigningAlgorithm
+	}
+	return o.Alg
+}
+
+// signToken signs claims with the active key of the chosen algorithm, or with
+// the client secret for HS256
+func signToken(claims jwt.MapClaims, opts SigningOptions) (string, error) {
+	alg := opts.alg()
+	if alg == "HS256" {
+		if opts.Secret == "" {
+			return "", errors.New("HS256 signing needs a client secret")
 		}
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(opts.Secret))
+	}
+
+	key, err := activeKey(alg)
+	if err != nil {
+		return "", err
 	}
 
-	// Encode the public key components
-	nBytes := publicKey.N.Bytes()
-	eBytes := big.NewInt(int64(publicKey.E)).Bytes()
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(alg), claims)
+	token.Header["kid"] = key.kid
 
-	n := base64.RawURLEncoding.EncodeToString(nBytes)
-	e := base64.RawURLEncoding.EncodeToString(eBytes)
+	return token.SignedString(key.privateKey)
+}
 
-	jwk := map[string]interface{}{
-		"kty": "RSA",
-		"use": "sig",
-		"kid": keyID,
-		"alg": "RS256",
-		"n":   n,
-		"e":   e,
+// PublishedKeySet returns the public signing keys: the active key of every
+// algorithm followed by the rotated-out keys that are still within their grace period
+func PublishedKeySet() (JSONWebKeySet, error) {
+	keys, err := publishedKeys()
+	if err != nil {
+		return JSONWebKeySet{}, err
 	}
 
-	jwks := map[string]interface{}{
-		"keys": []interface{}{jwk},
+	keySet := JSONWebKeySet{Keys: make([]JSONWebKey, 0, len(keys))}
+	for _, key := range keys {
+		jwk, err := NewJSONWebKey(key.privateKey.Public(), key.kid, key.alg)
+		if err != nil {
+			return JSONWebKeySet{}, err
+		}
+		keySet.Keys = append(keySet.Keys, jwk)
+	}
+	return keySet, nil
+}
+
+// GetJWKS returns the JSON Web Key Set as a generic JSON object
+func GetJWKS() (map[string]interface{}, error) {
+	body, err := MarshalJWKS()
+	if err != nil {
+		return nil, err
 	}
 
+	var jwks map[string]interface{}
+	if err := json.Unmarshal(body, &jwks); err != nil {
+		return nil, err
+	}
 	return jwks, nil
 }
 
-// VerifyToken verifies a JWT token and returns the claims
+// VerifyToken verifies a JWT token against the published signing keys and
+// returns the claims. HS256 tokens cannot be verified without the client secret.
 func VerifyToken(tokenString string) (jwt.MapClaims, error) {
-	if publicKey == nil {
-		if err := InitKeys(); err != nil {
-			return nil, err
-		}
+	keys, err := publishedKeys()
+	if err != nil {
+		return nil, err
 	}
 
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		// Validate signing method
-		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		kid, _ := token.Header["kid"].(string)
+		for _, key := range keys {
+			if key.kid != kid {
+				continue
+			}
+			// Validate signing method
+			if token.Method.Alg() != key.alg {
+				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+			}
+			return key.privateKey.Public(), nil
 		}
-		return publicKey, nil
+		return nil, fmt.Errorf("unknown signing key %q", kid)
 	})
 
 	if err != nil {
@@ -151,32 +231,40 @@ func VerifyToken(tokenString string) (jwt.MapClaims, error) {
 	return nil, fmt.Errorf("invalid token")
 }
 
-// generateNonce generates a random nonce for the token
-func generateNonce() string {
-	b := make([]byte, 16)
-	if _, err := rand.Read(b); err != nil {
-		// This should never happen with crypto/rand, but we handle it for safety
-		panic("failed to generate random bytes: " + err.Error())
+// AccessTokenHash computes the at_hash claim for an access token: the
+// base64url-encoded left half of its SHA-256 hash (OIDC Core section 3.1.3.6)
+func AccessTokenHash(accessToken string) string {
+	return accessTokenHash(DefaultSigningAlgorithm, accessToken)
+}
+
+// accessTokenHash computes the at_hash claim with the hash function of the ID
+// token's signing algorithm. Ed25519 signatures use SHA-512.
+func accessTokenHash(alg, accessToken string) string {
+	var hash []byte
+	if alg == "EdDSA" {
+		sum := sha512.Sum512([]byte(accessToken))
+		hash = sum[:]
+	} else {
+		sum := sha256.Sum256([]byte(accessToken))
+		hash = sum[:]
 	}
-	return base64.RawURLEncoding.EncodeToString(b)
+	return base64.RawURLEncoding.EncodeToString(hash[:len(hash)/2])
 }
 
-// GetPublicKey returns the public key (for testing purposes)
+// GetPublicKey returns the active RS256 public key (for testing purposes)
 func GetPublicKey() (*rsa.PublicKey, error) {
-	if publicKey == nil {
-		if err := InitKeys(); err != nil {
-			return nil, err
-		}
+	key, err := activeKey(DefaultSigningAlgorithm)
+	if err != nil {
+		return nil, err
 	}
-	return publicKey, nil
+	return key.privateKey.Public().(*rsa.PublicKey), nil
 }
 
-// GetPublicKeyPEM returns the public key in PEM format
+// GetPublicKeyPEM returns the active RS256 public key in PEM format
 func GetPublicKeyPEM() (string, error) {
-	if publicKey == nil {
-		if err := InitKeys(); err != nil {
-			return "", err
-		}
+	publicKey, err := GetPublicKey()
+	if err != nil {
+		return "", err
 	}
 
 	pubKeyBytes, err := x509.MarshalPKIXPublicKey(publicKey)
@@ -189,9 +277,9 @@ func GetPublicKeyPEM() (string, error) {
 
 // MarshalJWKS returns the JWKS as a JSON byte array
 func MarshalJWKS() ([]byte, error) {
-	jwks, err := GetJWKS()
+	keySet, err := PublishedKeySet()
 	if err != nil {
 		return nil, err
 	}
-	return json.Marshal(jwks)
+	return json.Marshal(keySet)
 }
@@ -1,7 +1,10 @@
 package jwt
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
 	"testing"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
@@ -12,16 +15,106 @@ func TestInitKeys(t *testing.T) {
 		t.Fatalf("Failed to initialize keys: %v", err)
 	}
 
-	if privateKey == nil {
-		t.Error("Private key should not be nil after initialization")
-	}
+	for _, alg := range keyAlgorithms {
+		key, err := activeKey(alg)
+		if err != nil {
+			t.Fatalf("Failed to get active %s key: %v", alg, err)
+		}
+
+		if key.privateKey == nil {
+			t.Errorf("%s private key should not be nil after initialization", alg)
+		}
 
-	if publicKey == nil {
-		t.Error("Public key should not be nil after initialization")
+		if key.kid == "" {
+			t.Errorf("%s key ID should not be empty after initialization", alg)
+		}
 	}
+}
 
-	if keyID == "" {
-		t.Error("Key ID should not be empty after initialization")
+func TestSigningAlgorithms(t *testing.T) {
+	jwks, err := PublishedKeySet()
+	if err != nil {
+		t.Fatalf("Failed to get JWKS: %v", err)
+	}
+	published := make(map[string]JSONWebKey)
+	for _, key := range jwks.Keys {
+		published[key.Kid] = key
+	}
+
+	tests := []struct {
+		alg string
+		kty string
+		crv string
+	}{
+		{alg: "RS256", kty: "RSA"},
+		{alg: "PS256", kty: "RSA"},
+		{alg: "ES256", kty: "EC", crv: "P-256"},
+		{alg: "EdDSA", kty: "OKP", crv: "Ed25519"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.alg, func(t *testing.T) {
+			idToken, err := GenerateIDTokenWithOptions("http://localhost:8080", "test-client", "user-123", "", "", IDTokenOptions{
+				AccessToken: "access-token",
+				Signing:     SigningOptions{Alg: tt.alg},
+			})
+			if err != nil {
+				t.Fatalf("Failed to generate ID token: %v", err)
+			}
+
+			claims, err := VerifyToken(idToken)
+			if err != nil {
+				t.Fatalf("Failed to verify token: %v", err)
+			}
+			if want := accessTokenHash(tt.alg, "access-token"); claims["at_hash"] != want {
+				t.Errorf("Expected at_hash %s, got %v", want, claims["at_hash"])
+			}
+
+			token, _, err := jwt.NewParser().ParseUnverified(idToken, jwt.MapClaims{})
+			if err != nil {
+				t.Fatalf("Failed to parse token: %v", err)
+			}
+			if token.Method.Alg() != tt.alg {
+				t.Errorf("Expected alg %s, got %s", tt.alg, token.Method.Alg())
+			}
+
+			key, ok := published[tokenKid(t, idToken)]
+			if !ok {
+				t.Fatalf("Expected the signing key in the JWKS")
+			}
+			if key.Kty != tt.kty || key.Crv != tt.crv || key.Alg != tt.alg {
+				t.Errorf("Expected a %s %s key for %s, got %+v", tt.kty, tt.crv, tt.alg, key)
+			}
+		})
+	}
+
+	t.Run("HS256", func(t *testing.T) {
+		if _, err := GenerateAccessTokenWithOptions("http://localhost:8080", "test-client", "user-123", nil, AccessTokenOptions{
+			Signing: SigningOptions{Alg: "HS256"},
+		}); err == nil {
+			t.Errorf("Expected HS256 signing without a secret to fail")
+		}
+
+		accessToken, err := GenerateAccessTokenWithOptions("http://localhost:8080", "test-client", "user-123", nil, AccessTokenOptions{
+			Signing: SigningOptions{Alg: "HS256", Secret: "test-secret"},
+		})
+		if err != nil {
+			t.Fatalf("Failed to generate access token: %v", err)
+		}
+		token, err := jwt.Parse(accessToken, func(*jwt.Token) (interface{}, error) {
+			return []byte("test-secret"), nil
+		}, jwt.WithValidMethods([]string{"HS256"}))
+		if err != nil || !token.Valid {
+			t.Errorf("Expected the token to verify with the client secret: %v", err)
+		}
+		if _, ok := token.Header["kid"]; ok {
+			t.Errorf("Expected no kid for an HS256 token")
+		}
+	})
+
+	if _, err := GenerateAccessTokenWithOptions("http://localhost:8080", "test-client", "user-123", nil, AccessTokenOptions{
+		Signing: SigningOptions{Alg: "none"},
+	}); err == nil {
+		t.Errorf("Expected an unsupported algorithm to fail")
 	}
 }
 
@@ -74,6 +167,65 @@ func TestGenerateIDToken(t *testing.T) {
 	}
 }
 
+func TestGenerateIDTokenWithOptions(t *testing.T) {
+	err := InitKeys()
+	if err != nil {
+		t.Fatalf("Failed to initialize keys: %v", err)
+	}
+
+	authTime := time.Now().Add(-5 * time.Minute).Truncate(time.Second)
+	tokenString, err := GenerateIDTokenWithOptions("http://localhost:8080", "test-client", "user-123", "", "", IDTokenOptions{
+		Nonce:       "n-0S6_WzA2Mj",
+		AccessToken: "jHkWEdUXMU1BwAsC4vtUsZwnNgTAPF3v",
+		AuthTime:    authTime,
+	})
+	if err != nil {
+		t.Fatalf("Failed to generate ID token: %v", err)
+	}
+
+	claims, err := VerifyToken(tokenString)
+	if err != nil {
+		t.Fatalf("Failed to verify token: %v", err)
+	}
+
+	if claims["nonce"] != "n-0S6_WzA2Mj" {
+		t.Errorf("Expected nonce to be echoed, got %v", claims["nonce"])
+	}
+	if claims["azp"] != "test-client" {
+		t.Errorf("Expected azp test-client, got %v", claims["azp"])
+	}
+	if claims["auth_time"] != float64(authTime.Unix()) {
+		t.Errorf("Expected auth_time %d, got %v", authTime.Unix(), claims["auth_time"])
+	}
+	if _, ok := claims["iat"].(float64); !ok {
+		t.Errorf("Expected iat claim, got %v", claims["iat"])
+	}
+	// The left half of the SHA-256 of the access token, base64url encoded
+	hash := sha256.Sum256([]byte("jHkWEdUXMU1BwAsC4vtUsZwnNgTAPF3v"))
+	if expected := base64.RawURLEncoding.EncodeToString(hash[:16]); claims["at_hash"] != expected {
+		t.Errorf("Expected at_hash %s, got %v", expected, claims["at_hash"])
+	}
+
+	// Without options there is no nonce or at_hash, and auth_time defaults to now
+	tokenString, err = GenerateIDToken("http://localhost:8080", "test-client", "user-123", "", "")
+	if err != nil {
+		t.Fatalf("Failed to generate ID token: %v", err)
+	}
+	claims, err = VerifyToken(tokenString)
+	if err != nil {
+		t.Fatalf("Failed to verify token: %v", err)
+	}
+	if _, exists := claims["nonce"]; exists {
+		t.Errorf("Expected no nonce claim, got %v", claims["nonce"])
+	}
+	if _, exists := claims["at_hash"]; exists {
+		t.Errorf("Expected no at_hash claim, got %v", claims["at_hash"])
+	}
+	if claims["auth_time"] != claims["iat"] {
+		t.Errorf("Expected auth_time to default to iat, got %v and %v", claims["auth_time"], claims["iat"])
+	}
+}
+
 func TestGenerateAccessToken(t *testing.T) {
 	err := InitKeys()
 	if err != nil {
@@ -0,0 +1,231 @@
+package jwt
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+)
+
+// minRSAKeyBits is the smallest RSA key that may sign tokens (RFC 7518 section 3.3)
+const minRSAKeyBits = 2048
+
+// LoadKeys replaces the key ring with the private keys in the given files, so
+// that tokens and the JWKS survive a restart. A file holds PEM private keys
+// (PKCS #8, PKCS #1 or SEC 1), a private JWK, or a JWK Set with several keys.
+//
+// The first key of each algorithm signs new tokens, further keys only verify
+// them. Algorithms without a loaded key get an ephemeral key as before.
+func LoadKeys(paths ...string) ([]KeyInfo, error) {
+	var loaded []*signingKey
+	for _, path := range paths {
+		data, err := os.ReadFile(path) // #nosec G304 -- the path is supplied by the operator
+		if err != nil {
+			return nil, fmt.Errorf("reading key file: %w", err)
+		}
+		keys, err := parseKeyFile(data)
+		if err != nil {
+			return nil, fmt.Errorf("key file %s: %w", path, err)
+		}
+		loaded = append(loaded, keys...)
+	}
+	if len(loaded) == 0 {
+		return nil, errors.New("no signing keys found")
+	}
+
+	kids := make(map[string]bool, len(loaded))
+	for _, key := range loaded {
+		if kids[key.kid] {
+			return nil, fmt.Errorf("duplicate kid %q", key.kid)
+		}
+		kids[key.kid] = true
+	}
+
+	keyRing.mu.Lock()
+	active := make([]*signingKey, 0, len(keyAlgorithms))
+	var extra []*signingKey
+	for _, alg := range keyAlgorithms {
+		index := slices.IndexFunc(loaded, func(key *signingKey) bool { return key.alg == alg })
+		if index < 0 {
+			privateKey, err := generateKey(alg)
+			if err != nil {
+				keyRing.mu.Unlock()
+				return nil, err
+			}
+			keyRing.serial++
+			active = append(active, &signingKey{
+				kid:        ephemeralKid(keyRing.serial, alg),
+				alg:        alg,
+				privateKey: privateKey,
+				createdAt:  time.Now(),
+			})
+			continue
+		}
+		active = append(active, loaded[index])
+	}
+	for _, key := range loaded {
+		if !slices.Contains(active, key) {
+			extra = append(extra, key)
+		}
+	}
+	keyRing.active = active
+	keyRing.verifyOnly = extra
+	keyRing.retired = nil
+	keyRing.mu.Unlock()
+
+	return ListKeys()
+}
+
+// GenerateSigningKey generates a private JWK for a signing algorithm. Its kid
+// is the RFC 7638 thumbprint of the key.
+func GenerateSigningKey(alg string) (JSONWebKey, error) {
+	privateKey, err := generateKey(alg)
+	if err != nil {
+		return JSONWebKey{}, err
+	}
+	jwk, err := NewPrivateJSONWebKey(privateKey, "", alg)
+	if err != nil {
+		return JSONWebKey{}, err
+	}
+	if jwk.Kid, err = jwk.Thumbprint(); err != nil {
+		return JSONWebKey{}, err
+	}
+	return jwk, nil
+}
+
+// parseKeyFile parses the signing keys of a PEM, JWK or JWK Set file
+func parseKeyFile(data []byte) ([]*signingKey, error) {
+	data = bytes.TrimSpace(data)
+	if !bytes.HasPrefix(data, []byte("{")) {
+		return parsePEMKeys(data)
+	}
+
+	var keySet struct {
+		Keys []JSONWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &keySet); err != nil {
+		return nil, fmt.Errorf("parsing JWK: %w", err)
+	}
+	if keySet.Keys == nil {
+		var jwk JSONWebKey
+		if err := json.Unmarshal(data, &jwk); err != nil {
+			return nil, fmt.Errorf("parsing JWK: %w", err)
+		}
+		keySet.Keys = []JSONWebKey{jwk}
+	}
+
+	var keys []*signingKey
+	for i, jwk := range keySet.Keys {
+		// Encryption keys have no place in the signing key ring
+		if jwk.Use == "enc" {
+			continue
+		}
+		privateKey, err := jwk.PrivateKey()
+		if err != nil {
+			return nil, fmt.Errorf("key %d: %w", i, err)
+		}
+		key, err := newLoadedKey(privateKey, jwk.Kid, jwk.Alg)
+		if err != nil {
+			return nil, fmt.Errorf("key %d: %w", i, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// parsePEMKeys parses every private key block of a PEM file
+func parsePEMKeys(data []byte) ([]*signingKey, error) {
+	var keys []*signingKey
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+
+		var privateKey interface{}
+		var err error
+		switch block.Type {
+		case "PRIVATE KEY":
+			privateKey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		case "RSA PRIVATE KEY":
+			privateKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "EC PRIVATE KEY":
+			privateKey, err = x509.ParseECPrivateKey(block.Bytes)
+		case "EC PARAMETERS":
+			// Written by openssl ecparam ahead of the key itself
+			continue
+		default:
+			return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", block.Type, err)
+		}
+
+		signer, ok := privateKey.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type %T", privateKey)
+		}
+		key, err := newLoadedKey(signer, "", "")
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no PEM private keys found")
+	}
+	return keys, nil
+}
+
+// newLoadedKey checks that a loaded private key suits its algorithm. An empty
+// alg is inferred from the key type and an empty kid is the key's thumbprint.
+func newLoadedKey(privateKey crypto.Signer, kid, alg string) (*signingKey, error) {
+	var algs []string
+	switch key := privateKey.(type) {
+	case *rsa.PrivateKey:
+		if bits := key.N.BitLen(); bits < minRSAKeyBits {
+			return nil, fmt.Errorf("RSA key of %d bits is too small, at least %d bits are required", bits, minRSAKeyBits)
+		}
+		algs = []string{"RS256", "PS256"}
+	case *ecdsa.PrivateKey:
+		if key.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("unsupported EC curve %s, only P-256 keys can sign", key.Curve.Params().Name)
+		}
+		algs = []string{"ES256"}
+	case ed25519.PrivateKey:
+		algs = []string{"EdDSA"}
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", privateKey)
+	}
+
+	if alg == "" {
+		alg = algs[0]
+	}
+	if !slices.Contains(algs, alg) {
+		return nil, fmt.Errorf("algorithm %q does not match the key type", alg)
+	}
+
+	if kid == "" {
+		jwk, err := NewJSONWebKey(privateKey.Public(), "", alg)
+		if err != nil {
+			return nil, err
+		}
+		if kid, err = jwk.Thumbprint(); err != nil {
+			return nil, err
+		}
+	}
+
+	return &signingKey{kid: kid, alg: alg, privateKey: privateKey, createdAt: time.Now()}, nil
+}
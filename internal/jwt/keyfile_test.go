@@ -0,0 +1,203 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPrivateJSONWebKeyRoundTrip(t *testing.T) {
+	for _, alg := range keyAlgorithms {
+		t.Run(alg, func(t *testing.T) {
+			jwk, err := GenerateSigningKey(alg)
+			if err != nil {
+				t.Fatalf("Failed to generate key: %v", err)
+			}
+			if thumbprint, _ := jwk.Thumbprint(); jwk.Kid != thumbprint {
+				t.Errorf("Expected the kid to be the thumbprint %s, got %s", thumbprint, jwk.Kid)
+			}
+
+			privateKey, err := jwk.PrivateKey()
+			if err != nil {
+				t.Fatalf("Failed to decode private key: %v", err)
+			}
+			again, err := NewPrivateJSONWebKey(privateKey, jwk.Kid, alg)
+			if err != nil {
+				t.Fatalf("Failed to encode private key: %v", err)
+			}
+			if again != jwk {
+				t.Errorf("Expected the key to round-trip, got %+v want %+v", again, jwk)
+			}
+		})
+	}
+
+	// A private parameter that does not match the public key is rejected
+	jwk, _ := GenerateSigningKey("ES256")
+	other, _ := GenerateSigningKey("ES256")
+	jwk.D = other.D
+	if _, err := jwk.PrivateKey(); err == nil {
+		t.Errorf("Expected a mismatched EC private key to be rejected")
+	}
+}
+
+func TestLoadKeys(t *testing.T) {
+	dir := t.TempDir()
+
+	rsaKey, _ := GenerateSigningKey("RS256")
+	esKey, _ := GenerateSigningKey("ES256")
+	verifyOnly, _ := GenerateSigningKey("RS256")
+	jwksFile := writeKeyFile(t, dir, "keys.json", JSONWebKeySet{Keys: []JSONWebKey{rsaKey, esKey, verifyOnly}})
+
+	keys, err := LoadKeys(jwksFile)
+	if err != nil {
+		t.Fatalf("Failed to load keys: %v", err)
+	}
+	active := make(map[string]string)
+	for _, key := range keys {
+		if key.Active {
+			active[key.Alg] = key.Kid
+		}
+	}
+	if active["RS256"] != rsaKey.Kid || active["ES256"] != esKey.Kid {
+		t.Errorf("Expected the loaded keys to be active, got %+v", keys)
+	}
+	if active["PS256"] == "" || active["EdDSA"] == "" {
+		t.Errorf("Expected ephemeral keys for the other algorithms, got %+v", keys)
+	}
+	if len(keys) != len(keyAlgorithms)+1 || keys[len(keys)-1].Kid != verifyOnly.Kid || keys[len(keys)-1].Active {
+		t.Errorf("Expected %s to be published but not active, got %+v", verifyOnly.Kid, keys)
+	}
+
+	token, err := GenerateAccessTokenWithOptions("http://localhost:8080", "test-client", "user-123", nil, AccessTokenOptions{
+		Signing: SigningOptions{Alg: "ES256"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to generate access token: %v", err)
+	}
+	if kid := tokenKid(t, token); kid != esKey.Kid {
+		t.Errorf("Expected token signed with %s, got %s", esKey.Kid, kid)
+	}
+
+	// Loading the same file again, as after a restart, keeps tokens valid
+	if _, err := LoadKeys(jwksFile); err != nil {
+		t.Fatalf("Failed to reload keys: %v", err)
+	}
+	if _, err := VerifyToken(token); err != nil {
+		t.Errorf("Expected the token to verify after reloading the keys: %v", err)
+	}
+
+	// Rotation retires the active keys but keeps the verify-only key
+	if _, err := RotateKeys(0); err != nil {
+		t.Fatalf("Failed to rotate keys: %v", err)
+	}
+	keys, err = ListKeys()
+	if err != nil {
+		t.Fatalf("Failed to list keys: %v", err)
+	}
+	if len(keys) != len(keyAlgorithms)+1 || keys[len(keys)-1].Kid != verifyOnly.Kid || keys[len(keys)-1].RetiresAt != nil {
+		t.Errorf("Expected %s to stay published after rotation, got %+v", verifyOnly.Kid, keys)
+	}
+}
+
+func TestLoadKeys_PEM(t *testing.T) {
+	dir := t.TempDir()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate EC key: %v", err)
+	}
+	ecDER, _ := x509.MarshalECPrivateKey(ecKey)
+	pemFile := filepath.Join(dir, "keys.pem")
+	pemData := append(
+		pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: ecDER})...,
+	)
+	if err := os.WriteFile(pemFile, pemData, 0o600); err != nil {
+		t.Fatalf("Failed to write key file: %v", err)
+	}
+
+	keys, err := LoadKeys(pemFile)
+	if err != nil {
+		t.Fatalf("Failed to load keys: %v", err)
+	}
+	wantKid, _ := NewJSONWebKey(&rsaKey.PublicKey, "", "RS256")
+	thumbprint, _ := wantKid.Thumbprint()
+	if keys[0].Alg != "RS256" || keys[0].Kid != thumbprint {
+		t.Errorf("Expected the RSA key as RS256 with kid %s, got %+v", thumbprint, keys[0])
+	}
+	if keys[2].Alg != "ES256" || strings.HasPrefix(keys[2].Kid, "mock-key-") {
+		t.Errorf("Expected the loaded EC key for ES256, got %+v", keys[2])
+	}
+}
+
+func TestLoadKeys_Errors(t *testing.T) {
+	dir := t.TempDir()
+
+	rsaKey, _ := GenerateSigningKey("RS256")
+	p384Key, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	p384JWK, _ := NewPrivateJSONWebKey(p384Key, "p384", "")
+	mismatched := rsaKey
+	mismatched.Alg = "ES256"
+	publicOnly, _ := GenerateSigningKey("EdDSA")
+	publicOnly.D = ""
+	smallRSAKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	smallRSAJWK, _ := NewPrivateJSONWebKey(smallRSAKey, "small", "")
+
+	tests := []struct {
+		name    string
+		content interface{}
+	}{
+		{name: "duplicate kid", content: JSONWebKeySet{Keys: []JSONWebKey{rsaKey, rsaKey}}},
+		{name: "unsupported curve", content: p384JWK},
+		{name: "algorithm mismatch", content: mismatched},
+		{name: "public key only", content: publicOnly},
+		{name: "RSA key under 2048 bits", content: smallRSAJWK},
+		{name: "not a key", content: "not a key file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeKeyFile(t, dir, "keys", tt.content)
+			if _, err := LoadKeys(path); err == nil {
+				t.Errorf("Expected loading to fail")
+			}
+		})
+	}
+
+	if _, err := LoadKeys(filepath.Join(dir, "missing.json")); err == nil {
+		t.Errorf("Expected a missing file to fail")
+	}
+}
+
+// writeKeyFile writes a key file, encoding content as JSON unless it is a string
+func writeKeyFile(t *testing.T, dir, name string, content interface{}) string {
+	t.Helper()
+
+	data, ok := content.(string)
+	if !ok {
+		encoded, err := json.Marshal(content)
+		if err != nil {
+			t.Fatalf("Failed to encode key file: %v", err)
+		}
+		data = string(encoded)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("Failed to write key file: %v", err)
+	}
+	return path
+}
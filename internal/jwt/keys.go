@@ -0,0 +1,303 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSigningAlgorithm signs tokens when no other algorithm is chosen
+const DefaultSigningAlgorithm = "RS256"
+
+// keyAlgorithms are the asymmetric signing algorithms. The key ring holds an
+// active key for each of them.
+var keyAlgorithms = []string{"RS256", "PS256", "ES256", "EdDSA"}
+
+// SigningAlgorithms lists the algorithms tokens can be signed with. HS256
+// tokens are signed with the client secret and have no key in the JWKS.
+func SigningAlgorithms() []string {
+	return append(slices.Clone(keyAlgorithms), "HS256")
+}
+
+// IsSigningAlgorithm reports whether tokens can be signed with alg
+func IsSigningAlgorithm(alg string) bool {
+	return slices.Contains(SigningAlgorithms(), alg)
+}
+
+// signingKey is a key pair of the signing key ring
+type signingKey struct {
+	kid        string
+	alg        string
+	privateKey crypto.Signer
+	createdAt  time.Time
+	// retiresAt is when a rotated-out key stops being published. Zero for the
+	// active keys and for loaded keys that only verify tokens.
+	retiresAt time.Time
+}
+
+// KeyInfo describes a signing key for the admin API
+type KeyInfo struct {
+	Kid       string     `json:"kid"`
+	Alg       string     `json:"alg"`
+	Active    bool       `json:"active"`
+	CreatedAt time.Time  `json:"created_at"`
+	RetiresAt *time.Time `json:"retires_at,omitempty"`
+}
+
+// ErrActiveKey is returned when the active signing key would be removed
+var ErrActiveKey = errors.New("the active signing key cannot be removed")
+
+// ErrUnknownKey is returned for a kid that is not in the key ring
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// keyRing holds the active signing keys, one per algorithm in keyAlgorithms
+// order, and the further published keys: loaded keys that only verify tokens,
+// which rotation leaves in place, and rotated-out keys until their grace
+// period ends
+var keyRing struct {
+	mu         sync.RWMutex
+	active     []*signingKey
+	verifyOnly []*signingKey
+	retired    []*signingKey
+	serial     int
+}
+
+// InitKeys initializes the signing key of every algorithm
+func InitKeys() error {
+	keyRing.mu.RLock()
+	initialized := keyRing.active != nil
+	keyRing.mu.RUnlock()
+	if initialized {
+		return nil
+	}
+
+	keyRing.mu.Lock()
+	defer keyRing.mu.Unlock()
+
+	if keyRing.active != nil {
+		return nil
+	}
+	keys, err := newSigningKeys()
+	if err != nil {
+		return err
+	}
+	keyRing.active = keys
+	return nil
+}
+
+// RotateKeys replaces the active key of every algorithm and returns the new
+// kids. The previous keys keep verifying tokens and stay in the JWKS for the
+// grace period.
+func RotateKeys(gracePeriod time.Duration) ([]string, error) {
+	if err := InitKeys(); err != nil {
+		return nil, err
+	}
+
+	keyRing.mu.Lock()
+	defer keyRing.mu.Unlock()
+
+	keys, err := newSigningKeys()
+	if err != nil {
+		return nil, err
+	}
+	retiresAt := time.Now().Add(gracePeriod)
+	for _, previous := range keyRing.active {
+		previous.retiresAt = retiresAt
+	}
+	keyRing.retired = append(slices.Clone(keyRing.active), keyRing.retired...)
+	keyRing.active = keys
+	pruneRetiredKeys()
+
+	kids := make([]string, 0, len(keys))
+	for _, key := range keys {
+		kids = append(kids, key.kid)
+	}
+	return kids, nil
+}
+
+// RemoveKey immediately stops publishing a rotated-out or verify-only key
+func RemoveKey(kid string) error {
+	keyRing.mu.Lock()
+	defer keyRing.mu.Unlock()
+
+	for _, key := range keyRing.active {
+		if key.kid == kid {
+			return ErrActiveKey
+		}
+	}
+	for i, key := range keyRing.verifyOnly {
+		if key.kid == kid {
+			keyRing.verifyOnly = append(keyRing.verifyOnly[:i], keyRing.verifyOnly[i+1:]...)
+			return nil
+		}
+	}
+	for i, key := range keyRing.retired {
+		if key.kid == kid {
+			keyRing.retired = append(keyRing.retired[:i], keyRing.retired[i+1:]...)
+			return nil
+		}
+	}
+	return ErrUnknownKey
+}
+
+// ListKeys describes the published signing keys, the active keys first
+func ListKeys() ([]KeyInfo, error) {
+	if err := InitKeys(); err != nil {
+		return nil, err
+	}
+
+	keyRing.mu.RLock()
+	defer keyRing.mu.RUnlock()
+	keys := publishedKeysLocked()
+	infos := make([]KeyInfo, 0, len(keys))
+	for _, key := range keys {
+		info := KeyInfo{Kid: key.kid, Alg: key.alg, Active: slices.Contains(keyRing.active, key), CreatedAt: key.createdAt}
+		if !key.retiresAt.IsZero() {
+			retiresAt := key.retiresAt
+			info.RetiresAt = &retiresAt
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// StartKeyRotation rotates the signing keys every interval until stop is called
+func StartKeyRotation(interval, gracePeriod time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				kids, err := RotateKeys(gracePeriod)
+				if err != nil {
+					log.Printf("Scheduled key rotation failed: %v", err)
+					continue
+				}
+				log.Printf("Rotated signing keys, now signing with %s", strings.Join(kids, ", "))
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			ticker.Stop()
+			close(done)
+		})
+	}
+}
+
+// activeKey returns the key that signs new tokens with alg
+func activeKey(alg string) (*signingKey, error) {
+	if err := InitKeys(); err != nil {
+		return nil, err
+	}
+
+	keyRing.mu.RLock()
+	defer keyRing.mu.RUnlock()
+	for _, key := range keyRing.active {
+		if key.alg == alg {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported signing algorithm %q", alg)
+}
+
+// publishedKeys returns the active keys followed by the verify-only keys and
+// the rotated-out keys whose grace period has not ended yet
+func publishedKeys() ([]*signingKey, error) {
+	if err := InitKeys(); err != nil {
+		return nil, err
+	}
+
+	keyRing.mu.RLock()
+	defer keyRing.mu.RUnlock()
+	return publishedKeysLocked(), nil
+}
+
+// publishedKeysLocked returns the published keys without dropping the retired
+// ones, which only happens on rotation. The caller must hold the lock.
+func publishedKeysLocked() []*signingKey {
+	now := time.Now()
+	keys := append(slices.Clone(keyRing.active), keyRing.verifyOnly...)
+	for _, key := range keyRing.retired {
+		if key.publishedAt(now) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// pruneRetiredKeys drops rotated-out keys past their grace period. The caller
+// must hold the write lock.
+func pruneRetiredKeys() {
+	now := time.Now()
+	kept := keyRing.retired[:0]
+	for _, key := range keyRing.retired {
+		if key.publishedAt(now) {
+			kept = append(kept, key)
+		}
+	}
+	keyRing.retired = kept
+}
+
+// publishedAt reports whether a rotated-out key is still published at the
+// given time
+func (k *signingKey) publishedAt(now time.Time) bool {
+	return now.Before(k.retiresAt)
+}
+
+// newSigningKeys generates the next key of every algorithm. The caller must
+// hold the write lock.
+func newSigningKeys() ([]*signingKey, error) {
+	keyRing.serial++
+	now := time.Now()
+
+	keys := make([]*signingKey, 0, len(keyAlgorithms))
+	for _, alg := range keyAlgorithms {
+		privateKey, err := generateKey(alg)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, &signingKey{
+			kid:        ephemeralKid(keyRing.serial, alg),
+			alg:        alg,
+			privateKey: privateKey,
+			createdAt:  now,
+		})
+	}
+	return keys, nil
+}
+
+// ephemeralKid names a generated key after its generation and algorithm
+func ephemeralKid(serial int, alg string) string {
+	return fmt.Sprintf("mock-key-%d-%s", serial, strings.ToLower(alg))
+}
+
+// generateKey generates a private key for a signing algorithm
+func generateKey(alg string) (crypto.Signer, error) {
+	switch alg {
+	case "RS256", "PS256":
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case "ES256":
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "EdDSA":
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		return privateKey, err
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+}
@@ -0,0 +1,134 @@
+package jwt
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestRotateKeys(t *testing.T) {
+	if err := InitKeys(); err != nil {
+		t.Fatalf("Failed to initialize keys: %v", err)
+	}
+
+	oldToken, err := GenerateAccessToken("http://localhost:8080", "test-client", "user-123", nil)
+	if err != nil {
+		t.Fatalf("Failed to generate access token: %v", err)
+	}
+	oldKid := tokenKid(t, oldToken)
+
+	newKids, err := RotateKeys(time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to rotate keys: %v", err)
+	}
+	if len(newKids) != len(keyAlgorithms) {
+		t.Fatalf("expected a new key per algorithm, got %v", newKids)
+	}
+	newKid := newKids[0]
+	if newKid == oldKid {
+		t.Fatalf("expected a new kid, got %s again", newKid)
+	}
+
+	// New tokens are signed with the new key, and old tokens still verify
+	newToken, err := GenerateAccessToken("http://localhost:8080", "test-client", "user-123", nil)
+	if err != nil {
+		t.Fatalf("Failed to generate access token: %v", err)
+	}
+	if kid := tokenKid(t, newToken); kid != newKid {
+		t.Errorf("expected token signed with %s, got %s", newKid, kid)
+	}
+	if _, err := VerifyToken(oldToken); err != nil {
+		t.Errorf("expected token of the retiring key to verify: %v", err)
+	}
+
+	// Both generations are published, the active keys first
+	keys, err := ListKeys()
+	if err != nil {
+		t.Fatalf("Failed to list keys: %v", err)
+	}
+	n := len(keyAlgorithms)
+	if len(keys) < 2*n || keys[0].Kid != newKid || !keys[0].Active || keys[n].Kid != oldKid || keys[n].Active || keys[n].RetiresAt == nil {
+		t.Fatalf("expected active %s followed by retiring %s, got %+v", newKid, oldKid, keys)
+	}
+	jwks, _ := GetJWKS()
+	if published := jwks["keys"].([]interface{}); len(published) != len(keys) {
+		t.Errorf("expected %d keys in the JWKS, got %d", len(keys), len(published))
+	}
+
+	if err := RemoveKey(newKid); !errors.Is(err, ErrActiveKey) {
+		t.Errorf("expected ErrActiveKey, got %v", err)
+	}
+	if err := RemoveKey("unknown-kid"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("expected ErrUnknownKey, got %v", err)
+	}
+	if err := RemoveKey(oldKid); err != nil {
+		t.Errorf("expected retiring key to be removed: %v", err)
+	}
+	if _, err := VerifyToken(oldToken); err == nil {
+		t.Errorf("expected token of a removed key to be rejected")
+	}
+
+	// Without a grace period the previous key is dropped right away
+	if _, err := RotateKeys(0); err != nil {
+		t.Fatalf("Failed to rotate keys: %v", err)
+	}
+	if _, err := VerifyToken(newToken); err == nil {
+		t.Errorf("expected token of a retired key to be rejected")
+	}
+}
+
+func TestRetiredKeyGracePeriodEnds(t *testing.T) {
+	before, err := activeKey(DefaultSigningAlgorithm)
+	if err != nil {
+		t.Fatalf("Failed to get active key: %v", err)
+	}
+	if _, err := RotateKeys(20 * time.Millisecond); err != nil {
+		t.Fatalf("Failed to rotate keys: %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+
+	// The key is no longer published even though it is only dropped from the
+	// key ring on the next rotation
+	keys, err := ListKeys()
+	if err != nil {
+		t.Fatalf("Failed to list keys: %v", err)
+	}
+	for _, key := range keys {
+		if key.Kid == before.kid {
+			t.Errorf("expected key %s to be unpublished after its grace period, got %+v", before.kid, keys)
+		}
+	}
+}
+
+func TestStartKeyRotation(t *testing.T) {
+	before, err := activeKey(DefaultSigningAlgorithm)
+	if err != nil {
+		t.Fatalf("Failed to get active key: %v", err)
+	}
+
+	stop := StartKeyRotation(10*time.Millisecond, time.Hour)
+	defer stop()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if after, _ := activeKey(DefaultSigningAlgorithm); after.kid != before.kid {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("expected the timer to rotate the signing key")
+}
+
+// tokenKid returns the kid header of a token
+func tokenKid(t *testing.T, tokenString string) string {
+	t.Helper()
+
+	token, _, err := jwt.NewParser().ParseUnverified(tokenString, jwt.MapClaims{})
+	if err != nil {
+		t.Fatalf("Failed to parse token: %v", err)
+	}
+	kid, _ := token.Header["kid"].(string)
+	return kid
+}
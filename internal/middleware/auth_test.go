@@ -18,6 +18,10 @@ func TestValidateToken(t *testing.T) {
 	store.StoreToken(validToken, "client-123")
 	store.StoreAuthCode("client-123", &models.AuthRequest{ClientID: "client-123"})
 
+	// Add a token that is revoked before the requests are made
+	store.StoreToken("revoked-token", "client-123")
+	store.RevokeToken("revoked-token")
+
 	tests := []struct {
 		name           string
 		authorization  string
@@ -38,6 +42,11 @@ func TestValidateToken(t *testing.T) {
 			authorization:  "Bearer invalid-token",
 			expectedStatus: http.StatusUnauthorized,
 		},
+		{
+			name:           "Revoked token",
+			authorization:  "Bearer revoked-token",
+			expectedStatus: http.StatusUnauthorized,
+		},
 		{
 			name:           "Malformed token",
 			authorization:  "InvalidHeader",
@@ -0,0 +1,170 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// ClaimsRequest is the claims parameter of an authorization request (OIDC
+// Core section 5.5). It asks for individual claims in the ID token or the
+// userinfo response, whether or not the granted scopes release them.
+type ClaimsRequest struct {
+	UserInfo map[string]*ClaimRequest `json:"userinfo,omitempty"`
+	IDToken  map[string]*ClaimRequest `json:"id_token,omitempty"`
+}
+
+// ClaimRequest qualifies a single requested claim. A nil ClaimRequest (JSON
+// null) requests the claim in the default manner.
+type ClaimRequest struct {
+	Essential bool          `json:"essential,omitempty"`
+	Value     interface{}   `json:"value,omitempty"`
+	Values    []interface{} `json:"values,omitempty"`
+}
+
+// ParseClaimsRequest parses the JSON claims parameter. An empty parameter
+// returns nil.
+func ParseClaimsRequest(param string) (*ClaimsRequest, error) {
+	if param == "" {
+		return nil, nil
+	}
+	var request ClaimsRequest
+	if err := json.Unmarshal([]byte(param), &request); err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// Clone returns a copy of the claims request. Requested values are shared.
+func (c *ClaimsRequest) Clone() *ClaimsRequest {
+	if c == nil {
+		return nil
+	}
+	return &ClaimsRequest{UserInfo: copyClaimRequests(c.UserInfo), IDToken: copyClaimRequests(c.IDToken)}
+}
+
+// copyClaimRequests copies a set of claim requests, keeping null requests null
+func copyClaimRequests(requests map[string]*ClaimRequest) map[string]*ClaimRequest {
+	if requests == nil {
+		return nil
+	}
+	requestsCopy := make(map[string]*ClaimRequest, len(requests))
+	for claim, request := range requests {
+		if request != nil {
+			requestCopy := *request
+			requestCopy.Values = append([]interface{}(nil), request.Values...)
+			request = &requestCopy
+		}
+		requestsCopy[claim] = request
+	}
+	return requestsCopy
+}
+
+// Accepts reports whether a claim value satisfies the requested value or
+// values. A request without either accepts any value.
+func (r *ClaimRequest) Accepts(value interface{}) bool {
+	if r == nil || (r.Value == nil && len(r.Values) == 0) {
+		return true
+	}
+	if r.Value != nil {
+		return reflect.DeepEqual(r.Value, value)
+	}
+	for _, accepted := range r.Values {
+		if reflect.DeepEqual(accepted, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReleasedClaims returns the claims released by the granted scope (see
+// FilterClaims) plus the individually requested claims. A requested claim with
+// a value or values is only added when the user's claim matches one of them.
+func ReleasedClaims(claims map[string]interface{}, scope string, custom []*Scope, requested map[string]*ClaimRequest) map[string]interface{} {
+	released := FilterClaims(claims, scope, custom)
+	for name, request := range requested {
+		if value, exists := claims[name]; exists && request.Accepts(value) {
+			released[name] = value
+		}
+	}
+	return released
+}
+
+// reservedClaims are set by the server from the grant and cannot be replaced
+// by custom claims
+var reservedClaims = map[string]bool{
+	"iss": true, "sub": true, "aud": true, "azp": true, "exp": true, "iat": true, "nbf": true,
+	"jti": true, "auth_time": true, "nonce": true, "at_hash": true, "scope": true, "client_id": true,
+}
+
+// CustomClaims are arbitrary JSON claims added to the tokens issued for a user,
+// for a client, or globally. ID tokens and access tokens carry separate sets.
+type CustomClaims struct {
+	IDToken     map[string]interface{} `json:"id_token,omitempty"`
+	AccessToken map[string]interface{} `json:"access_token,omitempty"`
+}
+
+// Validate checks that no custom claim is unnamed or replaces a claim the
+// server sets. A nil CustomClaims is valid.
+func (c *CustomClaims) Validate() error {
+	if c == nil {
+		return nil
+	}
+	for _, set := range []struct {
+		name   string
+		claims map[string]interface{}
+	}{
+		{"id_token", c.IDToken},
+		{"access_token", c.AccessToken},
+	} {
+		for claim := range set.claims {
+			if claim == "" {
+				return fmt.Errorf("%s claims cannot have an empty name", set.name)
+			}
+			if reservedClaims[claim] {
+				return fmt.Errorf("%s claim %q is set by the server and cannot be customized", set.name, claim)
+			}
+		}
+	}
+	return nil
+}
+
+// Clone returns a copy of the custom claims. Claim values are shared.
+func (c *CustomClaims) Clone() *CustomClaims {
+	if c == nil {
+		return nil
+	}
+	return &CustomClaims{IDToken: copyClaims(c.IDToken), AccessToken: copyClaims(c.AccessToken)}
+}
+
+// MergeCustomClaims combines custom claims from the least to the most specific
+// level (global, client, user). A claim set at a more specific level replaces
+// the value of a less specific one entirely, nested objects included. Nil
+// levels are skipped.
+func MergeCustomClaims(levels ...*CustomClaims) CustomClaims {
+	merged := CustomClaims{IDToken: map[string]interface{}{}, AccessToken: map[string]interface{}{}}
+	for _, level := range levels {
+		if level == nil {
+			continue
+		}
+		for claim, value := range level.IDToken {
+			merged.IDToken[claim] = value
+		}
+		for claim, value := range level.AccessToken {
+			merged.AccessToken[claim] = value
+		}
+	}
+	return merged
+}
+
+// copyClaims returns a shallow copy of a claim set, or nil for a nil set
+func copyClaims(claims map[string]interface{}) map[string]interface{} {
+	if claims == nil {
+		return nil
+	}
+	claimsCopy := make(map[string]interface{}, len(claims))
+	for claim, value := range claims {
+		claimsCopy[claim] = value
+	}
+	return claimsCopy
+}
@@ -0,0 +1,123 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseClaimsRequest(t *testing.T) {
+	request, err := ParseClaimsRequest(`{"userinfo":{"email":null,"locale":{"values":["en","fr"]}},"id_token":{"auth_time":{"essential":true}}}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := request.UserInfo["email"]; !ok || request.UserInfo["email"] != nil {
+		t.Errorf("expected a null email request, got %+v", request.UserInfo)
+	}
+	if !request.IDToken["auth_time"].Essential {
+		t.Errorf("expected an essential auth_time request, got %+v", request.IDToken)
+	}
+
+	if request, err := ParseClaimsRequest(""); request != nil || err != nil {
+		t.Errorf("expected nil for an empty parameter, got %+v, %v", request, err)
+	}
+	for _, param := range []string{`[]`, `{"userinfo":["email"]}`, `{"id_token":{"email":true}}`} {
+		if _, err := ParseClaimsRequest(param); err == nil {
+			t.Errorf("expected an error for %s", param)
+		}
+	}
+}
+
+func TestClaimsRequestClone(t *testing.T) {
+	original, _ := ParseClaimsRequest(`{"userinfo":{"email":null,"locale":{"values":["en","fr"]}},"id_token":{"auth_time":{"essential":true}}}`)
+	clone := original.Clone()
+	if !reflect.DeepEqual(original, clone) {
+		t.Fatalf("Clone() = %+v; want %+v", clone, original)
+	}
+
+	clone.UserInfo["email"] = &ClaimRequest{Value: "x"}
+	clone.UserInfo["locale"].Values[0] = "de"
+	clone.IDToken["auth_time"].Essential = false
+	if original.UserInfo["email"] != nil || original.UserInfo["locale"].Values[0] != "en" || !original.IDToken["auth_time"].Essential {
+		t.Errorf("Clone is not a deep copy - original was modified: %+v", original)
+	}
+
+	var nilRequest *ClaimsRequest
+	if nilRequest.Clone() != nil {
+		t.Error("Cloning nil should return nil")
+	}
+}
+
+func TestReleasedClaims(t *testing.T) {
+	claims := map[string]interface{}{
+		"sub":          "alice",
+		"email":        "alice@example.com",
+		"phone_number": "+15551234567",
+		"locale":       "en",
+	}
+	requested := map[string]*ClaimRequest{
+		"phone_number": nil,
+		"locale":       {Values: []interface{}{"fr", "de"}},
+		"email":        {Value: "alice@example.com", Essential: true},
+		"nickname":     nil,
+	}
+
+	released := ReleasedClaims(claims, "openid", nil, requested)
+	want := map[string]interface{}{"sub": "alice", "email": "alice@example.com", "phone_number": "+15551234567"}
+	if !reflect.DeepEqual(released, want) {
+		t.Errorf("ReleasedClaims() = %v; want %v", released, want)
+	}
+
+	// A value mismatch does not withdraw a claim the scope releases
+	released = ReleasedClaims(claims, "openid profile", nil, requested)
+	if released["locale"] != "en" {
+		t.Errorf("expected the profile scope to release locale, got %v", released)
+	}
+}
+
+func TestCustomClaimsValidate(t *testing.T) {
+	testCases := []struct {
+		name   string
+		claims *CustomClaims
+		valid  bool
+	}{
+		{"Nil", nil, true},
+		{"Nested claims", &CustomClaims{IDToken: map[string]interface{}{"tenant_id": "t1", "https://example.com/claims": map[string]interface{}{"plan": "pro"}}}, true},
+		{"Empty claim name", &CustomClaims{AccessToken: map[string]interface{}{"": "x"}}, false},
+		{"Reserved ID token claim", &CustomClaims{IDToken: map[string]interface{}{"sub": "other"}}, false},
+		{"Reserved access token claim", &CustomClaims{AccessToken: map[string]interface{}{"scope": "admin"}}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.claims.Validate(); (err == nil) != tc.valid {
+				t.Errorf("Validate() = %v; want valid %v", err, tc.valid)
+			}
+		})
+	}
+}
+
+func TestMergeCustomClaims(t *testing.T) {
+	global := &CustomClaims{
+		IDToken:     map[string]interface{}{"tenant_id": "global", "env": "test"},
+		AccessToken: map[string]interface{}{"tenant_id": "global"},
+	}
+	client := &CustomClaims{IDToken: map[string]interface{}{"tenant_id": "client", "https://example.com/claims": map[string]interface{}{"plan": "free", "seats": 1.0}}}
+	user := &CustomClaims{IDToken: map[string]interface{}{"https://example.com/claims": map[string]interface{}{"plan": "pro"}}}
+
+	merged := MergeCustomClaims(global, nil, client, user)
+
+	want := map[string]interface{}{
+		"tenant_id":                  "client",
+		"env":                        "test",
+		"https://example.com/claims": map[string]interface{}{"plan": "pro"},
+	}
+	if !reflect.DeepEqual(merged.IDToken, want) {
+		t.Errorf("IDToken = %v; want %v", merged.IDToken, want)
+	}
+	if !reflect.DeepEqual(merged.AccessToken, map[string]interface{}{"tenant_id": "global"}) {
+		t.Errorf("AccessToken = %v; want the global tenant_id only", merged.AccessToken)
+	}
+	if len(global.IDToken) != 2 {
+		t.Errorf("expected the levels to be left unchanged, got %v", global.IDToken)
+	}
+}
@@ -0,0 +1,364 @@
+package models
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// Client types as defined in RFC 6749 section 2.1
+const (
+	ClientTypeConfidential = "confidential"
+	ClientTypePublic       = "public"
+)
+
+// Subject identifier types (OIDC Core section 8)
+const (
+	SubjectTypePublic   = "public"
+	SubjectTypePairwise = "pairwise"
+)
+
+// Token endpoint authentication methods (OIDC Core section 9)
+var tokenEndpointAuthMethods = map[string]bool{
+	"client_secret_basic": true,
+	"client_secret_post":  true,
+	"client_secret_jwt":   true,
+	"private_key_jwt":     true,
+	"none":                true,
+}
+
+// Token signing algorithms a client may register (OIDC Registration section 2).
+// HS256 tokens are signed with the client secret.
+var tokenSigningAlgorithms = map[string]bool{
+	"RS256": true,
+	"PS256": true,
+	"ES256": true,
+	"EdDSA": true,
+	"HS256": true,
+}
+
+// Key management and content encryption algorithms a client may register for
+// encrypted responses (OIDC Registration section 2)
+var (
+	keyManagementAlgorithms = map[string]bool{
+		"RSA-OAEP-256": true,
+		"ECDH-ES":      true,
+	}
+	contentEncryptionAlgorithms = map[string]bool{
+		"A128CBC-HS256": true,
+		"A256GCM":       true,
+	}
+)
+
+// Client represents an OAuth2 client known to the mock server
+type Client struct {
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret,omitempty"`
+	// ClientSecrets are further accepted secrets, e.g. while a secret is being rotated
+	ClientSecrets []string `json:"client_secrets,omitempty"`
+	// ClientType is "public" or "confidential". Empty means confidential when
+	// the client has a secret and public otherwise.
+	ClientType string `json:"client_type,omitempty"`
+	// RedirectURIs lists the allowed redirect URIs. It is required when the
+	// client may use the authorization_code grant.
+	RedirectURIs []string `json:"redirect_uris,omitempty"`
+	// AllowedScopes limits the scopes the client may request. Empty allows any scope.
+	AllowedScopes []string `json:"allowed_scopes,omitempty"`
+	// GrantTypes limits the grant types the client may use. Empty allows any grant type.
+	GrantTypes []string `json:"grant_types,omitempty"`
+	// TokenEndpointAuthMethod is the only authentication method the client may
+	// use at the token endpoint. Empty allows any method.
+	TokenEndpointAuthMethod string `json:"token_endpoint_auth_method,omitempty"`
+	// JWKS is the client's public JWK Set, used to verify private_key_jwt assertions
+	JWKS json.RawMessage `json:"jwks,omitempty"`
+	// JWKSURI is where the client publishes its JWK Set, used when JWKS is empty
+	JWKSURI    string `json:"jwks_uri,omitempty"`
+	ClientName string `json:"client_name,omitempty"`
+	// IDTokenSignedResponseAlg is the algorithm the client's ID tokens are
+	// signed with. Empty uses the server default.
+	IDTokenSignedResponseAlg string `json:"id_token_signed_response_alg,omitempty"`
+	// AccessTokenSignedResponseAlg is the algorithm the client's access tokens
+	// are signed with. Empty uses the server default.
+	AccessTokenSignedResponseAlg string `json:"access_token_signed_response_alg,omitempty"`
+	// IDTokenEncryptedResponseAlg encrypts ID tokens to the client's
+	// encryption key when set. IDTokenEncryptedResponseEnc defaults to A128CBC-HS256.
+	IDTokenEncryptedResponseAlg string `json:"id_token_encrypted_response_alg,omitempty"`
+	IDTokenEncryptedResponseEnc string `json:"id_token_encrypted_response_enc,omitempty"`
+	// UserinfoSignedResponseAlg makes userinfo responses JWTs signed with this
+	// algorithm. Empty returns JSON unless the request accepts application/jwt.
+	UserinfoSignedResponseAlg string `json:"userinfo_signed_response_alg,omitempty"`
+	// UserinfoEncryptedResponseAlg encrypts userinfo responses to the client's
+	// encryption key when set. UserinfoEncryptedResponseEnc defaults to A128CBC-HS256.
+	UserinfoEncryptedResponseAlg string `json:"userinfo_encrypted_response_alg,omitempty"`
+	UserinfoEncryptedResponseEnc string `json:"userinfo_encrypted_response_enc,omitempty"`
+	// SubjectType is "public" or "pairwise". Pairwise clients receive a sub
+	// derived from the user and their sector, so that clients of different
+	// sectors cannot correlate users. Empty means public.
+	SubjectType string `json:"subject_type,omitempty"`
+	// SectorIdentifierURI names the sector of a pairwise client by its host.
+	// Empty uses the host of the redirect URIs.
+	SectorIdentifierURI string `json:"sector_identifier_uri,omitempty"`
+	// CustomClaims are added to the tokens issued to the client. User custom
+	// claims take precedence over them.
+	CustomClaims *CustomClaims `json:"custom_claims,omitempty"`
+	// RegistrationAccessToken authorizes RFC 7592 management of a dynamically
+	// registered client. Empty for clients registered by other means.
+	RegistrationAccessToken string `json:"registration_access_token,omitempty"`
+}
+
+// Validate checks that the client registration is complete and consistent
+func (c *Client) Validate() error {
+	switch {
+	case c.ClientID == "":
+		return errors.New("missing client_id")
+	case c.ClientType != "" && c.ClientType != ClientTypePublic && c.ClientType != ClientTypeConfidential:
+		return errors.New(`client_type must be "public" or "confidential"`)
+	case c.TokenEndpointAuthMethod != "" && !tokenEndpointAuthMethods[c.TokenEndpointAuthMethod]:
+		return fmt.Errorf("unsupported token_endpoint_auth_method %q", c.TokenEndpointAuthMethod)
+	case c.TokenEndpointAuthMethod == "none" && c.ClientType == ClientTypeConfidential:
+		return errors.New(`confidential clients cannot use token_endpoint_auth_method "none"`)
+	case len(c.JWKS) > 0 && c.JWKSURI != "":
+		return errors.New("jwks and jwks_uri cannot both be set")
+	case c.TokenEndpointAuthMethod == "private_key_jwt" && !c.HasKeys():
+		return errors.New("private_key_jwt clients need a jwks or jwks_uri")
+	case c.ClientType == ClientTypeConfidential && len(c.Secrets()) == 0 && !c.HasKeys():
+		return errors.New("confidential clients need a client_secret, jwks or jwks_uri")
+	case c.SubjectType != "" && c.SubjectType != SubjectTypePublic && c.SubjectType != SubjectTypePairwise:
+		return fmt.Errorf("unsupported subject_type %q", c.SubjectType)
+	case len(c.RedirectURIs) == 0 && c.AllowsGrantType("authorization_code"):
+		return errors.New("redirect_uris are required for the authorization_code grant")
+	}
+
+	for _, signing := range []struct{ name, alg string }{
+		{"id_token_signed_response_alg", c.IDTokenSignedResponseAlg},
+		{"access_token_signed_response_alg", c.AccessTokenSignedResponseAlg},
+		{"userinfo_signed_response_alg", c.UserinfoSignedResponseAlg},
+	} {
+		switch {
+		case signing.alg != "" && !tokenSigningAlgorithms[signing.alg]:
+			return fmt.Errorf("unsupported %s %q", signing.name, signing.alg)
+		case signing.alg == "HS256" && len(c.Secrets()) == 0:
+			return fmt.Errorf("%s HS256 needs a client_secret", signing.name)
+		}
+	}
+
+	for _, encryption := range []struct{ prefix, alg, enc string }{
+		{"id_token", c.IDTokenEncryptedResponseAlg, c.IDTokenEncryptedResponseEnc},
+		{"userinfo", c.UserinfoEncryptedResponseAlg, c.UserinfoEncryptedResponseEnc},
+	} {
+		switch {
+		case encryption.alg != "" && !keyManagementAlgorithms[encryption.alg]:
+			return fmt.Errorf("unsupported %s_encrypted_response_alg %q", encryption.prefix, encryption.alg)
+		case encryption.enc != "" && !contentEncryptionAlgorithms[encryption.enc]:
+			return fmt.Errorf("unsupported %s_encrypted_response_enc %q", encryption.prefix, encryption.enc)
+		case encryption.enc != "" && encryption.alg == "":
+			return fmt.Errorf("%s_encrypted_response_enc needs %s_encrypted_response_alg", encryption.prefix, encryption.prefix)
+		case encryption.alg != "" && !c.HasKeys():
+			return fmt.Errorf("%s_encrypted_response_alg needs a jwks or jwks_uri with an encryption key", encryption.prefix)
+		}
+	}
+
+	if c.JWKSURI != "" {
+		if u, err := url.Parse(c.JWKSURI); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("jwks_uri must be an absolute http or https URL")
+		}
+	}
+
+	if c.SectorIdentifierURI != "" {
+		if u, err := url.Parse(c.SectorIdentifierURI); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("sector_identifier_uri must be an absolute http or https URL")
+		}
+	}
+	if c.SubjectType == SubjectTypePairwise && c.SectorIdentifier() == "" {
+		return errors.New("pairwise clients need a sector_identifier_uri or redirect_uris on a single host")
+	}
+	if err := c.CustomClaims.Validate(); err != nil {
+		return fmt.Errorf("custom_claims: %w", err)
+	}
+
+	if len(c.JWKS) > 0 {
+		var keySet struct {
+			Keys []json.RawMessage `json:"keys"`
+		}
+		if err := json.Unmarshal(c.JWKS, &keySet); err != nil || len(keySet.Keys) == 0 {
+			return errors.New("jwks must be a JWK Set with at least one key")
+		}
+	}
+	return nil
+}
+
+// Clone creates a deep copy of the client
+func (c *Client) Clone() *Client {
+	if c == nil {
+		return nil
+	}
+
+	clone := *c
+	clone.ClientSecrets = append([]string(nil), c.ClientSecrets...)
+	clone.RedirectURIs = append([]string(nil), c.RedirectURIs...)
+	clone.AllowedScopes = append([]string(nil), c.AllowedScopes...)
+	clone.GrantTypes = append([]string(nil), c.GrantTypes...)
+	clone.JWKS = append(json.RawMessage(nil), c.JWKS...)
+	clone.CustomClaims = c.CustomClaims.Clone()
+	return &clone
+}
+
+// IsPublic reports whether the client is a public client, which cannot keep
+// a secret and identifies itself by client ID alone
+func (c *Client) IsPublic() bool {
+	if c.ClientType != "" {
+		return c.ClientType == ClientTypePublic
+	}
+	if c.TokenEndpointAuthMethod == "none" {
+		return true
+	}
+	return len(c.Secrets()) == 0 && !c.HasKeys()
+}
+
+// HasKeys reports whether the client registered keys for private_key_jwt
+func (c *Client) HasKeys() bool {
+	return len(c.JWKS) > 0 || c.JWKSURI != ""
+}
+
+// Secrets returns every secret the client may authenticate with
+func (c *Client) Secrets() []string {
+	var secrets []string
+	for _, s := range append([]string{c.ClientSecret}, c.ClientSecrets...) {
+		if s != "" {
+			secrets = append(secrets, s)
+		}
+	}
+	return secrets
+}
+
+// HasSecret reports whether secret is one of the client's secrets
+func (c *Client) HasSecret(secret string) bool {
+	if secret == "" {
+		return false
+	}
+	match := 0
+	for _, s := range c.Secrets() {
+		match |= subtle.ConstantTimeCompare([]byte(s), []byte(secret))
+	}
+	return match == 1
+}
+
+// AllowsAuthMethod reports whether the client may authenticate with the given
+// token endpoint authentication method
+func (c *Client) AllowsAuthMethod(method string) bool {
+	return c.TokenEndpointAuthMethod == "" || c.TokenEndpointAuthMethod == method
+}
+
+// AllowsScope reports whether every scope in the space-delimited scope string
+// may be granted to the client
+func (c *Client) AllowsScope(scope string) bool {
+	if len(c.AllowedScopes) == 0 {
+		return true
+	}
+
+	allowed := make(map[string]bool, len(c.AllowedScopes))
+	for _, s := range c.AllowedScopes {
+		allowed[s] = true
+	}
+	for _, s := range strings.Fields(scope) {
+		if !allowed[s] {
+			return false
+		}
+	}
+	return true
+}
+
+// AllowsGrantType reports whether the client may use the given grant type
+func (c *Client) AllowsGrantType(grantType string) bool {
+	if len(c.GrantTypes) == 0 {
+		return true
+	}
+	for _, g := range c.GrantTypes {
+		if g == grantType {
+			return true
+		}
+	}
+	return false
+}
+
+// SectorIdentifier returns the host that pairwise subjects are derived for: the
+// host of the sector identifier URI, else the host shared by all redirect URIs.
+// It is empty when neither names a single host.
+func (c *Client) SectorIdentifier() string {
+	if c.SectorIdentifierURI != "" {
+		u, err := url.Parse(c.SectorIdentifierURI)
+		if err != nil {
+			return ""
+		}
+		return u.Hostname()
+	}
+
+	var host string
+	for _, redirectURI := range c.RedirectURIs {
+		u, err := url.Parse(redirectURI)
+		if err != nil || u.Hostname() == "" || (host != "" && u.Hostname() != host) {
+			return ""
+		}
+		host = u.Hostname()
+	}
+	return host
+}
+
+// Subject returns the sub the client sees for a user's local subject. Public
+// clients, and unregistered (nil) clients, see the local subject. Pairwise
+// clients see a hash of their sector, the local subject and the server's salt
+// (OIDC Core section 8.1), which is the same for every client of the sector.
+func (c *Client) Subject(localSubject, salt string) string {
+	if c == nil || c.SubjectType != SubjectTypePairwise {
+		return localSubject
+	}
+	sum := sha256.Sum256([]byte(c.SectorIdentifier() + "|" + localSubject + "|" + salt))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AllowsRedirectURI reports whether a redirect URI is registered for the client.
+// URIs must match exactly, except that the port of a loopback URI may vary
+// (RFC 8252 section 7.3) so that native apps can listen on any free port. A
+// client without redirect URIs allows none.
+func (c *Client) AllowsRedirectURI(redirectURI string) bool {
+	for _, registered := range c.RedirectURIs {
+		if registered == redirectURI || loopbackMatch(registered, redirectURI) {
+			return true
+		}
+	}
+	return false
+}
+
+// loopbackMatch reports whether two http loopback URIs differ only in their port
+func loopbackMatch(registered, requested string) bool {
+	registeredURL, err := url.Parse(registered)
+	if err != nil {
+		return false
+	}
+	requestedURL, err := url.Parse(requested)
+	if err != nil {
+		return false
+	}
+
+	if registeredURL.Scheme != "http" || requestedURL.Scheme != "http" || !isLoopbackHost(registeredURL.Hostname()) {
+		return false
+	}
+	return registeredURL.Hostname() == requestedURL.Hostname() &&
+		registeredURL.Path == requestedURL.Path &&
+		registeredURL.RawQuery == requestedURL.RawQuery
+}
+
+// isLoopbackHost reports whether host names the loopback interface
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
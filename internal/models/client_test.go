@@ -0,0 +1,220 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClientAllowsScope(t *testing.T) {
+	restricted := &Client{ClientID: "restricted", AllowedScopes: []string{"read", "write"}}
+	unrestricted := &Client{ClientID: "unrestricted"}
+
+	testCases := []struct {
+		name     string
+		client   *Client
+		scope    string
+		expected bool
+	}{
+		{"Single allowed scope", restricted, "read", true},
+		{"All allowed scopes", restricted, "read write", true},
+		{"Empty scope", restricted, "", true},
+		{"Disallowed scope", restricted, "read admin", false},
+		{"Unrestricted client", unrestricted, "anything at all", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if result := tc.client.AllowsScope(tc.scope); result != tc.expected {
+				t.Errorf("AllowsScope(%q) = %v; want %v", tc.scope, result, tc.expected)
+			}
+		})
+	}
+}
+
+func TestClientAllowsRedirectURI(t *testing.T) {
+	client := &Client{
+		ClientID: "native-app",
+		RedirectURIs: []string{
+			"https://app.example.com/callback",
+			"http://127.0.0.1/callback",
+			"http://localhost:8080/oauth",
+		},
+	}
+
+	testCases := []struct {
+		name        string
+		redirectURI string
+		expected    bool
+	}{
+		{"Exact match", "https://app.example.com/callback", true},
+		{"Different path", "https://app.example.com/other", false},
+		{"Trailing slash", "https://app.example.com/callback/", false},
+		{"Loopback IP with any port", "http://127.0.0.1:51234/callback", true},
+		{"Loopback name with other port", "http://localhost:9999/oauth", true},
+		{"Loopback with different path", "http://127.0.0.1:51234/other", false},
+		{"Loopback host must match", "http://localhost:51234/callback", false},
+		{"Non-loopback port change", "https://app.example.com:8443/callback", false},
+		{"Loopback over https", "https://127.0.0.1:51234/callback", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if result := client.AllowsRedirectURI(tc.redirectURI); result != tc.expected {
+				t.Errorf("AllowsRedirectURI(%q) = %v; want %v", tc.redirectURI, result, tc.expected)
+			}
+		})
+	}
+
+	if (&Client{ClientID: "service"}).AllowsRedirectURI("https://anything.example.com/") {
+		t.Errorf("expected a client without redirect URIs to allow no redirect URI")
+	}
+}
+
+func TestClientSecretsAndType(t *testing.T) {
+	testCases := []struct {
+		name           string
+		client         *Client
+		secret         string
+		expectedSecret bool
+		expectedPublic bool
+	}{
+		{"Primary secret", &Client{ClientSecret: "s1"}, "s1", true, false},
+		{"Rotated secret", &Client{ClientSecret: "s1", ClientSecrets: []string{"s2"}}, "s2", true, false},
+		{"Wrong secret", &Client{ClientSecret: "s1"}, "s3", false, false},
+		{"Empty secret never matches", &Client{}, "", false, true},
+		{"Explicit public type with secret", &Client{ClientSecret: "s1", ClientType: ClientTypePublic}, "s1", true, true},
+		{"Explicit confidential type without secret", &Client{ClientType: ClientTypeConfidential}, "", false, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if result := tc.client.HasSecret(tc.secret); result != tc.expectedSecret {
+				t.Errorf("HasSecret(%q) = %v; want %v", tc.secret, result, tc.expectedSecret)
+			}
+			if result := tc.client.IsPublic(); result != tc.expectedPublic {
+				t.Errorf("IsPublic() = %v; want %v", result, tc.expectedPublic)
+			}
+		})
+	}
+}
+
+func TestClientAllowsGrantType(t *testing.T) {
+	client := &Client{GrantTypes: []string{"authorization_code", "refresh_token"}}
+	if !client.AllowsGrantType("refresh_token") {
+		t.Errorf("expected refresh_token to be allowed")
+	}
+	if client.AllowsGrantType("client_credentials") {
+		t.Errorf("expected client_credentials to be rejected")
+	}
+	if !(&Client{}).AllowsGrantType("client_credentials") {
+		t.Errorf("expected a client without grant types to allow any grant type")
+	}
+}
+
+func TestClientClone(t *testing.T) {
+	original := &Client{
+		ClientID:      "web-app",
+		ClientSecrets: []string{"old-secret"},
+		RedirectURIs:  []string{"http://localhost/callback"},
+		AllowedScopes: []string{"openid"},
+		GrantTypes:    []string{"authorization_code"},
+		JWKS:          []byte(`{"keys":[]}`),
+		CustomClaims:  &CustomClaims{IDToken: map[string]interface{}{"tenant_id": "t1"}},
+	}
+
+	clone := original.Clone()
+	if !reflect.DeepEqual(original, clone) {
+		t.Fatalf("Clone() = %+v; want %+v", clone, original)
+	}
+
+	clone.ClientSecrets[0] = "changed"
+	clone.RedirectURIs[0] = "changed"
+	clone.AllowedScopes[0] = "changed"
+	clone.GrantTypes[0] = "changed"
+	clone.JWKS[0] = '['
+	clone.CustomClaims.IDToken["tenant_id"] = "t2"
+	if original.ClientSecrets[0] != "old-secret" || original.RedirectURIs[0] != "http://localhost/callback" ||
+		original.AllowedScopes[0] != "openid" || original.GrantTypes[0] != "authorization_code" ||
+		string(original.JWKS) != `{"keys":[]}` || original.CustomClaims.IDToken["tenant_id"] != "t1" {
+		t.Errorf("Clone is not a deep copy - original was modified: %+v", original)
+	}
+
+	var nilClient *Client
+	if nilClient.Clone() != nil {
+		t.Error("Cloning nil should return nil")
+	}
+}
+
+func TestClientValidate(t *testing.T) {
+	callback := []string{"https://app.example.com/callback"}
+	testCases := []struct {
+		name   string
+		client *Client
+		valid  bool
+	}{
+		{"Public client", &Client{ClientID: "app", RedirectURIs: callback}, true},
+		{"Authorization code client without redirect URIs", &Client{ClientID: "app"}, false},
+		{"Explicit authorization code grant without redirect URIs", &Client{ClientID: "app", GrantTypes: []string{"refresh_token", "authorization_code"}}, false},
+		{"Service client without redirect URIs", &Client{ClientID: "app", ClientSecret: "s1", GrantTypes: []string{"client_credentials"}}, true},
+		{"Missing client_id", &Client{ClientSecret: "s1"}, false},
+		{"Unknown client type", &Client{ClientID: "app", ClientType: "trusted"}, false},
+		{"Confidential client without credentials", &Client{ClientID: "app", ClientType: ClientTypeConfidential}, false},
+		{"Confidential client with only keys", &Client{ClientID: "app", ClientType: ClientTypeConfidential, RedirectURIs: callback, JWKS: []byte(`{"keys":[{"kty":"EC"}]}`)}, true},
+		{"Unknown auth method", &Client{ClientID: "app", TokenEndpointAuthMethod: "tls_client_auth"}, false},
+		{"private_key_jwt without keys", &Client{ClientID: "app", ClientSecret: "s1", TokenEndpointAuthMethod: "private_key_jwt"}, false},
+		{"Empty key set", &Client{ClientID: "app", JWKS: []byte(`{"keys":[]}`)}, false},
+		{"Confidential client with auth method none", &Client{ClientID: "app", ClientSecret: "s1", ClientType: ClientTypeConfidential, TokenEndpointAuthMethod: "none"}, false},
+		{"ES256 ID tokens", &Client{ClientID: "app", RedirectURIs: callback, IDTokenSignedResponseAlg: "ES256"}, true},
+		{"Unknown ID token signing alg", &Client{ClientID: "app", IDTokenSignedResponseAlg: "none"}, false},
+		{"HS256 access tokens with a secret", &Client{ClientID: "app", ClientSecret: "s1", RedirectURIs: callback, AccessTokenSignedResponseAlg: "HS256"}, true},
+		{"HS256 ID tokens without a secret", &Client{ClientID: "app", IDTokenSignedResponseAlg: "HS256"}, false},
+		{"Signed userinfo", &Client{ClientID: "app", RedirectURIs: callback, UserinfoSignedResponseAlg: "PS256"}, true},
+		{"Unknown userinfo signing alg", &Client{ClientID: "app", UserinfoSignedResponseAlg: "none"}, false},
+		{"Encrypted ID tokens", &Client{ClientID: "app", RedirectURIs: callback, JWKS: []byte(`{"keys":[{"kty":"RSA"}]}`), IDTokenEncryptedResponseAlg: "RSA-OAEP-256", IDTokenEncryptedResponseEnc: "A256GCM"}, true},
+		{"Encrypted userinfo without keys", &Client{ClientID: "app", UserinfoEncryptedResponseAlg: "ECDH-ES"}, false},
+		{"Unknown encryption alg", &Client{ClientID: "app", JWKS: []byte(`{"keys":[{"kty":"RSA"}]}`), IDTokenEncryptedResponseAlg: "RSA1_5"}, false},
+		{"Encryption enc without alg", &Client{ClientID: "app", JWKS: []byte(`{"keys":[{"kty":"RSA"}]}`), UserinfoEncryptedResponseEnc: "A256GCM"}, false},
+		{"Pairwise client", &Client{ClientID: "app", RedirectURIs: []string{"https://app.example.com/a", "https://app.example.com/b"}, SubjectType: SubjectTypePairwise}, true},
+		{"Pairwise client across hosts", &Client{ClientID: "app", RedirectURIs: []string{"https://a.example.com/cb", "https://b.example.com/cb"}, SubjectType: SubjectTypePairwise}, false},
+		{"Pairwise client with a sector identifier", &Client{ClientID: "app", RedirectURIs: []string{"https://a.example.com/cb", "https://b.example.com/cb"}, SubjectType: SubjectTypePairwise, SectorIdentifierURI: "https://example.com/sector.json"}, true},
+		{"Relative sector identifier", &Client{ClientID: "app", SectorIdentifierURI: "/sector.json"}, false},
+		{"Unknown subject type", &Client{ClientID: "app", SubjectType: "ephemeral"}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.client.Validate(); (err == nil) != tc.valid {
+				t.Errorf("Validate() = %v; want valid %v", err, tc.valid)
+			}
+		})
+	}
+
+	if (&Client{ClientID: "app", JWKS: []byte(`{"keys":[{"kty":"EC"}]}`)}).IsPublic() {
+		t.Errorf("expected a client with keys to be confidential")
+	}
+}
+
+func TestClientSubject(t *testing.T) {
+	shop := &Client{ClientID: "shop", RedirectURIs: []string{"https://shop.example.com/cb"}, SubjectType: SubjectTypePairwise}
+	shopAdmin := &Client{ClientID: "shop-admin", RedirectURIs: []string{"https://admin.example.com/cb"}, SubjectType: SubjectTypePairwise, SectorIdentifierURI: "https://shop.example.com/sector.json"}
+	blog := &Client{ClientID: "blog", RedirectURIs: []string{"https://blog.example.com/cb"}, SubjectType: SubjectTypePairwise}
+
+	var unregistered *Client
+	if sub := unregistered.Subject("alice", ""); sub != "alice" {
+		t.Errorf("expected unregistered clients to see the local subject, got %q", sub)
+	}
+	if sub := (&Client{ClientID: "public"}).Subject("alice", ""); sub != "alice" {
+		t.Errorf("expected public clients to see the local subject, got %q", sub)
+	}
+
+	sub := shop.Subject("alice", "")
+	if sub == "alice" || sub != shop.Subject("alice", "") {
+		t.Errorf("expected a stable pairwise subject, got %q", sub)
+	}
+	if shopAdmin.Subject("alice", "") != sub {
+		t.Errorf("expected clients of one sector to share subjects")
+	}
+	if blog.Subject("alice", "") == sub || shop.Subject("bob", "") == sub || shop.Subject("alice", "pepper") == sub {
+		t.Errorf("expected the sector, user and salt to change the subject")
+	}
+}
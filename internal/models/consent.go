@@ -0,0 +1,29 @@
+package models
+
+import "strings"
+
+// Consent is a pre-set consent decision for a client. A consent without a
+// UserID applies to every user of the client that has no consent of their own.
+type Consent struct {
+	ClientID string `json:"client_id"`
+	UserID   string `json:"user_id,omitempty"`
+	// Scopes lists the scopes the user grants. Requested scopes missing here are withheld.
+	Scopes []string `json:"scopes"`
+}
+
+// Grant returns the requested scopes that the consent allows, keeping the
+// order in which they were requested
+func (c *Consent) Grant(requested string) string {
+	allowed := make(map[string]bool, len(c.Scopes))
+	for _, s := range c.Scopes {
+		allowed[s] = true
+	}
+
+	granted := make([]string, 0, len(c.Scopes))
+	for _, s := range strings.Fields(requested) {
+		if allowed[s] {
+			granted = append(granted, s)
+		}
+	}
+	return strings.Join(granted, " ")
+}
@@ -0,0 +1,26 @@
+package models
+
+import "testing"
+
+func TestConsentGrant(t *testing.T) {
+	tests := []struct {
+		name      string
+		scopes    []string
+		requested string
+		expected  string
+	}{
+		{"All scopes granted", []string{"openid", "email", "profile"}, "openid email", "openid email"},
+		{"Partial grant keeps request order", []string{"email", "openid"}, "openid profile email", "openid email"},
+		{"Nothing granted", []string{"calendar"}, "openid email", ""},
+		{"Empty consent", nil, "openid", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			consent := &Consent{ClientID: "test-client", Scopes: tt.scopes}
+			if got := consent.Grant(tt.requested); got != tt.expected {
+				t.Errorf("Grant(%q) = %q, want %q", tt.requested, got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// Device authorization states
+const (
+	DeviceStatusPending  = "pending"
+	DeviceStatusApproved = "approved"
+	DeviceStatusDenied   = "denied"
+	DeviceStatusRedeemed = "redeemed"
+)
+
+// DeviceAuthorization represents an RFC 8628 device authorization request
+type DeviceAuthorization struct {
+	DeviceCode   string
+	UserCode     string
+	ClientID     string
+	UserID       string // Registered user who approved the device, if any
+	Scope        string
+	Status       string
+	ExpiresAt    time.Time
+	Interval     time.Duration // Minimum time between token requests
+	LastPolledAt time.Time
+	AuthTime     time.Time // When the user approved the device
+}
+
+// Expired reports whether the device code is past its expiry time
+func (d *DeviceAuthorization) Expired() bool {
+	return d.ExpiresAt.Before(time.Now())
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// AllApplications is the AppRoles key of roles assigned in every application
+const AllApplications = "*"
+
+// groupsSource names the groups claim source of an overage pointer
+const groupsSource = "src1"
+
+// ValidateMemberships checks that the user's groups and app roles are named
+func (u *UserInfo) ValidateMemberships() error {
+	for _, group := range u.Groups {
+		if group == "" {
+			return errors.New("groups cannot contain an empty group")
+		}
+	}
+	for clientID, roles := range u.AppRoles {
+		if clientID == "" {
+			return errors.New("app_roles cannot have an empty client ID")
+		}
+		for _, role := range roles {
+			if role == "" {
+				return fmt.Errorf("app_roles of %q cannot contain an empty role", clientID)
+			}
+		}
+	}
+	return nil
+}
+
+// Roles returns the roles the user is assigned in the client's application,
+// including the roles assigned in every application, without duplicates
+func (u *UserInfo) Roles(clientID string) []string {
+	var roles []string
+	seen := make(map[string]bool)
+	for _, key := range []string{AllApplications, clientID} {
+		for _, role := range u.AppRoles[key] {
+			if !seen[role] {
+				seen[role] = true
+				roles = append(roles, role)
+			}
+		}
+	}
+	return roles
+}
+
+// ClaimsFor returns the user's claims as they appear in the tokens and
+// userinfo responses of a client: Claims plus the roles claim
+func (u *UserInfo) ClaimsFor(clientID string) map[string]interface{} {
+	claims := u.Claims()
+	if roles := u.Roles(clientID); len(roles) > 0 {
+		values := make([]interface{}, len(roles))
+		for i, role := range roles {
+			values[i] = role
+		}
+		claims["roles"] = values
+	}
+	return claims
+}
+
+// ApplyGroupsOverage replaces a groups claim with more than threshold groups
+// by a pointer to the endpoint that lists them, as Entra ID does for large
+// tenants. The pointer is an OIDC distributed claim (OIDC Core section 5.6.2):
+// _claim_names maps groups to a source in _claim_sources holding the endpoint.
+// A threshold of zero disables the overage. It reports whether the groups
+// claim was replaced.
+func ApplyGroupsOverage(claims map[string]interface{}, threshold int, endpoint string) bool {
+	if threshold <= 0 {
+		return false
+	}
+
+	count := 0
+	switch groups := claims["groups"].(type) {
+	case []interface{}:
+		count = len(groups)
+	case []string:
+		count = len(groups)
+	}
+	if count <= threshold {
+		return false
+	}
+
+	delete(claims, "groups")
+	claims["_claim_names"] = map[string]interface{}{"groups": groupsSource}
+	claims["_claim_sources"] = map[string]interface{}{
+		groupsSource: map[string]interface{}{"endpoint": endpoint},
+	}
+	return true
+}
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUserInfoRoles(t *testing.T) {
+	user := &UserInfo{
+		Sub:    "alice",
+		Groups: []string{"g1"},
+		AppRoles: map[string][]string{
+			AllApplications: {"User"},
+			"client-1":      {"Admin", "User"},
+		},
+	}
+
+	if roles := user.Roles("client-1"); !reflect.DeepEqual(roles, []string{"User", "Admin"}) {
+		t.Errorf("Roles(client-1) = %v", roles)
+	}
+	if roles := user.Roles("client-2"); !reflect.DeepEqual(roles, []string{"User"}) {
+		t.Errorf("Roles(client-2) = %v", roles)
+	}
+
+	claims := user.ClaimsFor("client-1")
+	if !reflect.DeepEqual(claims["roles"], []interface{}{"User", "Admin"}) || !reflect.DeepEqual(claims["groups"], []interface{}{"g1"}) {
+		t.Errorf("unexpected membership claims %v", claims)
+	}
+	if _, exists := claims["app_roles"]; exists {
+		t.Error("app_roles should not be a claim")
+	}
+	if _, exists := (&UserInfo{Sub: "bob"}).ClaimsFor("client-1")["roles"]; exists {
+		t.Error("users without roles should not have a roles claim")
+	}
+}
+
+func TestUserInfoValidateMemberships(t *testing.T) {
+	testCases := []struct {
+		name    string
+		user    UserInfo
+		wantErr bool
+	}{
+		{"no memberships", UserInfo{}, false},
+		{"valid", UserInfo{Groups: []string{"g1"}, AppRoles: map[string][]string{"*": {"User"}}}, false},
+		{"empty group", UserInfo{Groups: []string{""}}, true},
+		{"empty client ID", UserInfo{AppRoles: map[string][]string{"": {"User"}}}, true},
+		{"empty role", UserInfo{AppRoles: map[string][]string{"client-1": {""}}}, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.user.ValidateMemberships(); (err != nil) != tc.wantErr {
+				t.Errorf("ValidateMemberships() error = %v; wantErr %t", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestApplyGroupsOverage(t *testing.T) {
+	endpoint := "http://localhost:8080/groups"
+
+	testCases := []struct {
+		name      string
+		groups    interface{}
+		threshold int
+		want      bool
+	}{
+		{"disabled", []interface{}{"g1", "g2", "g3"}, 0, false},
+		{"at threshold", []interface{}{"g1", "g2"}, 2, false},
+		{"over threshold", []interface{}{"g1", "g2", "g3"}, 2, true},
+		{"string slice over threshold", []string{"g1", "g2", "g3"}, 2, true},
+		{"no groups", nil, 2, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			claims := map[string]interface{}{"sub": "alice"}
+			if tc.groups != nil {
+				claims["groups"] = tc.groups
+			}
+
+			if got := ApplyGroupsOverage(claims, tc.threshold, endpoint); got != tc.want {
+				t.Fatalf("ApplyGroupsOverage() = %t; want %t", got, tc.want)
+			}
+			if !tc.want {
+				if _, exists := claims["_claim_names"]; exists {
+					t.Errorf("unexpected overage pointer in %v", claims)
+				}
+				return
+			}
+			if _, exists := claims["groups"]; exists {
+				t.Error("expected the groups claim to be removed")
+			}
+			names := claims["_claim_names"].(map[string]interface{})
+			sources := claims["_claim_sources"].(map[string]interface{})
+			source, ok := sources[names["groups"].(string)].(map[string]interface{})
+			if !ok || source["endpoint"] != endpoint {
+				t.Errorf("unexpected overage pointer %v %v", names, sources)
+			}
+		})
+	}
+}
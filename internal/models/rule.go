@@ -0,0 +1,97 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/expr"
+)
+
+// Targets a claim rule can compute claims for
+const (
+	RuleTargetIDToken     = "id_token"
+	RuleTargetAccessToken = "access_token"
+	RuleTargetUserInfo    = "userinfo"
+)
+
+// RuleVariables are the variables claim rule expressions can refer to: the
+// user's claims, the client, the granted scopes and the request
+var RuleVariables = []string{"user", "client", "scopes", "request"}
+
+// ClaimRule computes a claim from an expression over the user, client, scopes
+// and request, e.g. roles from group membership and the email domain
+type ClaimRule struct {
+	// Name identifies the rule. Rules are applied in name order, so a later
+	// rule replaces a claim computed by an earlier one.
+	Name string `json:"name"`
+	// Claim is the claim the expression's value is set as. A null value
+	// leaves the claim unset.
+	Claim string `json:"claim"`
+	// Expression is written in the expression language of the expr package
+	Expression string `json:"expression"`
+	// Targets lists the tokens and responses the claim is added to: id_token,
+	// access_token and userinfo. Empty means all of them.
+	Targets []string `json:"targets,omitempty"`
+
+	// program is the compiled expression, kept by Validate for Evaluate
+	program *expr.Program
+}
+
+// Validate checks that the rule is complete and that its expression compiles.
+// The compiled expression is kept on the rule, and its copies, for Evaluate.
+func (r *ClaimRule) Validate() error {
+	switch {
+	case r.Name == "":
+		return errors.New("name is required")
+	case r.Claim == "":
+		return errors.New("claim is required")
+	case reservedClaims[r.Claim]:
+		return fmt.Errorf("claim %q is set by the server and cannot be computed", r.Claim)
+	case r.Expression == "":
+		return errors.New("expression is required")
+	}
+	for _, target := range r.Targets {
+		if target != RuleTargetIDToken && target != RuleTargetAccessToken && target != RuleTargetUserInfo {
+			return fmt.Errorf("unsupported target %q", target)
+		}
+	}
+	program, err := expr.Compile(r.Expression, RuleVariables...)
+	if err != nil {
+		return fmt.Errorf("invalid expression: %w", err)
+	}
+	r.program = program
+	return nil
+}
+
+// Clone returns a copy of the rule
+func (r *ClaimRule) Clone() *ClaimRule {
+	clone := *r
+	clone.Targets = append([]string(nil), r.Targets...)
+	return &clone
+}
+
+// Applies reports whether the rule computes a claim for the target
+func (r *ClaimRule) Applies(target string) bool {
+	if len(r.Targets) == 0 {
+		return true
+	}
+	for _, t := range r.Targets {
+		if t == target {
+			return true
+		}
+	}
+	return false
+}
+
+// Evaluate computes the rule's claim value from the RuleVariables. Rules that
+// were not validated, or whose expression changed since, are compiled first.
+func (r *ClaimRule) Evaluate(variables map[string]interface{}) (interface{}, error) {
+	program := r.program
+	if program == nil || program.String() != r.Expression {
+		var err error
+		if program, err = expr.Compile(r.Expression, RuleVariables...); err != nil {
+			return nil, err
+		}
+	}
+	return program.Eval(variables)
+}
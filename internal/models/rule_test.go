@@ -0,0 +1,75 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClaimRuleValidate(t *testing.T) {
+	testCases := []struct {
+		name  string
+		rule  *ClaimRule
+		valid bool
+	}{
+		{"Valid rule", &ClaimRule{Name: "roles", Claim: "roles", Expression: `"admins" in user.groups ? ["admin"] : []`}, true},
+		{"Valid targets", &ClaimRule{Name: "tier", Claim: "tier", Expression: `"gold"`, Targets: []string{RuleTargetAccessToken, RuleTargetUserInfo}}, true},
+		{"Missing name", &ClaimRule{Claim: "roles", Expression: `[]`}, false},
+		{"Missing claim", &ClaimRule{Name: "roles", Expression: `[]`}, false},
+		{"Missing expression", &ClaimRule{Name: "roles", Claim: "roles"}, false},
+		{"Reserved claim", &ClaimRule{Name: "sub", Claim: "sub", Expression: `user.email`}, false},
+		{"Unknown target", &ClaimRule{Name: "roles", Claim: "roles", Expression: `[]`, Targets: []string{"refresh_token"}}, false},
+		{"Syntax error", &ClaimRule{Name: "roles", Claim: "roles", Expression: `user.groups.exists(g, `}, false},
+		{"Undeclared variable", &ClaimRule{Name: "roles", Claim: "roles", Expression: `session.roles`}, false},
+		{"Unknown function", &ClaimRule{Name: "roles", Claim: "roles", Expression: `user.email.domain()`}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.rule.Validate(); (err == nil) != tc.valid {
+				t.Errorf("Validate() = %v; want valid %v", err, tc.valid)
+			}
+		})
+	}
+}
+
+func TestClaimRuleEvaluate(t *testing.T) {
+	rule := &ClaimRule{
+		Name:       "roles",
+		Claim:      "roles",
+		Expression: `user.email.endsWith("@corp.example.com") ? ["employee"] + scopes.filter(s, s.startsWith("app.")) : ["guest"]`,
+		Targets:    []string{RuleTargetIDToken},
+	}
+	value, err := rule.Evaluate(map[string]interface{}{
+		"user":   map[string]interface{}{"email": "alice@corp.example.com"},
+		"scopes": []interface{}{"openid", "app.read"},
+	})
+	if err != nil {
+		t.Fatalf("Evaluate() error: %v", err)
+	}
+	if want := []interface{}{"employee", "app.read"}; !reflect.DeepEqual(value, want) {
+		t.Errorf("Evaluate() = %v; want %v", value, want)
+	}
+
+	// The expression compiled by Validate is kept by copies of the rule
+	if err := rule.Validate(); err != nil {
+		t.Fatalf("Validate() error: %v", err)
+	}
+	clone := rule.Clone()
+	if clone.program == nil || clone.program != rule.program {
+		t.Errorf("expected the clone to share the compiled expression")
+	}
+	if value, err := clone.Evaluate(map[string]interface{}{"user": map[string]interface{}{"email": "bob@example.com"}}); err != nil || !reflect.DeepEqual(value, []interface{}{"guest"}) {
+		t.Errorf("Evaluate() = %v, %v; want [guest]", value, err)
+	}
+	clone.Expression = `"changed"`
+	if value, err := clone.Evaluate(nil); err != nil || value != "changed" {
+		t.Errorf("expected a changed expression to be recompiled, got %v, %v", value, err)
+	}
+
+	if !rule.Applies(RuleTargetIDToken) || rule.Applies(RuleTargetUserInfo) {
+		t.Errorf("expected the rule to apply to ID tokens only")
+	}
+	if !(&ClaimRule{}).Applies(RuleTargetAccessToken) {
+		t.Errorf("expected a rule without targets to apply everywhere")
+	}
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// standardScopeClaims maps the OpenID Connect scopes to the claims they
+// release (OIDC Core section 5.4)
+var standardScopeClaims = map[string][]string{
+	"profile": {
+		"name", "family_name", "given_name", "middle_name", "nickname", "preferred_username",
+		"profile", "picture", "website", "gender", "birthdate", "zoneinfo", "locale", "updated_at",
+	},
+	"email":   {"email", "email_verified"},
+	"address": {"address"},
+	"phone":   {"phone_number", "phone_number_verified"},
+}
+
+// Scope is a custom scope defined by an admin, releasing the listed claims
+// in ID tokens and userinfo responses when it is granted
+type Scope struct {
+	Name   string   `json:"name"`
+	Claims []string `json:"claims"`
+}
+
+// Validate checks that the scope is well formed and does not redefine a standard scope
+func (s *Scope) Validate() error {
+	switch {
+	case s.Name == "":
+		return errors.New("missing name")
+	case strings.ContainsAny(s.Name, " \t\r\n\"\\"):
+		return fmt.Errorf("invalid scope name %q", s.Name)
+	case s.Name == "openid" || standardScopeClaims[s.Name] != nil:
+		return fmt.Errorf("%s is a standard scope and cannot be redefined", s.Name)
+	case len(s.Claims) == 0:
+		return errors.New("missing claims")
+	}
+	for _, claim := range s.Claims {
+		if claim == "" || claim == "sub" {
+			return fmt.Errorf("invalid claim %q", claim)
+		}
+	}
+	return nil
+}
+
+// FilterClaims returns the claims that the granted scope releases. Claims that
+// the standard scopes or a custom scope map are only kept when one of their
+// scopes is granted. Claims no scope maps, such as sub, are always kept.
+func FilterClaims(claims map[string]interface{}, scope string, custom []*Scope) map[string]interface{} {
+	mappings := make(map[string][]string, len(standardScopeClaims)+len(custom))
+	for name, scopeClaims := range standardScopeClaims {
+		mappings[name] = scopeClaims
+	}
+	for _, s := range custom {
+		mappings[s.Name] = s.Claims
+	}
+
+	mapped := make(map[string]bool)
+	for _, scopeClaims := range mappings {
+		for _, claim := range scopeClaims {
+			mapped[claim] = true
+		}
+	}
+	released := make(map[string]bool)
+	for _, granted := range strings.Fields(scope) {
+		for _, claim := range mappings[granted] {
+			released[claim] = true
+		}
+	}
+
+	filtered := make(map[string]interface{}, len(claims))
+	for name, value := range claims {
+		if !mapped[name] || released[name] {
+			filtered[name] = value
+		}
+	}
+	return filtered
+}
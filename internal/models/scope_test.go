@@ -0,0 +1,64 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFilterClaims(t *testing.T) {
+	claims := map[string]interface{}{
+		"sub":          "alice",
+		"name":         "Alice",
+		"email":        "alice@example.com",
+		"phone_number": "+15551234567",
+		"groups":       []string{"admins"},
+		"department":   "R&D",
+	}
+	custom := []*Scope{{Name: "groups", Claims: []string{"groups"}}}
+
+	tests := []struct {
+		scope string
+		want  []string
+	}{
+		{"openid", []string{"sub", "department"}},
+		{"openid profile email", []string{"sub", "department", "name", "email"}},
+		{"openid phone groups", []string{"sub", "department", "phone_number", "groups"}},
+		{"", []string{"sub", "department"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.scope, func(t *testing.T) {
+			filtered := FilterClaims(claims, tt.scope, custom)
+			want := make(map[string]interface{})
+			for _, claim := range tt.want {
+				want[claim] = claims[claim]
+			}
+			if !reflect.DeepEqual(filtered, want) {
+				t.Errorf("FilterClaims(%q) = %v; want %v", tt.scope, filtered, want)
+			}
+		})
+	}
+}
+
+func TestScopeValidate(t *testing.T) {
+	testCases := []struct {
+		name  string
+		scope *Scope
+		valid bool
+	}{
+		{"Custom scope", &Scope{Name: "groups", Claims: []string{"groups"}}, true},
+		{"Missing name", &Scope{Claims: []string{"groups"}}, false},
+		{"Name with a space", &Scope{Name: "my groups", Claims: []string{"groups"}}, false},
+		{"Standard scope", &Scope{Name: "email", Claims: []string{"groups"}}, false},
+		{"openid", &Scope{Name: "openid", Claims: []string{"groups"}}, false},
+		{"Missing claims", &Scope{Name: "groups"}, false},
+		{"Releasing sub", &Scope{Name: "groups", Claims: []string{"sub"}}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.scope.Validate(); (err == nil) != tc.valid {
+				t.Errorf("Validate() = %v; want valid %v", err, tc.valid)
+			}
+		})
+	}
+}
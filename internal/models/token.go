@@ -7,8 +7,9 @@ type TokenResponse struct {
 	AccessToken  string `json:"access_token"`
 	TokenType    string `json:"token_type"`
 	ExpiresIn    int    `json:"expires_in"`
-	RefreshToken string `json:"refresh_token"`
-	IDToken      string `json:"id_token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
 }
 
 // AuthRequest represents an authorization request
@@ -17,5 +18,52 @@ type AuthRequest struct {
 	RedirectURI string
 	Scope       string
 	Expiration  time.Time
+	// UserID is the sub of the registered user who authorized the request.
+	// Empty means the identity is derived from the client ID.
+	UserID string
+	// PKCE (RFC 7636) challenge sent by the client, verified at the token endpoint
+	CodeChallenge       string
+	CodeChallengeMethod string
+	// Nonce is echoed in the ID token so the client can detect replays
+	Nonce string
+	// AuthTime is when the user authenticated, reported as the auth_time claim
+	AuthTime time.Time
+	// Claims holds the individual claims requested with the claims parameter
+	Claims *ClaimsRequest
+	// Used is set once the code has been redeemed; FamilyID then names the
+	// token family issued from it, which is revoked if the code is replayed
+	Used     bool
+	FamilyID string
 	// Other fields as needed...
 }
+
+// Expired reports whether the authorization code is past its expiry time.
+// A zero Expiration never expires.
+func (a *AuthRequest) Expired() bool {
+	return !a.Expiration.IsZero() && a.Expiration.Before(time.Now())
+}
+
+// TokenRecord holds the server-side state of an issued access or refresh token
+type TokenRecord struct {
+	Token    string
+	ClientID string
+	UserID   string // Registered user the token is bound to, if any
+	Subject  string
+	Scope    string
+	// GrantType is the grant the token was issued by, empty if unknown
+	GrantType string
+	// FamilyID groups every token descended from the same authorization grant
+	FamilyID  string
+	IssuedAt  time.Time
+	AuthTime  time.Time // When the user authenticated for the grant
+	ExpiresAt time.Time // Zero means the token never expires
+	// Claims holds the individual claims requested for the grant, if any
+	Claims *ClaimsRequest
+	// Used is set once a refresh token has been rotated out
+	Used bool
+}
+
+// Expired reports whether the token is past its expiry time
+func (t *TokenRecord) Expired() bool {
+	return !t.ExpiresAt.IsZero() && t.ExpiresAt.Before(time.Now())
+}
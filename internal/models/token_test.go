@@ -154,7 +154,7 @@ func TestTokenResponseDefaults(t *testing.T) {
 	jsonStr := string(jsonData)
 
 	// Check JSON structure
-	expected := `{"access_token":"","token_type":"","expires_in":0,"refresh_token":"","id_token":""}`
+	expected := `{"access_token":"","token_type":"","expires_in":0}`
 	if jsonStr != expected {
 		t.Errorf("Empty TokenResponse JSON not as expected. Got: %s, Want: %s", jsonStr, expected)
 	}
@@ -212,3 +212,24 @@ func TestTokenResponseUnmarshalFromJSON(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthRequestExpired(t *testing.T) {
+	testCases := []struct {
+		name       string
+		expiration time.Time
+		expected   bool
+	}{
+		{"No expiration", time.Time{}, false},
+		{"Future expiration", time.Now().Add(time.Minute), false},
+		{"Past expiration", time.Now().Add(-time.Minute), true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			authReq := AuthRequest{Expiration: tc.expiration}
+			if authReq.Expired() != tc.expected {
+				t.Errorf("Expired() = %t, want %t", authReq.Expired(), tc.expected)
+			}
+		})
+	}
+}
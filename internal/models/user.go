@@ -1,5 +1,7 @@
 package models
 
+import "encoding/json"
+
 // UserInfo represents a user profile according to OpenID Connect standards
 // as returned by Google's OAuth2 userinfo endpoint
 type UserInfo struct {
@@ -14,8 +16,33 @@ type UserInfo struct {
 	Picture       string `json:"picture"`        // URL to profile picture
 
 	// Optional additional fields
-	Locale string `json:"locale,omitempty"` // User's locale/language
-	HD     string `json:"hd,omitempty"`     // Hosted domain (for G Suite users)
+	Locale              string   `json:"locale,omitempty"`                // User's locale/language
+	HD                  string   `json:"hd,omitempty"`                    // Hosted domain (for G Suite users)
+	PhoneNumber         string   `json:"phone_number,omitempty"`          // Phone number, preferably in E.164 format
+	PhoneNumberVerified bool     `json:"phone_number_verified,omitempty"` // Whether the phone number is verified
+	Address             *Address `json:"address,omitempty"`               // Postal address
+
+	// Groups are the IDs of the groups the user is a member of, emitted as
+	// the groups claim
+	Groups []string `json:"groups,omitempty"`
+	// AppRoles maps client IDs to the roles the user is assigned in that
+	// application, emitted as the roles claim of tokens issued to the client.
+	// Roles under AllApplications are assigned in every application.
+	AppRoles map[string][]string `json:"app_roles,omitempty"`
+
+	// CustomClaims are added to the tokens issued for the user. They are not
+	// returned by the userinfo endpoint.
+	CustomClaims *CustomClaims `json:"custom_claims,omitempty"`
+}
+
+// Address is a postal address as described in OIDC Core section 5.1.1
+type Address struct {
+	Formatted     string `json:"formatted,omitempty"`
+	StreetAddress string `json:"street_address,omitempty"`
+	Locality      string `json:"locality,omitempty"`
+	Region        string `json:"region,omitempty"`
+	PostalCode    string `json:"postal_code,omitempty"`
+	Country       string `json:"country,omitempty"`
 }
 
 // NewDefaultUser creates a user with default values
@@ -38,18 +65,49 @@ func (u *UserInfo) Clone() *UserInfo {
 		return nil
 	}
 
-	return &UserInfo{
-		Sub:           u.Sub,
-		ID:            u.ID,
-		Name:          u.Name,
-		GivenName:     u.GivenName,
-		FamilyName:    u.FamilyName,
-		Email:         u.Email,
-		EmailVerified: u.EmailVerified,
-		Picture:       u.Picture,
-		Locale:        u.Locale,
-		HD:            u.HD,
-	}
+	clone := &UserInfo{
+		Sub:                 u.Sub,
+		ID:                  u.ID,
+		Name:                u.Name,
+		GivenName:           u.GivenName,
+		FamilyName:          u.FamilyName,
+		Email:               u.Email,
+		EmailVerified:       u.EmailVerified,
+		Picture:             u.Picture,
+		Locale:              u.Locale,
+		HD:                  u.HD,
+		PhoneNumber:         u.PhoneNumber,
+		PhoneNumberVerified: u.PhoneNumberVerified,
+	}
+	if u.Address != nil {
+		address := *u.Address
+		clone.Address = &address
+	}
+	clone.Groups = append([]string(nil), u.Groups...)
+	if u.AppRoles != nil {
+		clone.AppRoles = make(map[string][]string, len(u.AppRoles))
+		for clientID, roles := range u.AppRoles {
+			clone.AppRoles[clientID] = append([]string(nil), roles...)
+		}
+	}
+	clone.CustomClaims = u.CustomClaims.Clone()
+	return clone
+}
+
+// Claims returns the user's claims as they appear in userinfo responses.
+// App roles depend on the client, see ClaimsFor.
+func (u *UserInfo) Claims() map[string]interface{} {
+	data, err := json.Marshal(u)
+	if err != nil {
+		return map[string]interface{}{"sub": u.Sub}
+	}
+	var claims map[string]interface{}
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return map[string]interface{}{"sub": u.Sub}
+	}
+	delete(claims, "custom_claims")
+	delete(claims, "app_roles")
+	return claims
 }
 
 // Merge updates this user with non-zero values from the other user
@@ -88,6 +146,23 @@ func (u *UserInfo) Merge(other *UserInfo) {
 	if other.HD != "" {
 		u.HD = other.HD
 	}
+	if other.PhoneNumber != "" {
+		u.PhoneNumber = other.PhoneNumber
+		u.PhoneNumberVerified = other.PhoneNumberVerified
+	}
+	if other.Address != nil {
+		address := *other.Address
+		u.Address = &address
+	}
+	if other.Groups != nil {
+		u.Groups = append([]string(nil), other.Groups...)
+	}
+	if other.AppRoles != nil {
+		u.AppRoles = other.Clone().AppRoles
+	}
+	if other.CustomClaims != nil {
+		u.CustomClaims = other.CustomClaims.Clone()
+	}
 }
 
 // UpdateUserFromConfig updates user info from a configuration map
@@ -128,4 +203,28 @@ func UpdateUserFromConfig(user *UserInfo, config map[string]interface{}) {
 	if hd, ok := config["hd"].(string); ok {
 		user.HD = hd
 	}
+	if phoneNumber, ok := config["phone_number"].(string); ok {
+		user.PhoneNumber = phoneNumber
+	}
+	if phoneNumberVerified, ok := config["phone_number_verified"].(bool); ok {
+		user.PhoneNumberVerified = phoneNumberVerified
+	}
+	if address, ok := config["address"].(map[string]interface{}); ok {
+		user.Address = &Address{}
+		data, _ := json.Marshal(address)
+		_ = json.Unmarshal(data, user.Address)
+	}
+	if groups, ok := config["groups"].([]interface{}); ok {
+		user.Groups = make([]string, 0, len(groups))
+		for _, group := range groups {
+			if groupID, ok := group.(string); ok {
+				user.Groups = append(user.Groups, groupID)
+			}
+		}
+	}
+	if appRoles, ok := config["app_roles"].(map[string]interface{}); ok {
+		user.AppRoles = nil
+		data, _ := json.Marshal(appRoles)
+		_ = json.Unmarshal(data, &user.AppRoles)
+	}
 }
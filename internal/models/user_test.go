@@ -114,6 +114,9 @@ func TestUserInfoClone(t *testing.T) {
 		Picture:       "https://example.com/picture.jpg",
 		Locale:        "en-US",
 		HD:            "example.com",
+		CustomClaims:  &CustomClaims{IDToken: map[string]interface{}{"tenant_id": "t1"}},
+		Groups:        []string{"g1"},
+		AppRoles:      map[string][]string{"client-1": {"Admin"}},
 	}
 
 	// Clone the user
@@ -134,6 +137,18 @@ func TestUserInfoClone(t *testing.T) {
 	if original.Email == cloned.Email {
 		t.Error("Clone is not a deep copy - email was modified in original")
 	}
+	cloned.CustomClaims.IDToken["tenant_id"] = "t2"
+	if original.CustomClaims.IDToken["tenant_id"] != "t1" {
+		t.Error("Clone is not a deep copy - custom claims were modified in original")
+	}
+	if _, exists := original.Claims()["custom_claims"]; exists {
+		t.Error("Custom claims should not be userinfo claims")
+	}
+	cloned.Groups[0] = "g2"
+	cloned.AppRoles["client-1"][0] = "Reader"
+	if original.Groups[0] != "g1" || original.AppRoles["client-1"][0] != "Admin" {
+		t.Error("Clone is not a deep copy - memberships were modified in original")
+	}
 
 	// Test cloning nil
 	var nilUser *UserInfo
@@ -269,6 +284,19 @@ func TestUpdateUserFromConfig(t *testing.T) {
 				HD:            "config.com",
 			},
 		},
+		{
+			name: "Update memberships",
+			user: &UserInfo{Sub: "base-id", Groups: []string{"old"}},
+			config: map[string]interface{}{
+				"groups":    []interface{}{"g1", "g2"},
+				"app_roles": map[string]interface{}{"*": []interface{}{"User"}},
+			},
+			expected: &UserInfo{
+				Sub:      "base-id",
+				Groups:   []string{"g1", "g2"},
+				AppRoles: map[string][]string{"*": {"User"}},
+			},
+		},
 		{
 			name: "Update some fields",
 			user: &UserInfo{
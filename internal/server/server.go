@@ -2,6 +2,7 @@ package server
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/handlers"
 	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/models"
@@ -24,11 +25,23 @@ func NewServer(addr string) *Server {
 	// Create handlers
 	authorizeHandler := &handlers.AuthorizeHandler{Store: memoryStore}
 	tokenHandler := handlers.NewTokenHandler(memoryStore)
-	userInfoHandler := &handlers.UserInfoHandler{Store: memoryStore}
+	userInfoHandler := &handlers.UserInfoHandler{Store: memoryStore, IssuerURL: "http://localhost" + addr}
 	configHandler := handlers.NewConfigHandler(memoryStore, defaultUser)
 	versionHandler := handlers.NewVersionHandler()
 	jwksHandler := handlers.NewJWKSHandler()
-	openIDConfigHandler := handlers.NewOpenIDConfigHandler("http://localhost" + addr)
+	openIDConfigHandler := handlers.NewOpenIDConfigHandler(memoryStore, "http://localhost"+addr)
+	deviceAuthorizationHandler := handlers.NewDeviceAuthorizationHandler(memoryStore, "http://localhost"+addr)
+	deviceVerificationHandler := handlers.NewDeviceVerificationHandler(memoryStore)
+	introspectionHandler := handlers.NewIntrospectionHandler(memoryStore, "http://localhost"+addr)
+	revocationHandler := handlers.NewRevocationHandler(memoryStore)
+	usersHandler := handlers.NewUsersHandler(memoryStore)
+	consentsHandler := handlers.NewConsentsHandler(memoryStore)
+	clientsHandler := handlers.NewClientsHandler(memoryStore)
+	scopesHandler := handlers.NewScopesHandler(memoryStore)
+	rulesHandler := handlers.NewRulesHandler(memoryStore)
+	groupsHandler := handlers.NewGroupsHandler(memoryStore)
+	registrationHandler := handlers.NewRegistrationHandler(memoryStore, "http://localhost"+addr)
+	keysHandler := handlers.NewKeysHandler(time.Hour)
 	
 	callbackHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -38,10 +51,28 @@ func NewServer(addr string) *Server {
 	mux.Handle("/authorize", authorizeHandler)
 	mux.Handle("/token", tokenHandler)
 	mux.Handle("/userinfo", userInfoHandler)
+	mux.Handle("/groups", groupsHandler)
 	mux.Handle("/config", configHandler)
 	mux.Handle("/version", versionHandler)
 	mux.Handle("/jwks", jwksHandler)
 	mux.Handle("/.well-known/openid-configuration", openIDConfigHandler)
+	mux.Handle("/device/code", deviceAuthorizationHandler)
+	mux.Handle("/device", deviceVerificationHandler)
+	mux.Handle("/introspect", introspectionHandler)
+	mux.Handle("/revoke", revocationHandler)
+	mux.Handle("/admin/users", usersHandler)
+	mux.Handle("/admin/users/", usersHandler)
+	mux.Handle("/admin/consents", consentsHandler)
+	mux.Handle("/admin/clients", clientsHandler)
+	mux.Handle("/admin/clients/", clientsHandler)
+	mux.Handle("/admin/scopes", scopesHandler)
+	mux.Handle("/admin/scopes/", scopesHandler)
+	mux.Handle("/admin/rules", rulesHandler)
+	mux.Handle("/admin/rules/", rulesHandler)
+	mux.Handle("/register", registrationHandler)
+	mux.Handle("/register/", registrationHandler)
+	mux.Handle("/admin/keys", keysHandler)
+	mux.Handle("/admin/keys/", keysHandler)
 	mux.Handle("/callback", callbackHandler)
 
 	return &Server{
@@ -2,7 +2,10 @@ package store
 
 import (
 	"log"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/models"
 	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/types"
@@ -13,11 +16,56 @@ type Store interface {
 	// Auth code methods
 	StoreAuthCode(code string, request *models.AuthRequest)
 	GetAuthCode(code string) (*models.AuthRequest, bool)
+	RedeemAuthCode(code, familyID string) bool
 	RemoveAuthCode(code string)
 
 	// Token methods
 	StoreToken(token string, clientID string)
 	GetClientIDByToken(token string) (string, bool)
+	StoreAccessToken(record *models.TokenRecord)
+	GetAccessToken(token string) (*models.TokenRecord, bool)
+	StoreRefreshToken(record *models.TokenRecord)
+	GetRefreshToken(token string) (*models.TokenRecord, bool)
+	MarkRefreshTokenUsed(token string) bool
+	RevokeTokenFamily(familyID string)
+	RevokeToken(token string)
+
+	// Device authorization methods
+	StoreDeviceAuthorization(authorization *models.DeviceAuthorization)
+	GetDeviceAuthorizationByUserCode(userCode string) (*models.DeviceAuthorization, bool)
+	UpdateDeviceAuthorization(deviceCode string, update func(*models.DeviceAuthorization)) (*models.DeviceAuthorization, bool)
+
+	// User methods
+	StoreUser(user *models.UserInfo)
+	GetUser(sub string) (*models.UserInfo, bool)
+	FindUser(loginHint string) (*models.UserInfo, bool)
+	ListUsers() []*models.UserInfo
+	RemoveUser(sub string) bool
+
+	// Client methods
+	StoreClient(client *models.Client)
+	GetClient(clientID string) (*models.Client, bool)
+	ListClients() []*models.Client
+	RemoveClient(clientID string) bool
+	UseClientAssertionID(clientID, jti string, expiresAt time.Time) bool
+
+	// Scope methods
+	StoreScope(scope *models.Scope)
+	GetScope(name string) (*models.Scope, bool)
+	ListScopes() []*models.Scope
+	RemoveScope(name string) bool
+
+	// Claim rule methods
+	StoreClaimRule(rule *models.ClaimRule)
+	GetClaimRule(name string) (*models.ClaimRule, bool)
+	ListClaimRules() []*models.ClaimRule
+	RemoveClaimRule(name string) bool
+
+	// Consent methods
+	StoreConsent(consent *models.Consent)
+	GetConsent(clientID, userID string) (*models.Consent, bool)
+	ListConsents() []*models.Consent
+	RemoveConsent(clientID, userID string) bool
 
 	// Config methods
 	StoreTokenConfig(config map[string]interface{})
@@ -25,23 +73,52 @@ type Store interface {
 	StoreErrorScenario(scenario types.ErrorScenario)
 	GetErrorScenario(endpoint string) (*types.ErrorScenario, bool)
 	ClearErrorScenario(endpoint string)
+	StoreSettings(settings types.Settings)
+	GetSettings() types.Settings
+	StoreCustomClaims(claims *models.CustomClaims)
+	GetCustomClaims() *models.CustomClaims
 }
 
 // MemoryStore implements Store using in-memory storage
 type MemoryStore struct {
 	mu            sync.RWMutex
 	authCodes     map[string]*models.AuthRequest
-	tokens        map[string]string // token -> clientID
+	tokens        map[string]*models.TokenRecord // access token -> record
+	refreshTokens map[string]*models.TokenRecord // refresh token -> record
+	clients       map[string]*models.Client
+	scopes        map[string]*models.Scope               // custom scope name -> claim mapping
+	claimRules    map[string]*models.ClaimRule           // rule name -> rule
+	deviceCodes   map[string]*models.DeviceAuthorization // device code -> authorization
+	users         map[string]*models.UserInfo            // sub -> user
+	consents      map[consentKey]*models.Consent
+	assertionIDs  map[assertionKey]time.Time // used client assertion jti -> expiry
 	tokenConfig   map[string]interface{}
 	errorScenario *types.ErrorScenario
+	settings      types.Settings
+	customClaims  *models.CustomClaims // global custom claims
+	lastPruned    time.Time            // when writes last swept out expired entries
 }
 
+// pruneInterval is how often writes sweep expired codes, tokens and client
+// assertion IDs out of the store. An entry is only swept once it has been
+// expired for a whole interval, so that a client retrying or polling just
+// after expiry still gets an expiry error rather than an unknown-code error.
+const pruneInterval = time.Minute
+
 // NewMemoryStore creates a new memory store
 func NewMemoryStore() *MemoryStore {
 	return &MemoryStore{
-		authCodes:   make(map[string]*models.AuthRequest),
-		tokens:      make(map[string]string),
-		tokenConfig: make(map[string]interface{}),
+		authCodes:     make(map[string]*models.AuthRequest),
+		tokens:        make(map[string]*models.TokenRecord),
+		refreshTokens: make(map[string]*models.TokenRecord),
+		clients:       make(map[string]*models.Client),
+		scopes:        make(map[string]*models.Scope),
+		claimRules:    make(map[string]*models.ClaimRule),
+		deviceCodes:   make(map[string]*models.DeviceAuthorization),
+		users:         make(map[string]*models.UserInfo),
+		consents:      make(map[consentKey]*models.Consent),
+		assertionIDs:  make(map[assertionKey]time.Time),
+		tokenConfig:   make(map[string]interface{}),
 	}
 }
 
@@ -49,15 +126,36 @@ func NewMemoryStore() *MemoryStore {
 func (s *MemoryStore) StoreAuthCode(code string, request *models.AuthRequest) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.pruneExpiredLocked()
 	s.authCodes[code] = request
 }
 
-// GetAuthCode retrieves an authorization code by its value
+// GetAuthCode retrieves a copy of an authorization code by its value
 func (s *MemoryStore) GetAuthCode(code string) (*models.AuthRequest, bool) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	request, exists := s.authCodes[code]
-	return request, exists
+	if !exists {
+		return nil, false
+	}
+	requestCopy := *request
+	requestCopy.Claims = request.Claims.Clone()
+	return &requestCopy, true
+}
+
+// RedeemAuthCode marks an authorization code as used and records the token
+// family issued from it. The code is kept so that a replay can revoke that family.
+// It returns false if the code is unknown or had already been redeemed.
+func (s *MemoryStore) RedeemAuthCode(code, familyID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	request, exists := s.authCodes[code]
+	if !exists || request.Used {
+		return false
+	}
+	request.Used = true
+	request.FamilyID = familyID
+	return true
 }
 
 // RemoveAuthCode removes an authorization code
@@ -71,15 +169,434 @@ func (s *MemoryStore) RemoveAuthCode(code string) {
 func (s *MemoryStore) StoreToken(token string, clientID string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.tokens[token] = clientID
+	s.pruneExpiredLocked()
+	s.tokens[token] = &models.TokenRecord{Token: token, ClientID: clientID}
 }
 
 // GetClientIDByToken retrieves the client ID associated with a token
 func (s *MemoryStore) GetClientIDByToken(token string) (string, bool) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	clientID, exists := s.tokens[token]
-	return clientID, exists
+	record, exists := s.tokens[token]
+	if !exists {
+		return "", false
+	}
+	return record.ClientID, true
+}
+
+// StoreAccessToken stores an access token together with its grant details
+func (s *MemoryStore) StoreAccessToken(record *models.TokenRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneExpiredLocked()
+	s.tokens[record.Token] = record
+}
+
+// GetAccessToken retrieves a copy of the record for an access token
+func (s *MemoryStore) GetAccessToken(token string) (*models.TokenRecord, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	record, exists := s.tokens[token]
+	if !exists {
+		return nil, false
+	}
+	recordCopy := *record
+	recordCopy.Claims = record.Claims.Clone()
+	return &recordCopy, true
+}
+
+// StoreRefreshToken stores a refresh token together with its grant details
+func (s *MemoryStore) StoreRefreshToken(record *models.TokenRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneExpiredLocked()
+	s.refreshTokens[record.Token] = record
+}
+
+// GetRefreshToken retrieves a copy of the record for a refresh token
+func (s *MemoryStore) GetRefreshToken(token string) (*models.TokenRecord, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	record, exists := s.refreshTokens[token]
+	if !exists {
+		return nil, false
+	}
+	recordCopy := *record
+	recordCopy.Claims = record.Claims.Clone()
+	return &recordCopy, true
+}
+
+// MarkRefreshTokenUsed flags a refresh token as rotated out. It returns false if
+// the token is unknown or had already been used, which signals a replay.
+func (s *MemoryStore) MarkRefreshTokenUsed(token string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, exists := s.refreshTokens[token]
+	if !exists || record.Used {
+		return false
+	}
+	record.Used = true
+	return true
+}
+
+// RevokeTokenFamily removes every access and refresh token issued from the same grant
+func (s *MemoryStore) RevokeTokenFamily(familyID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revokeFamilyLocked(familyID)
+}
+
+// revokeFamilyLocked removes the tokens of a grant. The caller must hold s.mu.
+func (s *MemoryStore) revokeFamilyLocked(familyID string) {
+	if familyID == "" {
+		return
+	}
+	for token, record := range s.tokens {
+		if record.FamilyID == familyID {
+			delete(s.tokens, token)
+		}
+	}
+	for token, record := range s.refreshTokens {
+		if record.FamilyID == familyID {
+			delete(s.refreshTokens, token)
+		}
+	}
+}
+
+// pruneExpiredLocked removes the codes, tokens and client assertion IDs that
+// expired more than a pruneInterval ago, at most once per pruneInterval. The
+// caller must hold the write lock.
+func (s *MemoryStore) pruneExpiredLocked() {
+	now := time.Now()
+	if now.Sub(s.lastPruned) < pruneInterval {
+		return
+	}
+	s.lastPruned = now
+
+	cutoff := now.Add(-pruneInterval)
+	expired := func(expiresAt time.Time) bool {
+		return !expiresAt.IsZero() && expiresAt.Before(cutoff)
+	}
+	for code, request := range s.authCodes {
+		if expired(request.Expiration) {
+			delete(s.authCodes, code)
+		}
+	}
+	for token, record := range s.tokens {
+		if expired(record.ExpiresAt) {
+			delete(s.tokens, token)
+		}
+	}
+	for token, record := range s.refreshTokens {
+		if expired(record.ExpiresAt) {
+			delete(s.refreshTokens, token)
+		}
+	}
+	for deviceCode, authorization := range s.deviceCodes {
+		if expired(authorization.ExpiresAt) {
+			delete(s.deviceCodes, deviceCode)
+		}
+	}
+	for key, expiry := range s.assertionIDs {
+		if expired(expiry) {
+			delete(s.assertionIDs, key)
+		}
+	}
+}
+
+// StoreDeviceAuthorization stores a pending device authorization request
+func (s *MemoryStore) StoreDeviceAuthorization(authorization *models.DeviceAuthorization) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneExpiredLocked()
+	s.deviceCodes[authorization.DeviceCode] = authorization
+}
+
+// GetDeviceAuthorizationByUserCode retrieves a copy of the device authorization
+// for the code the user types in on the verification page
+func (s *MemoryStore) GetDeviceAuthorizationByUserCode(userCode string) (*models.DeviceAuthorization, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, authorization := range s.deviceCodes {
+		if authorization.UserCode == userCode {
+			authorizationCopy := *authorization
+			return &authorizationCopy, true
+		}
+	}
+	return nil, false
+}
+
+// UpdateDeviceAuthorization applies update to a device authorization while
+// holding the store lock, and returns a copy of the updated authorization
+func (s *MemoryStore) UpdateDeviceAuthorization(deviceCode string, update func(*models.DeviceAuthorization)) (*models.DeviceAuthorization, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	authorization, exists := s.deviceCodes[deviceCode]
+	if !exists {
+		return nil, false
+	}
+	update(authorization)
+	authorizationCopy := *authorization
+	return &authorizationCopy, true
+}
+
+// StoreUser adds a user to the registry, replacing any user with the same sub
+func (s *MemoryStore) StoreUser(user *models.UserInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[user.Sub] = user.Clone()
+}
+
+// GetUser retrieves a copy of a registered user by sub
+func (s *MemoryStore) GetUser(sub string) (*models.UserInfo, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	user, exists := s.users[sub]
+	if !exists {
+		return nil, false
+	}
+	return user.Clone(), true
+}
+
+// FindUser looks a registered user up by sub or, failing that, by email address
+func (s *MemoryStore) FindUser(loginHint string) (*models.UserInfo, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if user, exists := s.users[loginHint]; exists {
+		return user.Clone(), true
+	}
+	for _, user := range s.users {
+		if loginHint != "" && strings.EqualFold(user.Email, loginHint) {
+			return user.Clone(), true
+		}
+	}
+	return nil, false
+}
+
+// ListUsers returns copies of all registered users ordered by sub
+func (s *MemoryStore) ListUsers() []*models.UserInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	users := make([]*models.UserInfo, 0, len(s.users))
+	for _, user := range s.users {
+		users = append(users, user.Clone())
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Sub < users[j].Sub })
+	return users
+}
+
+// RemoveUser deletes a user from the registry. Tokens bound to the user stop
+// resolving to an identity. It returns false if the user did not exist.
+func (s *MemoryStore) RemoveUser(sub string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, exists := s.users[sub]
+	delete(s.users, sub)
+	return exists
+}
+
+// StoreClient registers a client, replacing any client with the same ID
+func (s *MemoryStore) StoreClient(client *models.Client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clients[client.ClientID] = client.Clone()
+}
+
+// GetClient retrieves a copy of a registered client by its ID
+func (s *MemoryStore) GetClient(clientID string) (*models.Client, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	client, exists := s.clients[clientID]
+	if !exists {
+		return nil, false
+	}
+	return client.Clone(), true
+}
+
+// ListClients returns copies of all registered clients ordered by client ID
+func (s *MemoryStore) ListClients() []*models.Client {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	clients := make([]*models.Client, 0, len(s.clients))
+	for _, client := range s.clients {
+		clients = append(clients, client.Clone())
+	}
+	sort.Slice(clients, func(i, j int) bool { return clients[i].ClientID < clients[j].ClientID })
+	return clients
+}
+
+// RemoveClient deletes a client from the registry. It returns false if the
+// client did not exist.
+func (s *MemoryStore) RemoveClient(clientID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, exists := s.clients[clientID]
+	delete(s.clients, clientID)
+	return exists
+}
+
+// StoreScope defines a custom scope, replacing any scope with the same name
+func (s *MemoryStore) StoreScope(scope *models.Scope) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	scopeCopy := models.Scope{Name: scope.Name, Claims: append([]string(nil), scope.Claims...)}
+	s.scopes[scope.Name] = &scopeCopy
+}
+
+// GetScope retrieves a copy of a custom scope by its name
+func (s *MemoryStore) GetScope(name string) (*models.Scope, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	scope, exists := s.scopes[name]
+	if !exists {
+		return nil, false
+	}
+	scopeCopy := models.Scope{Name: scope.Name, Claims: append([]string(nil), scope.Claims...)}
+	return &scopeCopy, true
+}
+
+// ListScopes returns copies of all custom scopes ordered by name
+func (s *MemoryStore) ListScopes() []*models.Scope {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	scopes := make([]*models.Scope, 0, len(s.scopes))
+	for _, scope := range s.scopes {
+		scopes = append(scopes, &models.Scope{Name: scope.Name, Claims: append([]string(nil), scope.Claims...)})
+	}
+	sort.Slice(scopes, func(i, j int) bool { return scopes[i].Name < scopes[j].Name })
+	return scopes
+}
+
+// RemoveScope deletes a custom scope. It returns false if the scope did not exist.
+func (s *MemoryStore) RemoveScope(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, exists := s.scopes[name]
+	delete(s.scopes, name)
+	return exists
+}
+
+// StoreClaimRule defines a claim rule, replacing any rule with the same name
+func (s *MemoryStore) StoreClaimRule(rule *models.ClaimRule) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.claimRules[rule.Name] = rule.Clone()
+}
+
+// GetClaimRule retrieves a copy of a claim rule by its name
+func (s *MemoryStore) GetClaimRule(name string) (*models.ClaimRule, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rule, exists := s.claimRules[name]
+	if !exists {
+		return nil, false
+	}
+	return rule.Clone(), true
+}
+
+// ListClaimRules returns copies of all claim rules ordered by name, which is
+// the order they are applied in
+func (s *MemoryStore) ListClaimRules() []*models.ClaimRule {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rules := make([]*models.ClaimRule, 0, len(s.claimRules))
+	for _, rule := range s.claimRules {
+		rules = append(rules, rule.Clone())
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Name < rules[j].Name })
+	return rules
+}
+
+// RemoveClaimRule deletes a claim rule. It returns false if the rule did not exist.
+func (s *MemoryStore) RemoveClaimRule(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, exists := s.claimRules[name]
+	delete(s.claimRules, name)
+	return exists
+}
+
+// assertionKey identifies a client assertion by its issuing client and jti
+type assertionKey struct {
+	clientID string
+	jti      string
+}
+
+// UseClientAssertionID records the jti of a client assertion until the assertion
+// expires. It returns false if the client already used the jti (RFC 7523 section 3).
+func (s *MemoryStore) UseClientAssertionID(clientID, jti string, expiresAt time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneExpiredLocked()
+
+	key := assertionKey{clientID: clientID, jti: jti}
+	if expiry, used := s.assertionIDs[key]; used && !time.Now().After(expiry) {
+		return false
+	}
+	s.assertionIDs[key] = expiresAt
+	return true
+}
+
+// consentKey identifies the consent a user gave a client
+type consentKey struct {
+	clientID string
+	userID   string
+}
+
+// StoreConsent saves a consent decision, replacing any earlier decision for
+// the same client and user
+func (s *MemoryStore) StoreConsent(consent *models.Consent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	consentCopy := *consent
+	consentCopy.Scopes = append([]string(nil), consent.Scopes...)
+	s.consents[consentKey{consent.ClientID, consent.UserID}] = &consentCopy
+}
+
+// GetConsent retrieves the consent a user gave a client, falling back to the
+// client-wide consent without a user
+func (s *MemoryStore) GetConsent(clientID, userID string) (*models.Consent, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	consent, exists := s.consents[consentKey{clientID, userID}]
+	if !exists {
+		consent, exists = s.consents[consentKey{clientID, ""}]
+	}
+	if !exists {
+		return nil, false
+	}
+	consentCopy := *consent
+	consentCopy.Scopes = append([]string(nil), consent.Scopes...)
+	return &consentCopy, true
+}
+
+// ListConsents returns all consent decisions ordered by client and user
+func (s *MemoryStore) ListConsents() []*models.Consent {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	consents := make([]*models.Consent, 0, len(s.consents))
+	for _, consent := range s.consents {
+		consentCopy := *consent
+		consentCopy.Scopes = append([]string(nil), consent.Scopes...)
+		consents = append(consents, &consentCopy)
+	}
+	sort.Slice(consents, func(i, j int) bool {
+		if consents[i].ClientID != consents[j].ClientID {
+			return consents[i].ClientID < consents[j].ClientID
+		}
+		return consents[i].UserID < consents[j].UserID
+	})
+	return consents
+}
+
+// RemoveConsent deletes a consent decision. It returns false if none existed.
+func (s *MemoryStore) RemoveConsent(clientID, userID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := consentKey{clientID, userID}
+	_, exists := s.consents[key]
+	delete(s.consents, key)
+	return exists
 }
 
 // StoreTokenConfig saves customized token configuration
@@ -141,6 +658,48 @@ func (s *MemoryStore) ClearErrorScenario(endpoint string) {
 	}
 }
 
+// RevokeToken removes an access or refresh token. Revoking a refresh token also
+// revokes every other token issued from the same grant (RFC 7009 section 2.1).
+func (s *MemoryStore) RevokeToken(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, isRefreshToken := s.refreshTokens[token]
+	delete(s.refreshTokens, token)
+	delete(s.tokens, token)
+	if isRefreshToken {
+		s.revokeFamilyLocked(record.FamilyID)
+	}
+}
+
+// StoreSettings replaces the server behaviour settings
+func (s *MemoryStore) StoreSettings(settings types.Settings) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.settings = settings
+}
+
+// GetSettings returns the current server behaviour settings
+func (s *MemoryStore) GetSettings() types.Settings {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.settings
+}
+
+// StoreCustomClaims replaces the global custom claims added to every token.
+// Nil removes them.
+func (s *MemoryStore) StoreCustomClaims(claims *models.CustomClaims) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.customClaims = claims.Clone()
+}
+
+// GetCustomClaims returns a copy of the global custom claims, or nil if none are set
+func (s *MemoryStore) GetCustomClaims() *models.CustomClaims {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.customClaims.Clone()
+}
+
 // GetUserInfoByToken retrieves user information based on a token
 func (s *MemoryStore) GetUserInfoByToken(token string) (*models.UserInfo, bool) {
 	s.mu.RLock()
@@ -152,11 +711,24 @@ func (s *MemoryStore) GetUserInfoByToken(token string) (*models.UserInfo, bool)
 		log.Printf("Store: Looking up token (short token)")
 	}
 
-	clientID, exists := s.tokens[token]
-	if !exists {
+	record, exists := s.tokens[token]
+	if !exists || record.Expired() {
 		log.Printf("Store: Token not found in tokens map")
 		return nil, false
 	}
+
+	// Tokens bound to a registered user resolve to that user's identity
+	if record.UserID != "" {
+		user, exists := s.users[record.UserID]
+		if !exists {
+			log.Printf("Store: User bound to token no longer exists")
+			return nil, false
+		}
+		log.Printf("Store: Found registered user for token: %s", user.Sub)
+		return user.Clone(), true
+	}
+
+	clientID := record.ClientID
 	log.Printf("Store: Found clientID for token: %s", clientID)
 
 	// Instead of trying to look up the auth request (which is removed after token exchange),
@@ -3,6 +3,7 @@ package store
 import (
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/models"
 	"github.com/chrisw-dev/golang-mock-oauth2-server/internal/types"
@@ -28,6 +29,30 @@ func TestMemoryStore_AuthCodeMethods(t *testing.T) {
 	}
 }
 
+func TestMemoryStore_ClaimsRequestIsCopied(t *testing.T) {
+	store := NewMemoryStore()
+	claims := &models.ClaimsRequest{UserInfo: map[string]*models.ClaimRequest{"email": {Essential: true}}}
+	store.StoreAuthCode("code", &models.AuthRequest{Claims: claims})
+	store.StoreAccessToken(&models.TokenRecord{Token: "access", Claims: claims})
+	store.StoreRefreshToken(&models.TokenRecord{Token: "refresh", Claims: claims})
+
+	request, _ := store.GetAuthCode("code")
+	request.Claims.UserInfo["email"].Essential = false
+	access, _ := store.GetAccessToken("access")
+	access.Claims.UserInfo["locale"] = nil
+	refresh, _ := store.GetRefreshToken("refresh")
+	delete(refresh.Claims.UserInfo, "email")
+
+	request, _ = store.GetAuthCode("code")
+	access, _ = store.GetAccessToken("access")
+	refresh, _ = store.GetRefreshToken("refresh")
+	for _, stored := range []*models.ClaimsRequest{request.Claims, access.Claims, refresh.Claims} {
+		if len(stored.UserInfo) != 1 || stored.UserInfo["email"] == nil || !stored.UserInfo["email"].Essential {
+			t.Errorf("expected stored claims request to be unaffected, got %+v", stored.UserInfo)
+		}
+	}
+}
+
 func TestMemoryStore_TokenMethods(t *testing.T) {
 	store := NewMemoryStore()
 	token := "test-token"
@@ -41,6 +66,67 @@ func TestMemoryStore_TokenMethods(t *testing.T) {
 	}
 }
 
+func TestMemoryStore_RefreshTokenMethods(t *testing.T) {
+	store := NewMemoryStore()
+	store.StoreAccessToken(&models.TokenRecord{Token: "access-1", ClientID: "test-client", FamilyID: "family-1"})
+	store.StoreRefreshToken(&models.TokenRecord{Token: "refresh-1", ClientID: "test-client", FamilyID: "family-1"})
+	store.StoreRefreshToken(&models.TokenRecord{Token: "refresh-2", ClientID: "test-client", FamilyID: "family-2"})
+
+	record, exists := store.GetRefreshToken("refresh-1")
+	if !exists || record.ClientID != "test-client" {
+		t.Fatalf("expected stored refresh token, got %+v", record)
+	}
+
+	// Test MarkRefreshTokenUsed only succeeds once
+	if !store.MarkRefreshTokenUsed("refresh-1") {
+		t.Errorf("expected first use to succeed")
+	}
+	if store.MarkRefreshTokenUsed("refresh-1") {
+		t.Errorf("expected second use to be reported as reuse")
+	}
+
+	// Test RevokeTokenFamily only removes tokens from the given family
+	store.RevokeTokenFamily("family-1")
+	if _, exists := store.GetRefreshToken("refresh-1"); exists {
+		t.Errorf("expected refresh token to be revoked")
+	}
+	if _, exists := store.GetClientIDByToken("access-1"); exists {
+		t.Errorf("expected access token to be revoked")
+	}
+	if _, exists := store.GetRefreshToken("refresh-2"); !exists {
+		t.Errorf("expected unrelated refresh token to remain")
+	}
+}
+
+func TestMemoryStore_RevokeToken(t *testing.T) {
+	store := NewMemoryStore()
+	store.StoreAccessToken(&models.TokenRecord{Token: "access-1", FamilyID: "family-1"})
+	store.StoreAccessToken(&models.TokenRecord{Token: "access-2", FamilyID: "family-1"})
+	store.StoreRefreshToken(&models.TokenRecord{Token: "refresh-1", FamilyID: "family-1"})
+	store.StoreAccessToken(&models.TokenRecord{Token: "access-3", FamilyID: "family-2"})
+
+	// Revoking an access token leaves the rest of its family
+	store.RevokeToken("access-1")
+	if _, exists := store.GetAccessToken("access-1"); exists {
+		t.Errorf("expected access token to be revoked")
+	}
+	if _, exists := store.GetRefreshToken("refresh-1"); !exists {
+		t.Errorf("expected refresh token to remain")
+	}
+
+	// Revoking a refresh token revokes its whole family
+	store.RevokeToken("refresh-1")
+	if _, exists := store.GetRefreshToken("refresh-1"); exists {
+		t.Errorf("expected refresh token to be revoked")
+	}
+	if _, exists := store.GetAccessToken("access-2"); exists {
+		t.Errorf("expected access token of the family to be revoked")
+	}
+	if _, exists := store.GetAccessToken("access-3"); !exists {
+		t.Errorf("expected unrelated access token to remain")
+	}
+}
+
 func TestMemoryStore_ConfigMethods(t *testing.T) {
 	store := NewMemoryStore()
 	tokenConfig := map[string]interface{}{
@@ -108,3 +194,206 @@ func TestMemoryStore_GetUserInfoByToken(t *testing.T) {
 		t.Errorf("expected user info to match, got %+v", userInfo)
 	}
 }
+
+func TestMemoryStore_UserMethods(t *testing.T) {
+	store := NewMemoryStore()
+	store.StoreUser(&models.UserInfo{Sub: "bob", Email: "bob@example.com"})
+	store.StoreUser(&models.UserInfo{Sub: "alice", Email: "Alice@Example.com"})
+
+	if user, exists := store.FindUser("alice@example.com"); !exists || user.Sub != "alice" {
+		t.Errorf("expected lookup by email to find alice, got %+v", user)
+	}
+	if user, exists := store.FindUser("bob"); !exists || user.Sub != "bob" {
+		t.Errorf("expected lookup by sub to find bob, got %+v", user)
+	}
+	if _, exists := store.FindUser(""); exists {
+		t.Errorf("expected empty login hint to find no user")
+	}
+
+	users := store.ListUsers()
+	if len(users) != 2 || users[0].Sub != "alice" || users[1].Sub != "bob" {
+		t.Errorf("expected users ordered by sub, got %+v", users)
+	}
+
+	// Tokens bound to a user resolve to that user until the user is removed
+	store.StoreAccessToken(&models.TokenRecord{Token: "bob-token", ClientID: "test-client", UserID: "bob"})
+	if userInfo, exists := store.GetUserInfoByToken("bob-token"); !exists || userInfo.Email != "bob@example.com" {
+		t.Errorf("expected token to resolve to bob, got %+v", userInfo)
+	}
+	if !store.RemoveUser("bob") {
+		t.Errorf("expected bob to be removed")
+	}
+	if _, exists := store.GetUserInfoByToken("bob-token"); exists {
+		t.Errorf("expected token of removed user to be rejected")
+	}
+}
+
+func TestMemoryStore_ConsentMethods(t *testing.T) {
+	store := NewMemoryStore()
+	store.StoreConsent(&models.Consent{ClientID: "test-client", Scopes: []string{"openid"}})
+	store.StoreConsent(&models.Consent{ClientID: "test-client", UserID: "alice", Scopes: []string{"openid", "email"}})
+
+	if consent, exists := store.GetConsent("test-client", "alice"); !exists || len(consent.Scopes) != 2 {
+		t.Errorf("expected alice's own consent, got %+v", consent)
+	}
+
+	// The store keeps its own copy of the scopes
+	consent, _ := store.GetConsent("test-client", "alice")
+	consent.Scopes[1] = "phone"
+	store.ListConsents()[1].Scopes[0] = "profile"
+	if stored, _ := store.GetConsent("test-client", "alice"); stored.Scopes[0] != "openid" || stored.Scopes[1] != "email" {
+		t.Errorf("expected stored consent to be unaffected, got %+v", stored)
+	}
+	if consent, exists := store.GetConsent("test-client", "bob"); !exists || consent.UserID != "" {
+		t.Errorf("expected client-wide consent for bob, got %+v", consent)
+	}
+	if _, exists := store.GetConsent("other-client", "alice"); exists {
+		t.Errorf("expected no consent for other client")
+	}
+	if consents := store.ListConsents(); len(consents) != 2 || consents[1].UserID != "alice" {
+		t.Errorf("expected two consents ordered by user, got %+v", consents)
+	}
+	if !store.RemoveConsent("test-client", "alice") || store.RemoveConsent("test-client", "alice") {
+		t.Errorf("expected consent to be removed exactly once")
+	}
+}
+
+func TestMemoryStore_RedeemAuthCode(t *testing.T) {
+	store := NewMemoryStore()
+	store.StoreAuthCode("test-code", &models.AuthRequest{ClientID: "test-client"})
+
+	if !store.RedeemAuthCode("test-code", "family-1") {
+		t.Fatalf("expected first redemption to succeed")
+	}
+	if store.RedeemAuthCode("test-code", "family-2") {
+		t.Errorf("expected second redemption to fail")
+	}
+	if store.RedeemAuthCode("unknown-code", "family-3") {
+		t.Errorf("expected unknown code to fail")
+	}
+
+	// The redeemed code is kept with the family of the first redemption
+	request, exists := store.GetAuthCode("test-code")
+	if !exists || !request.Used || request.FamilyID != "family-1" {
+		t.Errorf("expected used code bound to family-1, got %+v", request)
+	}
+}
+
+func TestMemoryStore_ClientMethods(t *testing.T) {
+	store := NewMemoryStore()
+	original := &models.Client{
+		ClientID:     "web-app",
+		ClientSecret: "secret",
+		RedirectURIs: []string{"http://localhost/callback"},
+		CustomClaims: &models.CustomClaims{AccessToken: map[string]interface{}{"tenant_id": "t1"}},
+	}
+	store.StoreClient(original)
+	store.StoreClient(&models.Client{ClientID: "native-app"})
+
+	// The store keeps its own copy of the client
+	original.RedirectURIs[0] = "http://evil.example.com/callback"
+	original.CustomClaims.AccessToken["tenant_id"] = "t2"
+	client, _ := store.GetClient("web-app")
+	client.ClientSecret = "changed"
+	client.RedirectURIs[0] = "http://evil.example.com/callback"
+	client.CustomClaims.AccessToken["tenant_id"] = "t3"
+	stored, _ := store.GetClient("web-app")
+	if stored.ClientSecret != "secret" || stored.RedirectURIs[0] != "http://localhost/callback" ||
+		stored.CustomClaims.AccessToken["tenant_id"] != "t1" {
+		t.Errorf("expected stored client to be unaffected, got %+v", stored)
+	}
+
+	clients := store.ListClients()
+	if len(clients) != 2 || clients[0].ClientID != "native-app" || clients[1].ClientID != "web-app" {
+		t.Errorf("expected clients ordered by ID, got %+v", clients)
+	}
+	if !store.RemoveClient("web-app") || store.RemoveClient("web-app") {
+		t.Errorf("expected client to be removed exactly once")
+	}
+}
+
+func TestMemoryStore_UseClientAssertionID(t *testing.T) {
+	store := NewMemoryStore()
+	expiresAt := time.Now().Add(time.Minute)
+
+	if !store.UseClientAssertionID("service-a", "jti-1", expiresAt) {
+		t.Fatalf("expected first use to succeed")
+	}
+	if store.UseClientAssertionID("service-a", "jti-1", expiresAt) {
+		t.Errorf("expected replayed jti to be rejected")
+	}
+	if !store.UseClientAssertionID("service-b", "jti-1", expiresAt) {
+		t.Errorf("expected the same jti from another client to be accepted")
+	}
+
+	// Expired entries are forgotten, since the assertion itself is no longer accepted
+	store.UseClientAssertionID("service-a", "jti-2", time.Now().Add(-time.Second))
+	if !store.UseClientAssertionID("service-a", "jti-2", expiresAt) {
+		t.Errorf("expected expired jti to be pruned")
+	}
+}
+
+func TestMemoryStore_PruneExpired(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Now()
+	longAgo := now.Add(-2 * pruneInterval)
+	justNow := now.Add(-time.Second)
+
+	store.StoreAuthCode("expired-code", &models.AuthRequest{Expiration: longAgo})
+	store.StoreAuthCode("recent-code", &models.AuthRequest{Expiration: justNow})
+	store.StoreAuthCode("valid-code", &models.AuthRequest{Expiration: now.Add(time.Minute)})
+	store.StoreAccessToken(&models.TokenRecord{Token: "expired-access", ExpiresAt: longAgo})
+	store.StoreAccessToken(&models.TokenRecord{Token: "recent-access", ExpiresAt: justNow})
+	store.StoreToken("legacy-access", "test-client")
+	store.StoreRefreshToken(&models.TokenRecord{Token: "expired-refresh", ExpiresAt: longAgo})
+	store.StoreRefreshToken(&models.TokenRecord{Token: "refresh", FamilyID: "family-1"})
+	store.StoreDeviceAuthorization(&models.DeviceAuthorization{DeviceCode: "expired-device", ExpiresAt: longAgo})
+	store.StoreDeviceAuthorization(&models.DeviceAuthorization{DeviceCode: "recent-device", ExpiresAt: justNow})
+	store.UseClientAssertionID("service", "expired-jti", longAgo)
+	store.UseClientAssertionID("service", "jti", now.Add(time.Minute))
+
+	// Writes within the interval do not sweep again
+	store.StoreAuthCode("next-code", &models.AuthRequest{})
+	if _, exists := store.GetAuthCode("expired-code"); !exists {
+		t.Fatalf("expected no sweep within the prune interval")
+	}
+
+	// The next write after the interval sweeps every kind of entry
+	store.lastPruned = now.Add(-pruneInterval)
+	store.StoreAuthCode("next-code", &models.AuthRequest{})
+
+	if _, exists := store.GetAuthCode("expired-code"); exists {
+		t.Errorf("expected expired auth code to be pruned")
+	}
+	for _, code := range []string{"recent-code", "valid-code", "next-code"} {
+		if _, exists := store.GetAuthCode(code); !exists {
+			t.Errorf("expected auth code %s to be kept", code)
+		}
+	}
+	if _, exists := store.GetAccessToken("expired-access"); exists {
+		t.Errorf("expected expired access token to be pruned")
+	}
+	for _, token := range []string{"recent-access", "legacy-access"} {
+		if _, exists := store.GetAccessToken(token); !exists {
+			t.Errorf("expected access token %s to be kept", token)
+		}
+	}
+	if _, exists := store.GetRefreshToken("expired-refresh"); exists {
+		t.Errorf("expected expired refresh token to be pruned")
+	}
+	if _, exists := store.GetRefreshToken("refresh"); !exists {
+		t.Errorf("expected refresh token without expiry to be kept")
+	}
+	if _, exists := store.UpdateDeviceAuthorization("expired-device", func(*models.DeviceAuthorization) {}); exists {
+		t.Errorf("expected expired device code to be pruned")
+	}
+	if _, exists := store.UpdateDeviceAuthorization("recent-device", func(*models.DeviceAuthorization) {}); !exists {
+		t.Errorf("expected recently expired device code to be kept")
+	}
+	if _, exists := store.assertionIDs[assertionKey{clientID: "service", jti: "expired-jti"}]; exists {
+		t.Errorf("expected expired client assertion ID to be pruned")
+	}
+	if _, exists := store.assertionIDs[assertionKey{clientID: "service", jti: "jti"}]; !exists {
+		t.Errorf("expected client assertion ID to be kept")
+	}
+}
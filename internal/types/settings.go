@@ -0,0 +1,28 @@
+package types
+
+// Settings holds the runtime-adjustable behaviour switches of the mock server
+type Settings struct {
+	// RefreshTokenRotation issues a new refresh token on every refresh and
+	// invalidates the one that was presented
+	RefreshTokenRotation bool
+	// RefreshTokenReuseDetection revokes the whole token family when a rotated
+	// refresh token is presented again. Only applies when rotation is enabled.
+	RefreshTokenReuseDetection bool
+	// RequirePKCE rejects authorization requests from registered public clients
+	// without a code challenge, and authorization codes redeemed by public
+	// clients (clients that do not authenticate at the token endpoint) without PKCE
+	RequirePKCE bool
+	// RequireRegisteredClients rejects clients that are not in the client registry.
+	// When false (open registration) any client ID is accepted.
+	RequireRegisteredClients bool
+	// SigningAlg is the algorithm tokens are signed with unless the client
+	// registered another one. Empty means RS256.
+	SigningAlg string
+	// PairwiseSalt is mixed into the pairwise subject identifiers of clients
+	// with the pairwise subject type. Changing it changes those identifiers.
+	PairwiseSalt string
+	// GroupsOverageThreshold is the most groups a token or userinfo response
+	// carries. Users in more groups get a pointer to the groups endpoint
+	// instead, like Entra ID's groups overage. Zero disables the overage.
+	GroupsOverageThreshold int
+}
@@ -80,7 +80,11 @@ func (p *GoogleProvider) ExchangeCodeForToken(code string) (map[string]interface
 		return nil, &Error{Code: "server_error", Description: "Failed to generate access token"}
 	}
 
-	idToken, err := jwt.GenerateIDToken(p.IssuerURL, authRequest.ClientID, sub, email, name)
+	idToken, err := jwt.GenerateIDTokenWithOptions(p.IssuerURL, authRequest.ClientID, sub, email, name, jwt.IDTokenOptions{
+		Nonce:       authRequest.Nonce,
+		AccessToken: accessToken,
+		AuthTime:    authRequest.AuthTime,
+	})
 	if err != nil {
 		return nil, &Error{Code: "server_error", Description: "Failed to generate ID token"}
 	}